@@ -3,57 +3,68 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"go.uber.org/zap"
-	"inmem-db-go/internal/database"
-	"inmem-db-go/internal/database/compute"
-	"inmem-db-go/internal/database/storage"
-	"inmem-db-go/internal/database/storage/engine/in_memory"
+	"inmem-db-go/internal/configuration"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/initialization"
+	"io"
 	"os"
 )
 
 func main() {
-	logger := zap.NewNop()
+	configPath := flag.String("config", "", "path to the YAML config, "+configuration.PathEnv+" is used when empty")
+	format := flag.String("format", "text", "reply format: text or json")
+	flag.Parse()
 
-	parser, err := compute.NewParser(logger)
+	encoder, err := result.ParseEncoder(*format)
 	if err != nil {
-		logger.Error(err.Error())
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
 	}
 
-	analyzer, err := compute.NewAnalyzer(logger)
+	cfg, err := configuration.LoadFile(configuration.Path(*configPath))
 	if err != nil {
-		logger.Error(err.Error())
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
 	}
 
-	comp, err := compute.NewCompute(parser, analyzer, logger)
+	initializer, err := initialization.NewInitializer(cfg)
 	if err != nil {
-		logger.Error(err.Error())
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
 	}
 
-	engine, err := in_memory.NewEngine(in_memory.HashTableBuilder, logger)
-	if err != nil {
-		logger.Error(err.Error())
-	}
+	defer func() {
+		if err := initializer.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+	}()
 
-	store, err := storage.NewStorage(engine, logger)
-	if err != nil {
-		logger.Error(err.Error())
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	database, err := database.NewDatabase(comp, store, logger)
-	if err != nil {
-		logger.Error(err.Error())
-	}
+	initializer.Start(ctx)
 
+	logger := initializer.Logger()
+	session := initializer.Database().NewSession()
 	reader := bufio.NewReader(os.Stdin)
 	for {
 		request, err := reader.ReadString('\n')
-		if err != nil {
+		if err != nil && !errors.Is(err, io.EOF) {
 			logger.Error(err.Error())
 			continue
 		}
 
-		result := database.HandleQuery(context.Background(), request)
-		fmt.Printf("%s\n", result)
+		// the last line is executed even without a trailing newline
+		if request != "" {
+			reply := session.Execute(ctx, request)
+			fmt.Printf("%s\n", encoder.Encode(reply))
+		}
+
+		if err != nil {
+			return
+		}
 	}
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"golang.org/x/term"
+	"inmem-db-go/internal/network"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	exitOK = iota
+	exitUsageError
+	exitConnectionError
+	exitTimeout
+	exitServerError
+)
+
+const prompt = "inmem-db> "
+
+var errorResponsePrefix = []byte("[error]")
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	address := flag.String("address", "localhost:3223", "server address")
+	timeout := flag.Duration("timeout", 10*time.Second, "connection and query timeout")
+	maxResponseSize := flag.Int("max-response-size", 1<<20, "maximum response size in bytes")
+	query := flag.String("e", "", "execute a single query and exit")
+	script := flag.String("f", "", "execute queries from a file, \"-\" reads them from stdin")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "\nExit codes: 0 ok, 1 usage error, 2 connection error, 3 timeout, 4 server error")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 0 || (*query != "" && *script != "") {
+		flag.Usage()
+		return exitUsageError
+	}
+
+	client, err := network.NewTCPClient(
+		*address,
+		network.WithClientTimeout(*timeout),
+		network.WithClientMaxResponseSize(*maxResponseSize),
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitCode(err)
+	}
+
+	defer client.Close()
+
+	switch {
+	case *query != "":
+		return execute(client, []byte(*query), os.Stdout, os.Stderr)
+	case *script == "-":
+		return executeScript(client, os.Stdin, os.Stdout)
+	case *script != "":
+		file, err := os.Open(*script)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return exitUsageError
+		}
+
+		defer file.Close()
+		return executeScript(client, file, os.Stdout)
+	case !term.IsTerminal(int(os.Stdin.Fd())):
+		return executeScript(client, os.Stdin, os.Stdout)
+	default:
+		return repl(client)
+	}
+}
+
+// execute sends one query and prints the response, server side
+// errors are printed as well but reported through the exit code.
+func execute(client *network.TCPClient, query []byte, output, errOutput io.Writer) int {
+	response, err := client.Send(query)
+	if err != nil {
+		fmt.Fprintln(errOutput, err.Error())
+		return exitCode(err)
+	}
+
+	fmt.Fprintf(output, "%s\n", response)
+	if bytes.HasPrefix(response, errorResponsePrefix) {
+		return exitServerError
+	}
+
+	return exitOK
+}
+
+// executeScript runs every non-empty line of the script. It stops on the first
+// connection problem, server side errors do not interrupt the script.
+func executeScript(client *network.TCPClient, script io.Reader, output io.Writer) int {
+	code := exitOK
+	scanner := bufio.NewScanner(script)
+	for scanner.Scan() {
+		query := bytes.TrimSpace(scanner.Bytes())
+		if len(query) == 0 {
+			continue
+		}
+
+		switch result := execute(client, query, output, os.Stderr); result {
+		case exitOK:
+		case exitServerError:
+			code = exitServerError
+		default:
+			return result
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitUsageError
+	}
+
+	return code
+}
+
+func repl(client *network.TCPClient) int {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitUsageError
+	}
+
+	defer func() {
+		_ = term.Restore(fd, state)
+	}()
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, prompt)
+
+	for {
+		line, err := terminal.ReadLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return exitOK
+			}
+
+			fmt.Fprintf(terminal, "%s\n", err.Error())
+			return exitUsageError
+		}
+
+		query := strings.TrimSpace(line)
+		switch query {
+		case "":
+			continue
+		case "exit", "quit":
+			return exitOK
+		}
+
+		if code := execute(client, []byte(query), terminal, terminal); code != exitOK && code != exitServerError {
+			return code
+		}
+	}
+}
+
+func exitCode(err error) int {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return exitTimeout
+	}
+
+	return exitConnectionError
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"inmem-db-go/internal/configuration"
+	"inmem-db-go/internal/initialization"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	configPath := flag.String("config", "", "path to the YAML config, "+configuration.PathEnv+" is used when empty")
+	flag.Parse()
+
+	cfg, err := configuration.LoadFile(configuration.Path(*configPath))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	initializer, err := initialization.NewInitializer(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	logger := initializer.Logger()
+	initializer.Start(ctx)
+	serveErr := initializer.Serve(ctx)
+	if serveErr != nil {
+		logger.Error(serveErr.Error())
+	}
+
+	// the log is closed only after both listeners answered in-flight queries
+	if err := initializer.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	if serveErr != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,291 @@
+// Package configuration loads the server settings from a YAML file.
+package configuration
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/storage/engine/in_memory"
+	"inmem-db-go/internal/database/storage/wal"
+	"io"
+	"os"
+)
+
+// PathEnv names the environment variable with the config path,
+// it is used when the path is not given on the command line.
+const PathEnv = "INMEMDB_CONFIG"
+
+const InMemoryEngine = "in_memory"
+
+const (
+	StderrOutput = "stderr"
+	StdoutOutput = "stdout"
+)
+
+type Config struct {
+	// Path is the file the config was loaded from, empty for the defaults
+	Path string `yaml:"-"`
+
+	Engine      EngineConfig      `yaml:"engine"`
+	Network     NetworkConfig     `yaml:"network"`
+	Query       QueryConfig       `yaml:"query"`
+	WAL         WALConfig         `yaml:"wal"`
+	Snapshots   SnapshotsConfig   `yaml:"snapshots"`
+	Replication ReplicationConfig `yaml:"replication"`
+	Logging     LoggingConfig     `yaml:"logging"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
+}
+
+type EngineConfig struct {
+	Type               string   `yaml:"type"`
+	Shards             int      `yaml:"shards"`
+	MaxMemory          Size     `yaml:"max_memory"`
+	EvictionPolicy     string   `yaml:"eviction_policy"`
+	ExpirationInterval Duration `yaml:"expiration_interval"`
+}
+
+type NetworkConfig struct {
+	Address        string   `yaml:"address"`
+	RESPAddress    string   `yaml:"resp_address"`
+	ReplyFormat    string   `yaml:"reply_format"`
+	MaxConnections int      `yaml:"max_connections"`
+	MaxMessageSize Size     `yaml:"max_message_size"`
+	IdleTimeout    Duration `yaml:"idle_timeout"`
+}
+
+type QueryConfig struct {
+	WordSymbols string `yaml:"word_symbols"`
+}
+
+// WALConfig disables persistence when the directory is empty.
+type WALConfig struct {
+	Directory         string   `yaml:"directory"`
+	FlushStrategy     string   `yaml:"flush_strategy"`
+	FlushBatchSize    Size     `yaml:"flush_batch_size"`
+	FlushBatchTimeout Duration `yaml:"flush_batch_timeout"`
+	MaxSegmentSize    Size     `yaml:"max_segment_size"`
+}
+
+// SnapshotsConfig disables snapshots when the directory is empty
+// and automatic snapshots when the interval is zero.
+type SnapshotsConfig struct {
+	Directory string   `yaml:"directory"`
+	Interval  Duration `yaml:"interval"`
+}
+
+type ReplicationConfig struct {
+	Address      string   `yaml:"address"`
+	ReplicaOf    string   `yaml:"replica_of"`
+	SyncInterval Duration `yaml:"sync_interval"`
+}
+
+// LoggingConfig writes logs to stderr, stdout or a file at the path.
+// A file is rotated once it grows over MaxSize, rotated files are
+// removed after MaxAge or when there are more than MaxBackups of them.
+type LoggingConfig struct {
+	Level      string   `yaml:"level"`
+	Encoding   string   `yaml:"encoding"`
+	Output     string   `yaml:"output"`
+	MaxSize    Size     `yaml:"max_size"`
+	MaxAge     Duration `yaml:"max_age"`
+	MaxBackups int      `yaml:"max_backups"`
+	// queries running longer are logged as slow, zero disables the slow log
+	SlowQueryThreshold Duration `yaml:"slow_query_threshold"`
+}
+
+// MetricsConfig serves metrics in the Prometheus text format at
+// /metrics of the address, metrics are disabled when it is empty.
+type MetricsConfig struct {
+	Address string `yaml:"address"`
+}
+
+// Default returns the settings used for keys missing in the file.
+func Default() *Config {
+	return &Config{
+		Engine: EngineConfig{
+			Type:               InMemoryEngine,
+			Shards:             in_memory.DefaultShardsNumber,
+			EvictionPolicy:     "noeviction",
+			ExpirationInterval: Duration(defaultExpirationInterval),
+		},
+		Network: NetworkConfig{
+			Address:        "localhost:3223",
+			ReplyFormat:    "text",
+			MaxConnections: 100,
+			MaxMessageSize: 4 << 10,
+			IdleTimeout:    Duration(defaultIdleTimeout),
+		},
+		Query: QueryConfig{
+			WordSymbols: compute.DefaultWordSymbols,
+		},
+		WAL: WALConfig{
+			FlushStrategy:     "sync",
+			FlushBatchSize:    64 << 10,
+			FlushBatchTimeout: Duration(defaultFlushBatchTimeout),
+			MaxSegmentSize:    10 << 20,
+		},
+		Replication: ReplicationConfig{
+			SyncInterval: Duration(defaultSyncInterval),
+		},
+		Logging: LoggingConfig{
+			Level:              "info",
+			Encoding:           "json",
+			Output:             StderrOutput,
+			SlowQueryThreshold: Duration(defaultSlowQueryThreshold),
+		},
+	}
+}
+
+// Path returns the config path given on the command line or in PathEnv,
+// an empty path means the defaults.
+func Path(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+
+	return os.Getenv(PathEnv)
+}
+
+// LoadFile loads the config at the path, the defaults are returned for an empty path.
+func LoadFile(path string) (*Config, error) {
+	if path == "" {
+		return Default(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	cfg, err := Load(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+
+	cfg.Path = path
+	return cfg, nil
+}
+
+// Load decodes the config over the defaults and validates it,
+// unknown keys are rejected.
+func Load(reader io.Reader) (*Config, error) {
+	cfg := Default()
+
+	decoder := yaml.NewDecoder(reader)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) Validate() error {
+	if c.Engine.Type != InMemoryEngine {
+		return fmt.Errorf("engine.type %q is invalid, supported: %s", c.Engine.Type, InMemoryEngine)
+	}
+
+	if c.Engine.Shards <= 0 {
+		return errors.New("engine.shards must be positive")
+	}
+
+	if _, err := in_memory.ParseEvictionPolicy(c.Engine.EvictionPolicy); err != nil {
+		return fmt.Errorf("engine.eviction_policy %q is invalid", c.Engine.EvictionPolicy)
+	}
+
+	if c.Engine.ExpirationInterval <= 0 {
+		return errors.New("engine.expiration_interval must be positive")
+	}
+
+	if c.Network.Address == "" {
+		return errors.New("network.address is required")
+	}
+
+	if _, err := result.ParseEncoder(c.Network.ReplyFormat); err != nil {
+		return fmt.Errorf("network.reply_format %q is invalid, supported: text, json", c.Network.ReplyFormat)
+	}
+
+	if c.Network.MaxConnections <= 0 {
+		return errors.New("network.max_connections must be positive")
+	}
+
+	if c.Network.MaxMessageSize <= 0 {
+		return errors.New("network.max_message_size must be positive")
+	}
+
+	if c.Network.IdleTimeout <= 0 {
+		return errors.New("network.idle_timeout must be positive")
+	}
+
+	if _, err := wal.ParseFlushStrategy(c.WAL.FlushStrategy); err != nil {
+		return fmt.Errorf("wal.flush_strategy %q is invalid, supported: sync, size, timeout", c.WAL.FlushStrategy)
+	}
+
+	if c.WAL.FlushBatchSize <= 0 {
+		return errors.New("wal.flush_batch_size must be positive")
+	}
+
+	if c.WAL.FlushBatchTimeout <= 0 {
+		return errors.New("wal.flush_batch_timeout must be positive")
+	}
+
+	if c.WAL.MaxSegmentSize <= 0 {
+		return errors.New("wal.max_segment_size must be positive")
+	}
+
+	if c.Snapshots.Interval < 0 {
+		return errors.New("snapshots.interval must not be negative")
+	}
+
+	if c.Replication.Address != "" && c.WAL.Directory == "" {
+		return errors.New("replication.address requires wal.directory")
+	}
+
+	// a replica restored from a master snapshot restarts its log after the snapshot
+	if c.Replication.ReplicaOf != "" && c.WAL.Directory != "" && c.Snapshots.Directory == "" {
+		return errors.New("replication.replica_of with wal.directory requires snapshots.directory")
+	}
+
+	if c.Replication.SyncInterval <= 0 {
+		return errors.New("replication.sync_interval must be positive")
+	}
+
+	if _, err := zapcore.ParseLevel(c.Logging.Level); err != nil {
+		return fmt.Errorf("logging.level %q is invalid", c.Logging.Level)
+	}
+
+	if c.Logging.Encoding != "json" && c.Logging.Encoding != "console" {
+		return fmt.Errorf("logging.encoding %q is invalid, supported: json, console", c.Logging.Encoding)
+	}
+
+	if c.Logging.Output == "" {
+		return errors.New("logging.output is required")
+	}
+
+	isFile := c.Logging.Output != StderrOutput && c.Logging.Output != StdoutOutput
+	if c.Logging.MaxSize < 0 || c.Logging.MaxAge < 0 || c.Logging.MaxBackups < 0 {
+		return errors.New("logging rotation settings must not be negative")
+	}
+
+	if (c.Logging.MaxSize > 0 || c.Logging.MaxAge > 0 || c.Logging.MaxBackups > 0) && !isFile {
+		return errors.New("logging rotation requires a file output")
+	}
+
+	if (c.Logging.MaxAge > 0 || c.Logging.MaxBackups > 0) && c.Logging.MaxSize == 0 {
+		return errors.New("logging.max_age and logging.max_backups require logging.max_size")
+	}
+
+	if c.Logging.SlowQueryThreshold < 0 {
+		return errors.New("logging.slow_query_threshold must not be negative")
+	}
+
+	return nil
+}
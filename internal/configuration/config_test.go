@@ -0,0 +1,185 @@
+package configuration
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(strings.NewReader(`
+engine:
+  shards: 4
+  max_memory: 512MB
+  eviction_policy: allkeys-lru
+network:
+  address: 0.0.0.0:3223
+  max_message_size: 8kb
+  idle_timeout: 1m
+wal:
+  directory: /var/lib/inmemdb/wal
+  flush_strategy: timeout
+  flush_batch_timeout: 50ms
+logging:
+  level: debug
+  output: stdout
+`))
+	require.NoError(t, err)
+
+	expected := Default()
+	expected.Engine.Shards = 4
+	expected.Engine.MaxMemory = 512 << 20
+	expected.Engine.EvictionPolicy = "allkeys-lru"
+	expected.Network.Address = "0.0.0.0:3223"
+	expected.Network.MaxMessageSize = 8 << 10
+	expected.Network.IdleTimeout = Duration(time.Minute)
+	expected.WAL.Directory = "/var/lib/inmemdb/wal"
+	expected.WAL.FlushStrategy = "timeout"
+	expected.WAL.FlushBatchTimeout = Duration(50 * time.Millisecond)
+	expected.Logging.Level = "debug"
+	expected.Logging.Output = "stdout"
+	assert.Equal(t, expected, cfg)
+}
+
+func TestLoadEmpty(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config string
+		err    string
+	}{
+		"unknown section": {
+			config: "engines:\n  shards: 4\n",
+			err:    "field engines not found",
+		},
+		"unknown key": {
+			config: "engine:\n  shard: 4\n",
+			err:    "field shard not found",
+		},
+		"size unit": {
+			config: "engine:\n  max_memory: 10XB\n",
+			err:    `line 2: size "10XB" is invalid`,
+		},
+		"negative size": {
+			config: "network:\n  max_message_size: -1KB\n",
+			err:    `line 2: size "-1KB" is invalid`,
+		},
+		"duration": {
+			config: "network:\n  idle_timeout: 5 minutes\n",
+			err:    `line 2: duration "5 minutes" is invalid`,
+		},
+		"engine type": {
+			config: "engine:\n  type: on_disk\n",
+			err:    `engine.type "on_disk" is invalid`,
+		},
+		"shards": {
+			config: "engine:\n  shards: 0\n",
+			err:    "engine.shards must be positive",
+		},
+		"flush strategy": {
+			config: "wal:\n  flush_strategy: never\n",
+			err:    `wal.flush_strategy "never" is invalid`,
+		},
+		"logging level": {
+			config: "logging:\n  level: verbose\n",
+			err:    `logging.level "verbose" is invalid`,
+		},
+		"logging encoding": {
+			config: "logging:\n  encoding: text\n",
+			err:    `logging.encoding "text" is invalid`,
+		},
+		"rotation of stderr": {
+			config: "logging:\n  max_size: 10MB\n",
+			err:    "logging rotation requires a file output",
+		},
+		"age without size": {
+			config: "logging:\n  output: /var/log/inmemdb.log\n  max_age: 168h\n",
+			err:    "logging.max_age and logging.max_backups require logging.max_size",
+		},
+		"replication without wal": {
+			config: "replication:\n  address: localhost:3224\n",
+			err:    "replication.address requires wal.directory",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Load(strings.NewReader(test.config))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]Size{
+		"0":      0,
+		"512":    512,
+		"512B":   512,
+		"64KB":   64 << 10,
+		"10 MB":  10 << 20,
+		"2gb":    2 << 30,
+		"":       -1,
+		"MB":     -1,
+		"1.5MB":  -1,
+		"10MiB":  -1,
+		"-10MB":  -1,
+		"9999GB": 9999 << 30,
+	}
+
+	for value, expected := range tests {
+		size, err := ParseSize(value)
+		if expected < 0 {
+			assert.Error(t, err, value)
+		} else {
+			require.NoError(t, err, value)
+			assert.Equal(t, expected, size, value)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadFile("")
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+
+	// the example documents the defaults
+	examplePath := filepath.Join("..", "..", "config.example.yaml")
+	cfg, err = LoadFile(examplePath)
+	require.NoError(t, err)
+
+	expected := Default()
+	expected.Path = examplePath
+	assert.Equal(t, expected, cfg)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("engine:\n  shards: zero\n"), 0o644))
+
+	_, err = LoadFile(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), path)
+
+	_, err = LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
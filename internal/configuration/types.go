@@ -0,0 +1,81 @@
+package configuration
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultExpirationInterval = 100 * time.Millisecond
+	defaultIdleTimeout        = 5 * time.Minute
+	defaultFlushBatchTimeout  = 10 * time.Millisecond
+	defaultSyncInterval       = 100 * time.Millisecond
+	defaultSlowQueryThreshold = 10 * time.Millisecond
+)
+
+// Size is a number of bytes written as a plain integer or with
+// one of the binary units B, KB, MB or GB, e.g. "64KB" or "10MB".
+type Size int64
+
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+func (s *Size) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: size must be a scalar", node.Line)
+	}
+
+	size, err := ParseSize(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+
+	*s = size
+	return nil
+}
+
+// ParseSize parses sizes like "512", "64KB" or "10MB", units are case-insensitive.
+func ParseSize(value string) (Size, error) {
+	number, multiplier := strings.TrimSpace(value), int64(1)
+	upper := strings.ToUpper(number)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(upper, unit.suffix) {
+			number, multiplier = strings.TrimSpace(number[:len(number)-len(unit.suffix)]), unit.multiplier
+			break
+		}
+	}
+
+	parsed, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || parsed < 0 || parsed > (1<<63-1)/multiplier {
+		return 0, fmt.Errorf("size %q is invalid, expected a number with an optional B, KB, MB or GB unit", value)
+	}
+
+	return Size(parsed * multiplier), nil
+}
+
+// Duration is written in the time.ParseDuration format, e.g. "100ms" or "5m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: duration must be a scalar", node.Line)
+	}
+
+	duration, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: duration %q is invalid, expected a value like 100ms or 5m", node.Line, node.Value)
+	}
+
+	*d = Duration(duration)
+	return nil
+}
@@ -4,18 +4,48 @@ import (
 	"context"
 	"errors"
 	"go.uber.org/zap"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/txcontext"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	setQueryArgumentsNumber = 2
-	getQueryArgumentsNumber = 1
-	delQueryArgumentsNumber = 1
+	setQueryArgumentsNumber         = 2
+	getQueryArgumentsNumber         = 1
+	delQueryArgumentsNumber         = 1
+	saveQueryArgumentsNumber        = 0
+	expireQueryArgumentsNumber      = 2
+	ttlQueryArgumentsNumber         = 1
+	persistQueryArgumentsNumber     = 1
+	existsQueryArgumentsNumber      = 1
+	transactionQueryArgumentsNumber = 0
+	replicationQueryArgumentsNumber = 0
+	infoQueryMaxArgumentsNumber     = 1
+	keysQueryArgumentsNumber        = 1
+	dbSizeQueryArgumentsNumber      = 0
+	multiKeyQueryMinArgumentsNumber = 1
+	incrQueryArgumentsNumber        = 1
+	incrByQueryArgumentsNumber      = 2
+	casQueryArgumentsNumber         = 3
+	appendQueryArgumentsNumber      = 2
+	strLenQueryArgumentsNumber      = 1
+	rangeQueryArgumentsNumber       = 3
+	hashQueryArgumentsNumber        = 1
+	hashFieldQueryArgumentsNumber   = 2
+	hSetQueryMinArgumentsNumber     = 3
+	hDelQueryMinArgumentsNumber     = 2
 )
 
 var (
-	errInvalidSymbol    = errors.New("invalid symbol")
-	errInvalidCommand   = errors.New("invalid command")
-	errInvalidArguments = errors.New("invalid arguments")
+	errInvalidSymbol     = result.NewError(result.CodeSyntax, "invalid symbol")
+	errInvalidEscape     = result.NewError(result.CodeSyntax, "invalid escape sequence")
+	errUnterminatedQuote = result.NewError(result.CodeSyntax, "unterminated quote")
+	errInvalidCommand    = result.NewError(result.CodeUnknownCommand, "invalid command")
+	errInvalidArguments  = result.NewError(result.CodeWrongArity, "invalid arguments")
+	errInvalidValue      = result.NewError(result.CodeInvalidArgument, "invalid argument value")
 )
 
 type Analyzer struct {
@@ -33,9 +63,47 @@ func NewAnalyzer(logger *zap.Logger) (*Analyzer, error) {
 	}
 
 	analyser.handlers = []func(context.Context, Query) error{
-		SetCommandID: analyser.analyzeSetQuery,
-		GetCommandID: analyser.analyzeGetQuery,
-		DelCommandID: analyser.analyzeDelQuery,
+		SetCommandID:         analyser.analyzeSetQuery,
+		GetCommandID:         analyser.analyzeGetQuery,
+		DelCommandID:         analyser.analyzeDelQuery,
+		SaveCommandID:        analyser.analyzeSaveQuery,
+		ExpireCommandID:      analyser.analyzeExpireQuery,
+		PExpireCommandID:     analyser.analyzeExpireQuery,
+		TTLCommandID:         analyser.analyzeTTLQuery,
+		PersistCommandID:     analyser.analyzePersistQuery,
+		ExistsCommandID:      analyser.analyzeExistsQuery,
+		BeginCommandID:       analyser.analyzeTransactionQuery,
+		CommitCommandID:      analyser.analyzeTransactionQuery,
+		RollbackCommandID:    analyser.analyzeTransactionQuery,
+		RoleCommandID:        analyser.analyzeReplicationQuery,
+		PromoteCommandID:     analyser.analyzeReplicationQuery,
+		InfoCommandID:        analyser.analyzeInfoQuery,
+		KeysCommandID:        analyser.analyzeKeysQuery,
+		ScanCommandID:        analyser.analyzeScanQuery,
+		DBSizeCommandID:      analyser.analyzeDBSizeQuery,
+		MSetCommandID:        analyser.analyzeMSetQuery,
+		MGetCommandID:        analyser.analyzeMultiKeyQuery,
+		MDelCommandID:        analyser.analyzeMultiKeyQuery,
+		IncrCommandID:        analyser.analyzeIncrQuery,
+		DecrCommandID:        analyser.analyzeIncrQuery,
+		IncrByCommandID:      analyser.analyzeIncrByQuery,
+		DecrByCommandID:      analyser.analyzeIncrByQuery,
+		IncrByFloatCommandID: analyser.analyzeIncrByFloatQuery,
+		SetNXCommandID:       analyser.analyzeGetSetQuery,
+		GetSetCommandID:      analyser.analyzeGetSetQuery,
+		CASCommandID:         analyser.analyzeCASQuery,
+		AppendCommandID:      analyser.analyzeAppendQuery,
+		StrLenCommandID:      analyser.analyzeStrLenQuery,
+		GetRangeCommandID:    analyser.analyzeGetRangeQuery,
+		SetRangeCommandID:    analyser.analyzeSetRangeQuery,
+		HSetCommandID:        analyser.analyzeHSetQuery,
+		HGetCommandID:        analyser.analyzeHashFieldQuery,
+		HDelCommandID:        analyser.analyzeHDelQuery,
+		HGetAllCommandID:     analyser.analyzeHashQuery,
+		HKeysCommandID:       analyser.analyzeHashQuery,
+		HValsCommandID:       analyser.analyzeHashQuery,
+		HLenCommandID:        analyser.analyzeHashQuery,
+		HExistsCommandID:     analyser.analyzeHashFieldQuery,
 	}
 
 	return analyser, nil
@@ -43,7 +111,7 @@ func NewAnalyzer(logger *zap.Logger) (*Analyzer, error) {
 
 func (a *Analyzer) AnalyzeQuery(ctx context.Context, tokens []string) (Query, error) {
 	if len(tokens) == 0 {
-		txID := ctx.Value("tx").(int64)
+		txID := txcontext.TxID(ctx)
 		a.logger.Debug("invalid query", zap.Int64("tx", txID))
 		return Query{}, errInvalidCommand
 	}
@@ -51,7 +119,7 @@ func (a *Analyzer) AnalyzeQuery(ctx context.Context, tokens []string) (Query, er
 	command := tokens[0]
 	commandID := CommandNameToCommandID(command)
 	if commandID == UnknownCommandID {
-		txID := ctx.Value("tx").(int64)
+		txID := txcontext.TxID(ctx)
 		a.logger.Debug(
 			"invalid command",
 			zap.Int64("tx", txID),
@@ -66,7 +134,7 @@ func (a *Analyzer) AnalyzeQuery(ctx context.Context, tokens []string) (Query, er
 		return Query{}, err
 	}
 
-	txID := ctx.Value("tx").(int64)
+	txID := txcontext.TxID(ctx)
 	a.logger.Debug(
 		"query analyzed",
 		zap.Int64("tx", txID),
@@ -77,22 +145,33 @@ func (a *Analyzer) AnalyzeQuery(ctx context.Context, tokens []string) (Query, er
 }
 
 func (a *Analyzer) analyzeSetQuery(ctx context.Context, query Query) error {
-	if len(query.Arguments()) != setQueryArgumentsNumber {
-		txID := ctx.Value("tx").(int64)
+	arguments := query.Arguments()
+	if len(arguments) < setQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
 		a.logger.Debug(
 			"invalid arguments for set query",
 			zap.Int64("tx", txID),
-			zap.Any("args", query.Arguments()),
+			zap.Any("args", arguments),
 		)
 		return errInvalidArguments
 	}
 
+	if _, err := ParseSetOptions(arguments[setQueryArgumentsNumber:]); err != nil {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid options for set query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return err
+	}
+
 	return nil
 }
 
 func (a *Analyzer) analyzeGetQuery(ctx context.Context, query Query) error {
 	if len(query.Arguments()) != getQueryArgumentsNumber {
-		txID := ctx.Value("tx").(int64)
+		txID := txcontext.TxID(ctx)
 		a.logger.Debug(
 			"invalid arguments for get query",
 			zap.Int64("tx", txID),
@@ -106,7 +185,7 @@ func (a *Analyzer) analyzeGetQuery(ctx context.Context, query Query) error {
 
 func (a *Analyzer) analyzeDelQuery(ctx context.Context, query Query) error {
 	if len(query.Arguments()) != delQueryArgumentsNumber {
-		txID := ctx.Value("tx").(int64)
+		txID := txcontext.TxID(ctx)
 		a.logger.Debug(
 			"invalid arguments for del query",
 			zap.Int64("tx", txID),
@@ -117,3 +196,475 @@ func (a *Analyzer) analyzeDelQuery(ctx context.Context, query Query) error {
 
 	return nil
 }
+
+func (a *Analyzer) analyzeSaveQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != saveQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for save query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+func (a *Analyzer) analyzeExpireQuery(ctx context.Context, query Query) error {
+	arguments := query.Arguments()
+	if len(arguments) != expireQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for expire query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidArguments
+	}
+
+	// the timeout must fit into a duration like the SET expiration does
+	unit := int64(time.Second)
+	if query.CommandID() == PExpireCommandID {
+		unit = int64(time.Millisecond)
+	}
+
+	timeout, err := strconv.ParseInt(arguments[1], 10, 64)
+	if err != nil || timeout > math.MaxInt64/unit || timeout < math.MinInt64/unit {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid timeout for expire query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidValue
+	}
+
+	return nil
+}
+
+func (a *Analyzer) analyzeTTLQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != ttlQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for ttl query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+func (a *Analyzer) analyzePersistQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != persistQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for persist query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+func (a *Analyzer) analyzeExistsQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != existsQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for exists query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeTransactionQuery checks BEGIN, COMMIT and ROLLBACK queries.
+func (a *Analyzer) analyzeTransactionQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != transactionQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for transaction query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeReplicationQuery checks ROLE and PROMOTE queries.
+func (a *Analyzer) analyzeReplicationQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != replicationQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for replication query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeInfoQuery accepts an optional section, sections
+// are known only to the database, so the name is not checked.
+func (a *Analyzer) analyzeInfoQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) > infoQueryMaxArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for info query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+func (a *Analyzer) analyzeKeysQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != keysQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for keys query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeScanQuery checks SCAN cursor [MATCH pattern] [COUNT count] queries,
+// the cursor is opaque here and is checked by the storage.
+func (a *Analyzer) analyzeScanQuery(ctx context.Context, query Query) error {
+	arguments := query.Arguments()
+	if len(arguments) == 0 || len(arguments)%2 == 0 {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for scan query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidArguments
+	}
+
+	for i := 1; i < len(arguments); i += 2 {
+		option, value := arguments[i], arguments[i+1]
+		if strings.EqualFold(option, MatchOption) {
+			continue
+		}
+
+		if strings.EqualFold(option, CountOption) {
+			if count, err := strconv.Atoi(value); err == nil && count > 0 {
+				continue
+			}
+		}
+
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid option for scan query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidValue
+	}
+
+	return nil
+}
+
+func (a *Analyzer) analyzeDBSizeQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != dbSizeQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for dbsize query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeMSetQuery checks MSET key value [key value ...] queries.
+func (a *Analyzer) analyzeMSetQuery(ctx context.Context, query Query) error {
+	arguments := query.Arguments()
+	if len(arguments) == 0 || len(arguments)%2 != 0 {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for mset query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeMultiKeyQuery checks MGET and MDEL queries.
+func (a *Analyzer) analyzeMultiKeyQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) < multiKeyQueryMinArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for multi-key query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeIncrQuery checks INCR and DECR queries.
+func (a *Analyzer) analyzeIncrQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != incrQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for incr query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeIncrByQuery checks INCRBY and DECRBY queries.
+func (a *Analyzer) analyzeIncrByQuery(ctx context.Context, query Query) error {
+	arguments := query.Arguments()
+	if len(arguments) != incrByQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for incrby query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidArguments
+	}
+
+	if _, err := strconv.ParseInt(arguments[1], 10, 64); err != nil {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid increment for incrby query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidValue
+	}
+
+	return nil
+}
+
+// analyzeIncrByFloatQuery checks INCRBYFLOAT queries, the increment must be finite.
+func (a *Analyzer) analyzeIncrByFloatQuery(ctx context.Context, query Query) error {
+	arguments := query.Arguments()
+	if len(arguments) != incrByQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for incrbyfloat query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidArguments
+	}
+
+	if increment, err := strconv.ParseFloat(arguments[1], 64); err != nil || math.IsInf(increment, 0) || math.IsNaN(increment) {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid increment for incrbyfloat query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidValue
+	}
+
+	return nil
+}
+
+// analyzeGetSetQuery checks SETNX and GETSET queries, unlike SET they have no options.
+func (a *Analyzer) analyzeGetSetQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != setQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for getset query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeCASQuery checks CAS key expected value queries.
+func (a *Analyzer) analyzeCASQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != casQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for cas query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+func (a *Analyzer) analyzeAppendQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != appendQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for append query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+func (a *Analyzer) analyzeStrLenQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != strLenQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for strlen query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeGetRangeQuery checks GETRANGE key start end queries,
+// negative indices count from the end of the value.
+func (a *Analyzer) analyzeGetRangeQuery(ctx context.Context, query Query) error {
+	arguments := query.Arguments()
+	if len(arguments) != rangeQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for getrange query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidArguments
+	}
+
+	_, startErr := strconv.ParseInt(arguments[1], 10, 64)
+	_, endErr := strconv.ParseInt(arguments[2], 10, 64)
+	if startErr != nil || endErr != nil {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid range for getrange query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidValue
+	}
+
+	return nil
+}
+
+// analyzeSetRangeQuery checks SETRANGE key offset value queries.
+func (a *Analyzer) analyzeSetRangeQuery(ctx context.Context, query Query) error {
+	arguments := query.Arguments()
+	if len(arguments) != rangeQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for setrange query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidArguments
+	}
+
+	if offset, err := strconv.ParseInt(arguments[1], 10, 64); err != nil || offset < 0 {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid offset for setrange query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidValue
+	}
+
+	return nil
+}
+
+// analyzeHSetQuery checks HSET key field value [field value ...] queries.
+func (a *Analyzer) analyzeHSetQuery(ctx context.Context, query Query) error {
+	arguments := query.Arguments()
+	if len(arguments) < hSetQueryMinArgumentsNumber || len(arguments)%2 != 1 {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for hset query",
+			zap.Int64("tx", txID),
+			zap.Any("args", arguments),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeHashFieldQuery checks HGET and HEXISTS queries.
+func (a *Analyzer) analyzeHashFieldQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != hashFieldQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for hash field query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeHDelQuery checks HDEL key field [field ...] queries.
+func (a *Analyzer) analyzeHDelQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) < hDelQueryMinArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for hdel query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
+
+// analyzeHashQuery checks HGETALL, HKEYS, HVALS and HLEN queries.
+func (a *Analyzer) analyzeHashQuery(ctx context.Context, query Query) error {
+	if len(query.Arguments()) != hashQueryArgumentsNumber {
+		txID := txcontext.TxID(ctx)
+		a.logger.Debug(
+			"invalid arguments for hash query",
+			zap.Int64("tx", txID),
+			zap.Any("args", query.Arguments()),
+		)
+		return errInvalidArguments
+	}
+
+	return nil
+}
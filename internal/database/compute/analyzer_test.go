@@ -5,7 +5,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"inmem-db-go/internal/database/txcontext"
 	"testing"
+	"time"
 )
 
 func TestAnalyzeQuery(t *testing.T) {
@@ -33,6 +35,60 @@ func TestAnalyzeQuery(t *testing.T) {
 			query:  NewQuery(DelCommandID, []string{"key"}),
 			err:    nil,
 		},
+		{
+			name:   "valid SET command with expiration",
+			tokens: []string{"SET", "key", "value", "EX", "30"},
+			query:  NewQuery(SetCommandID, []string{"key", "value", "EX", "30"}),
+			err:    nil,
+		},
+		{
+			name:   "valid EXPIRE command",
+			tokens: []string{"EXPIRE", "key", "30"},
+			query:  NewQuery(ExpireCommandID, []string{"key", "30"}),
+			err:    nil,
+		},
+		{
+			name:   "valid PEXPIRE command",
+			tokens: []string{"PEXPIRE", "key", "500"},
+			query:  NewQuery(PExpireCommandID, []string{"key", "500"}),
+			err:    nil,
+		},
+		{
+			name:   "valid TTL command",
+			tokens: []string{"TTL", "key"},
+			query:  NewQuery(TTLCommandID, []string{"key"}),
+			err:    nil,
+		},
+		{
+			name:   "valid PERSIST command",
+			tokens: []string{"PERSIST", "key"},
+			query:  NewQuery(PersistCommandID, []string{"key"}),
+			err:    nil,
+		},
+		{
+			name:   "valid EXISTS command",
+			tokens: []string{"EXISTS", "key"},
+			query:  NewQuery(ExistsCommandID, []string{"key"}),
+			err:    nil,
+		},
+		{
+			name:   "valid BEGIN command",
+			tokens: []string{"BEGIN"},
+			query:  NewQuery(BeginCommandID, []string{}),
+			err:    nil,
+		},
+		{
+			name:   "valid SAVE command",
+			tokens: []string{"SAVE"},
+			query:  NewQuery(SaveCommandID, []string{}),
+			err:    nil,
+		},
+		{
+			name:   "valid SNAPSHOT command",
+			tokens: []string{"SNAPSHOT"},
+			query:  NewQuery(SaveCommandID, []string{}),
+			err:    nil,
+		},
 		{
 			name:   "empty tokens",
 			tokens: []string{},
@@ -58,9 +114,29 @@ func TestAnalyzeQuery(t *testing.T) {
 			tokens: []string{"DEL", "key", "value"},
 			err:    errInvalidArguments,
 		},
+		{
+			name:   "invalid number arguments for SAVE command",
+			tokens: []string{"SAVE", "now"},
+			err:    errInvalidArguments,
+		},
+		{
+			name:   "invalid number arguments for EXISTS command",
+			tokens: []string{"EXISTS"},
+			err:    errInvalidArguments,
+		},
+		{
+			name:   "invalid number arguments for PROMOTE command",
+			tokens: []string{"PROMOTE", "now"},
+			err:    errInvalidArguments,
+		},
+		{
+			name:   "invalid number arguments for COMMIT command",
+			tokens: []string{"COMMIT", "now"},
+			err:    errInvalidArguments,
+		},
 	}
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 	analyzer, err := NewAnalyzer(zap.NewNop())
 	require.NoError(t, err)
 
@@ -98,13 +174,58 @@ func TestAnalyzeSetQuery(t *testing.T) {
 			err:   nil,
 		},
 		{
-			name:  "three argumens",
+			name:  "unknown option",
 			query: NewQuery(SetCommandID, []string{"key", "value", "wrong"}),
+			err:   errInvalidValue,
+		},
+		{
+			name:  "expiration without value",
+			query: NewQuery(SetCommandID, []string{"key", "value", "EX"}),
 			err:   errInvalidArguments,
 		},
+		{
+			name:  "conditional options",
+			query: NewQuery(SetCommandID, []string{"key", "value", "nx", "GET", "PX", "100"}),
+			err:   nil,
+		},
+		{
+			name:  "both conditions",
+			query: NewQuery(SetCommandID, []string{"key", "value", "NX", "XX"}),
+			err:   errInvalidValue,
+		},
+		{
+			name:  "two expirations",
+			query: NewQuery(SetCommandID, []string{"key", "value", "EX", "1", "PX", "100"}),
+			err:   errInvalidValue,
+		},
+		{
+			name:  "expiration in seconds",
+			query: NewQuery(SetCommandID, []string{"key", "value", "EX", "30"}),
+			err:   nil,
+		},
+		{
+			name:  "expiration in milliseconds",
+			query: NewQuery(SetCommandID, []string{"key", "value", "PX", "500"}),
+			err:   nil,
+		},
+		{
+			name:  "unknown option after condition",
+			query: NewQuery(SetCommandID, []string{"key", "value", "XX", "500"}),
+			err:   errInvalidValue,
+		},
+		{
+			name:  "non-numeric expiration",
+			query: NewQuery(SetCommandID, []string{"key", "value", "EX", "soon"}),
+			err:   errInvalidValue,
+		},
+		{
+			name:  "non-positive expiration",
+			query: NewQuery(SetCommandID, []string{"key", "value", "EX", "0"}),
+			err:   errInvalidValue,
+		},
 	}
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 	analyzer, err := NewAnalyzer(zap.NewNop())
 	require.NoError(t, err)
 
@@ -142,7 +263,7 @@ func TestAnalyzeGetQuery(t *testing.T) {
 		},
 	}
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 	analyzer, err := NewAnalyzer(zap.NewNop())
 	require.NoError(t, err)
 
@@ -180,7 +301,7 @@ func TestAnalyzeDelQuery(t *testing.T) {
 		},
 	}
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 	analyzer, err := NewAnalyzer(zap.NewNop())
 	require.NoError(t, err)
 
@@ -194,3 +315,321 @@ func TestAnalyzeDelQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestAnalyzeSaveQuery(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query Query
+		err   error
+	}{
+		{
+			name:  "empty arguments",
+			query: NewQuery(SaveCommandID, []string{}),
+			err:   nil,
+		},
+		{
+			name:  "one argument",
+			query: NewQuery(SaveCommandID, []string{"now"}),
+			err:   errInvalidArguments,
+		},
+	}
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	analyzer, err := NewAnalyzer(zap.NewNop())
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := analyzer.analyzeSaveQuery(ctx, tc.query)
+
+			assert.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func TestAnalyzeExpireQuery(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query Query
+		err   error
+	}{
+		{
+			name:  "valid EXPIRE command",
+			query: NewQuery(ExpireCommandID, []string{"key", "30"}),
+			err:   nil,
+		},
+		{
+			name:  "negative timeout",
+			query: NewQuery(ExpireCommandID, []string{"key", "-1"}),
+			err:   nil,
+		},
+		{
+			name:  "one argument",
+			query: NewQuery(ExpireCommandID, []string{"key"}),
+			err:   errInvalidArguments,
+		},
+		{
+			name:  "non-numeric timeout",
+			query: NewQuery(PExpireCommandID, []string{"key", "soon"}),
+			err:   errInvalidValue,
+		},
+		{
+			name:  "largest timeout",
+			query: NewQuery(ExpireCommandID, []string{"key", "9223372036"}),
+			err:   nil,
+		},
+		{
+			name:  "overflowing timeout",
+			query: NewQuery(ExpireCommandID, []string{"key", "9223372037"}),
+			err:   errInvalidValue,
+		},
+		{
+			name:  "overflowing negative timeout",
+			query: NewQuery(ExpireCommandID, []string{"key", "-9223372037"}),
+			err:   errInvalidValue,
+		},
+		{
+			name:  "overflowing timeout in milliseconds",
+			query: NewQuery(PExpireCommandID, []string{"key", "9223372036855"}),
+			err:   errInvalidValue,
+		},
+	}
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	analyzer, err := NewAnalyzer(zap.NewNop())
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := analyzer.analyzeExpireQuery(ctx, tc.query)
+
+			assert.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func TestAnalyzeTTLAndPersistQuery(t *testing.T) {
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	analyzer, err := NewAnalyzer(zap.NewNop())
+	require.NoError(t, err)
+
+	assert.NoError(t, analyzer.analyzeTTLQuery(ctx, NewQuery(TTLCommandID, []string{"key"})))
+	assert.Equal(t, errInvalidArguments, analyzer.analyzeTTLQuery(ctx, NewQuery(TTLCommandID, []string{})))
+	assert.NoError(t, analyzer.analyzePersistQuery(ctx, NewQuery(PersistCommandID, []string{"key"})))
+	assert.Equal(t, errInvalidArguments, analyzer.analyzePersistQuery(ctx, NewQuery(PersistCommandID, []string{"key", "key"})))
+}
+
+func TestAnalyzeInfoQuery(t *testing.T) {
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	analyzer, err := NewAnalyzer(zap.NewNop())
+	require.NoError(t, err)
+
+	assert.NoError(t, analyzer.analyzeInfoQuery(ctx, NewQuery(InfoCommandID, []string{})))
+	assert.NoError(t, analyzer.analyzeInfoQuery(ctx, NewQuery(InfoCommandID, []string{"memory"})))
+	assert.Equal(t, errInvalidArguments, analyzer.analyzeInfoQuery(ctx, NewQuery(InfoCommandID, []string{"memory", "server"})))
+}
+
+func TestAnalyzeKeysQueries(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	analyzer, err := NewAnalyzer(zap.NewNop())
+	require.NoError(t, err)
+
+	assert.NoError(t, analyzer.analyzeKeysQuery(ctx, NewQuery(KeysCommandID, []string{"user:*"})))
+	assert.Equal(t, errInvalidArguments, analyzer.analyzeKeysQuery(ctx, NewQuery(KeysCommandID, []string{})))
+	assert.NoError(t, analyzer.analyzeDBSizeQuery(ctx, NewQuery(DBSizeCommandID, []string{})))
+	assert.Equal(t, errInvalidArguments, analyzer.analyzeDBSizeQuery(ctx, NewQuery(DBSizeCommandID, []string{"key"})))
+}
+
+func TestAnalyzeScanQuery(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		arguments     []string
+		expectedError error
+	}{
+		{name: "cursor", arguments: []string{"0"}},
+		{name: "match and count", arguments: []string{"0", "match", "user:*", "COUNT", "100"}},
+		{name: "repeated option", arguments: []string{"1_61", "COUNT", "1", "COUNT", "2"}},
+		{name: "no cursor", arguments: []string{}, expectedError: errInvalidArguments},
+		{name: "option without value", arguments: []string{"0", "MATCH"}, expectedError: errInvalidArguments},
+		{name: "unknown option", arguments: []string{"0", "TYPE", "string"}, expectedError: errInvalidValue},
+		{name: "zero count", arguments: []string{"0", "COUNT", "0"}, expectedError: errInvalidValue},
+		{name: "invalid count", arguments: []string{"0", "COUNT", "ten"}, expectedError: errInvalidValue},
+	}
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	analyzer, err := NewAnalyzer(zap.NewNop())
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := analyzer.analyzeScanQuery(ctx, NewQuery(ScanCommandID, tc.arguments))
+			assert.Equal(t, tc.expectedError, err)
+		})
+	}
+}
+
+func TestAnalyzeMultiKeyQueries(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	analyzer, err := NewAnalyzer(zap.NewNop())
+	require.NoError(t, err)
+
+	assert.NoError(t, analyzer.analyzeMSetQuery(ctx, NewQuery(MSetCommandID, []string{"k1", "v1", "k2", "v2"})))
+	assert.Equal(t, errInvalidArguments, analyzer.analyzeMSetQuery(ctx, NewQuery(MSetCommandID, []string{"k1", "v1", "k2"})))
+	assert.Equal(t, errInvalidArguments, analyzer.analyzeMSetQuery(ctx, NewQuery(MSetCommandID, []string{})))
+	assert.NoError(t, analyzer.analyzeMultiKeyQuery(ctx, NewQuery(MGetCommandID, []string{"k1", "k2"})))
+	assert.NoError(t, analyzer.analyzeMultiKeyQuery(ctx, NewQuery(MDelCommandID, []string{"k1"})))
+	assert.Equal(t, errInvalidArguments, analyzer.analyzeMultiKeyQuery(ctx, NewQuery(MDelCommandID, []string{})))
+}
+
+func TestAnalyzeIncrQueries(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		query         Query
+		expectedError error
+	}{
+		{name: "incr", query: NewQuery(IncrCommandID, []string{"key"})},
+		{name: "decr without key", query: NewQuery(DecrCommandID, []string{}), expectedError: errInvalidArguments},
+		{name: "incrby", query: NewQuery(IncrByCommandID, []string{"key", "-5"})},
+		{name: "decrby without increment", query: NewQuery(DecrByCommandID, []string{"key"}), expectedError: errInvalidArguments},
+		{name: "incrby with float", query: NewQuery(IncrByCommandID, []string{"key", "1.5"}), expectedError: errInvalidValue},
+		{name: "incrby overflow", query: NewQuery(IncrByCommandID, []string{"key", "9223372036854775808"}), expectedError: errInvalidValue},
+		{name: "incrbyfloat", query: NewQuery(IncrByFloatCommandID, []string{"key", "-1.5e3"})},
+		{name: "incrbyfloat with infinity", query: NewQuery(IncrByFloatCommandID, []string{"key", "inf"}), expectedError: errInvalidValue},
+		{name: "incrbyfloat with nan", query: NewQuery(IncrByFloatCommandID, []string{"key", "NaN"}), expectedError: errInvalidValue},
+	}
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	analyzer, err := NewAnalyzer(zap.NewNop())
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := analyzer.handlers[tc.query.CommandID()]
+			assert.Equal(t, tc.expectedError, handler(ctx, tc.query))
+		})
+	}
+}
+
+func TestParseSetOptions(t *testing.T) {
+	t.Parallel()
+
+	options, err := ParseSetOptions([]string{"px", "1500", "get", "XX"})
+	require.NoError(t, err)
+	assert.Equal(t, SetOptions{IfExists: true, Get: true, TTL: 1500 * time.Millisecond}, options)
+	assert.True(t, options.Conditional())
+
+	options, err = ParseSetOptions([]string{"EX", "10"})
+	require.NoError(t, err)
+	assert.Equal(t, SetOptions{TTL: 10 * time.Second}, options)
+	assert.False(t, options.Conditional())
+
+	_, err = ParseSetOptions([]string{"EX", "9223372036854775807"})
+	assert.Equal(t, errInvalidValue, err)
+}
+
+func TestAnalyzeConditionalSetQueries(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	analyzer, err := NewAnalyzer(zap.NewNop())
+	require.NoError(t, err)
+
+	assert.NoError(t, analyzer.analyzeGetSetQuery(ctx, NewQuery(SetNXCommandID, []string{"key", "value"})))
+	assert.Equal(t, errInvalidArguments, analyzer.analyzeGetSetQuery(ctx, NewQuery(GetSetCommandID, []string{"key", "value", "NX"})))
+	assert.NoError(t, analyzer.analyzeCASQuery(ctx, NewQuery(CASCommandID, []string{"key", "old", "new"})))
+	assert.Equal(t, errInvalidArguments, analyzer.analyzeCASQuery(ctx, NewQuery(CASCommandID, []string{"key", "old"})))
+}
+
+func TestAnalyzeStringQueries(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		query         Query
+		expectedError error
+	}{
+		{name: "append", query: NewQuery(AppendCommandID, []string{"key", "value"})},
+		{name: "append without value", query: NewQuery(AppendCommandID, []string{"key"}), expectedError: errInvalidArguments},
+		{name: "strlen", query: NewQuery(StrLenCommandID, []string{"key"})},
+		{name: "strlen with two keys", query: NewQuery(StrLenCommandID, []string{"key", "key"}), expectedError: errInvalidArguments},
+		{name: "getrange", query: NewQuery(GetRangeCommandID, []string{"key", "0", "-1"})},
+		{name: "getrange without end", query: NewQuery(GetRangeCommandID, []string{"key", "0"}), expectedError: errInvalidArguments},
+		{name: "getrange with invalid start", query: NewQuery(GetRangeCommandID, []string{"key", "a", "1"}), expectedError: errInvalidValue},
+		{name: "getrange with invalid end", query: NewQuery(GetRangeCommandID, []string{"key", "1", "1.5"}), expectedError: errInvalidValue},
+		{name: "setrange", query: NewQuery(SetRangeCommandID, []string{"key", "5", "value"})},
+		{name: "setrange without value", query: NewQuery(SetRangeCommandID, []string{"key", "5"}), expectedError: errInvalidArguments},
+		{name: "setrange with negative offset", query: NewQuery(SetRangeCommandID, []string{"key", "-1", "value"}), expectedError: errInvalidValue},
+	}
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	analyzer, err := NewAnalyzer(zap.NewNop())
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := analyzer.handlers[tc.query.CommandID()]
+			assert.Equal(t, tc.expectedError, handler(ctx, tc.query))
+		})
+	}
+}
+
+func TestAnalyzeHashQueries(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		query         Query
+		expectedError error
+	}{
+		{name: "hset", query: NewQuery(HSetCommandID, []string{"key", "field", "value"})},
+		{name: "hset with two fields", query: NewQuery(HSetCommandID, []string{"key", "field_1", "value_1", "field_2", "value_2"})},
+		{name: "hset without value", query: NewQuery(HSetCommandID, []string{"key", "field"}), expectedError: errInvalidArguments},
+		{name: "hset with odd pairs", query: NewQuery(HSetCommandID, []string{"key", "field_1", "value_1", "field_2"}), expectedError: errInvalidArguments},
+		{name: "hget", query: NewQuery(HGetCommandID, []string{"key", "field"})},
+		{name: "hget without field", query: NewQuery(HGetCommandID, []string{"key"}), expectedError: errInvalidArguments},
+		{name: "hexists with two fields", query: NewQuery(HExistsCommandID, []string{"key", "field_1", "field_2"}), expectedError: errInvalidArguments},
+		{name: "hdel", query: NewQuery(HDelCommandID, []string{"key", "field_1", "field_2"})},
+		{name: "hdel without field", query: NewQuery(HDelCommandID, []string{"key"}), expectedError: errInvalidArguments},
+		{name: "hgetall", query: NewQuery(HGetAllCommandID, []string{"key"})},
+		{name: "hkeys with two keys", query: NewQuery(HKeysCommandID, []string{"key", "key"}), expectedError: errInvalidArguments},
+		{name: "hvals without key", query: NewQuery(HValsCommandID, nil), expectedError: errInvalidArguments},
+		{name: "hlen", query: NewQuery(HLenCommandID, []string{"key"})},
+	}
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	analyzer, err := NewAnalyzer(zap.NewNop())
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := analyzer.handlers[tc.query.CommandID()]
+			assert.Equal(t, tc.expectedError, handler(ctx, tc.query))
+		})
+	}
+}
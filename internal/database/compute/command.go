@@ -5,6 +5,44 @@ const (
 	SetCommandID
 	GetCommandID
 	DelCommandID
+	SaveCommandID
+	ExpireCommandID
+	PExpireCommandID
+	TTLCommandID
+	PersistCommandID
+	ExistsCommandID
+	BeginCommandID
+	CommitCommandID
+	RollbackCommandID
+	RoleCommandID
+	PromoteCommandID
+	InfoCommandID
+	KeysCommandID
+	ScanCommandID
+	DBSizeCommandID
+	MSetCommandID
+	MGetCommandID
+	MDelCommandID
+	IncrCommandID
+	DecrCommandID
+	IncrByCommandID
+	DecrByCommandID
+	IncrByFloatCommandID
+	SetNXCommandID
+	GetSetCommandID
+	CASCommandID
+	AppendCommandID
+	StrLenCommandID
+	GetRangeCommandID
+	SetRangeCommandID
+	HSetCommandID
+	HGetCommandID
+	HDelCommandID
+	HGetAllCommandID
+	HKeysCommandID
+	HValsCommandID
+	HLenCommandID
+	HExistsCommandID
 )
 
 var (
@@ -12,13 +50,156 @@ var (
 	SetCommand     = "SET"
 	GetCommand     = "GET"
 	DelCommand     = "DEL"
+	SaveCommand    = "SAVE"
+	// SnapshotCommand is an alias of SaveCommand
+	SnapshotCommand = "SNAPSHOT"
+	ExpireCommand   = "EXPIRE"
+	PExpireCommand  = "PEXPIRE"
+	TTLCommand      = "TTL"
+	PersistCommand  = "PERSIST"
+	ExistsCommand   = "EXISTS"
+	BeginCommand    = "BEGIN"
+	CommitCommand   = "COMMIT"
+	RollbackCommand = "ROLLBACK"
+	RoleCommand     = "ROLE"
+	PromoteCommand  = "PROMOTE"
+	InfoCommand     = "INFO"
+	// StatsCommand is an alias of InfoCommand
+	StatsCommand       = "STATS"
+	KeysCommand        = "KEYS"
+	ScanCommand        = "SCAN"
+	DBSizeCommand      = "DBSIZE"
+	MSetCommand        = "MSET"
+	MGetCommand        = "MGET"
+	MDelCommand        = "MDEL"
+	IncrCommand        = "INCR"
+	DecrCommand        = "DECR"
+	IncrByCommand      = "INCRBY"
+	DecrByCommand      = "DECRBY"
+	IncrByFloatCommand = "INCRBYFLOAT"
+	SetNXCommand       = "SETNX"
+	GetSetCommand      = "GETSET"
+	CASCommand         = "CAS"
+	AppendCommand      = "APPEND"
+	StrLenCommand      = "STRLEN"
+	GetRangeCommand    = "GETRANGE"
+	SetRangeCommand    = "SETRANGE"
+	HSetCommand        = "HSET"
+	HGetCommand        = "HGET"
+	HDelCommand        = "HDEL"
+	HGetAllCommand     = "HGETALL"
+	HKeysCommand       = "HKEYS"
+	HValsCommand       = "HVALS"
+	HLenCommand        = "HLEN"
+	HExistsCommand     = "HEXISTS"
+)
+
+// options of SetCommand: SET key value [NX | XX] [GET] [EX seconds | PX milliseconds]
+const (
+	ExpireSecondsOption      = "EX"
+	ExpireMillisecondsOption = "PX"
+	IfMissingOption          = "NX"
+	IfExistsOption           = "XX"
+	GetOption                = "GET"
+)
+
+// options of ScanCommand: SCAN cursor [MATCH pattern] [COUNT count]
+const (
+	MatchOption = "MATCH"
+	CountOption = "COUNT"
 )
 
 var commandNamesToId = map[string]int{
-	UnknownCommand: UnknownCommandID,
-	SetCommand:     SetCommandID,
-	GetCommand:     GetCommandID,
-	DelCommand:     DelCommandID,
+	UnknownCommand:     UnknownCommandID,
+	SetCommand:         SetCommandID,
+	GetCommand:         GetCommandID,
+	DelCommand:         DelCommandID,
+	SaveCommand:        SaveCommandID,
+	SnapshotCommand:    SaveCommandID,
+	ExpireCommand:      ExpireCommandID,
+	PExpireCommand:     PExpireCommandID,
+	TTLCommand:         TTLCommandID,
+	PersistCommand:     PersistCommandID,
+	ExistsCommand:      ExistsCommandID,
+	BeginCommand:       BeginCommandID,
+	CommitCommand:      CommitCommandID,
+	RollbackCommand:    RollbackCommandID,
+	RoleCommand:        RoleCommandID,
+	PromoteCommand:     PromoteCommandID,
+	InfoCommand:        InfoCommandID,
+	StatsCommand:       InfoCommandID,
+	KeysCommand:        KeysCommandID,
+	ScanCommand:        ScanCommandID,
+	DBSizeCommand:      DBSizeCommandID,
+	MSetCommand:        MSetCommandID,
+	MGetCommand:        MGetCommandID,
+	MDelCommand:        MDelCommandID,
+	IncrCommand:        IncrCommandID,
+	DecrCommand:        DecrCommandID,
+	IncrByCommand:      IncrByCommandID,
+	DecrByCommand:      DecrByCommandID,
+	IncrByFloatCommand: IncrByFloatCommandID,
+	SetNXCommand:       SetNXCommandID,
+	GetSetCommand:      GetSetCommandID,
+	CASCommand:         CASCommandID,
+	AppendCommand:      AppendCommandID,
+	StrLenCommand:      StrLenCommandID,
+	GetRangeCommand:    GetRangeCommandID,
+	SetRangeCommand:    SetRangeCommandID,
+	HSetCommand:        HSetCommandID,
+	HGetCommand:        HGetCommandID,
+	HDelCommand:        HDelCommandID,
+	HGetAllCommand:     HGetAllCommandID,
+	HKeysCommand:       HKeysCommandID,
+	HValsCommand:       HValsCommandID,
+	HLenCommand:        HLenCommandID,
+	HExistsCommand:     HExistsCommandID,
+}
+
+// commandIDsToName keeps the canonical name of commands with aliases
+var commandIDsToName = map[int]string{
+	UnknownCommandID:     UnknownCommand,
+	SetCommandID:         SetCommand,
+	GetCommandID:         GetCommand,
+	DelCommandID:         DelCommand,
+	SaveCommandID:        SaveCommand,
+	ExpireCommandID:      ExpireCommand,
+	PExpireCommandID:     PExpireCommand,
+	TTLCommandID:         TTLCommand,
+	PersistCommandID:     PersistCommand,
+	ExistsCommandID:      ExistsCommand,
+	BeginCommandID:       BeginCommand,
+	CommitCommandID:      CommitCommand,
+	RollbackCommandID:    RollbackCommand,
+	RoleCommandID:        RoleCommand,
+	PromoteCommandID:     PromoteCommand,
+	InfoCommandID:        InfoCommand,
+	KeysCommandID:        KeysCommand,
+	ScanCommandID:        ScanCommand,
+	DBSizeCommandID:      DBSizeCommand,
+	MSetCommandID:        MSetCommand,
+	MGetCommandID:        MGetCommand,
+	MDelCommandID:        MDelCommand,
+	IncrCommandID:        IncrCommand,
+	DecrCommandID:        DecrCommand,
+	IncrByCommandID:      IncrByCommand,
+	DecrByCommandID:      DecrByCommand,
+	IncrByFloatCommandID: IncrByFloatCommand,
+	SetNXCommandID:       SetNXCommand,
+	GetSetCommandID:      GetSetCommand,
+	CASCommandID:         CASCommand,
+	AppendCommandID:      AppendCommand,
+	StrLenCommandID:      StrLenCommand,
+	GetRangeCommandID:    GetRangeCommand,
+	SetRangeCommandID:    SetRangeCommand,
+	HSetCommandID:        HSetCommand,
+	HGetCommandID:        HGetCommand,
+	HDelCommandID:        HDelCommand,
+	HGetAllCommandID:     HGetAllCommand,
+	HKeysCommandID:       HKeysCommand,
+	HValsCommandID:       HValsCommand,
+	HLenCommandID:        HLenCommand,
+	HExistsCommandID:     HExistsCommand,
 }
 
 func CommandNameToCommandID(command string) int {
@@ -29,3 +210,12 @@ func CommandNameToCommandID(command string) int {
 
 	return status
 }
+
+func CommandIDToCommandName(commandID int) string {
+	name, found := commandIDsToName[commandID]
+	if !found {
+		return UnknownCommand
+	}
+
+	return name
+}
@@ -14,6 +14,46 @@ func TestNameToID(t *testing.T) {
 		{"set command", SetCommandID, "SET"},
 		{"get command", GetCommandID, "GET"},
 		{"del command", DelCommandID, "DEL"},
+		{"save command", SaveCommandID, "SAVE"},
+		{"snapshot command", SaveCommandID, "SNAPSHOT"},
+		{"expire command", ExpireCommandID, "EXPIRE"},
+		{"pexpire command", PExpireCommandID, "PEXPIRE"},
+		{"ttl command", TTLCommandID, "TTL"},
+		{"persist command", PersistCommandID, "PERSIST"},
+		{"exists command", ExistsCommandID, "EXISTS"},
+		{"begin command", BeginCommandID, "BEGIN"},
+		{"commit command", CommitCommandID, "COMMIT"},
+		{"rollback command", RollbackCommandID, "ROLLBACK"},
+		{"role command", RoleCommandID, "ROLE"},
+		{"promote command", PromoteCommandID, "PROMOTE"},
+		{"info command", InfoCommandID, "INFO"},
+		{"stats command", InfoCommandID, "STATS"},
+		{"keys command", KeysCommandID, "KEYS"},
+		{"scan command", ScanCommandID, "SCAN"},
+		{"dbsize command", DBSizeCommandID, "DBSIZE"},
+		{"mset command", MSetCommandID, "MSET"},
+		{"mget command", MGetCommandID, "MGET"},
+		{"mdel command", MDelCommandID, "MDEL"},
+		{"incr command", IncrCommandID, "INCR"},
+		{"decr command", DecrCommandID, "DECR"},
+		{"incrby command", IncrByCommandID, "INCRBY"},
+		{"decrby command", DecrByCommandID, "DECRBY"},
+		{"incrbyfloat command", IncrByFloatCommandID, "INCRBYFLOAT"},
+		{"setnx command", SetNXCommandID, "SETNX"},
+		{"getset command", GetSetCommandID, "GETSET"},
+		{"cas command", CASCommandID, "CAS"},
+		{"append command", AppendCommandID, "APPEND"},
+		{"strlen command", StrLenCommandID, "STRLEN"},
+		{"getrange command", GetRangeCommandID, "GETRANGE"},
+		{"setrange command", SetRangeCommandID, "SETRANGE"},
+		{"hset command", HSetCommandID, "HSET"},
+		{"hget command", HGetCommandID, "HGET"},
+		{"hdel command", HDelCommandID, "HDEL"},
+		{"hgetall command", HGetAllCommandID, "HGETALL"},
+		{"hkeys command", HKeysCommandID, "HKEYS"},
+		{"hvals command", HValsCommandID, "HVALS"},
+		{"hlen command", HLenCommandID, "HLEN"},
+		{"hexists command", HExistsCommandID, "HEXISTS"},
 		{"unknown command", UnknownCommandID, "DROP"},
 	}
 	for _, tc := range testCases {
@@ -24,3 +64,22 @@ func TestNameToID(t *testing.T) {
 		})
 	}
 }
+
+func TestIDToName(t *testing.T) {
+	t.Parallel()
+
+	for name, commandID := range commandNamesToId {
+		switch name {
+		case SnapshotCommand:
+			assert.Equal(t, SaveCommand, CommandIDToCommandName(commandID))
+			continue
+		case StatsCommand:
+			assert.Equal(t, InfoCommand, CommandIDToCommandName(commandID))
+			continue
+		}
+
+		assert.Equal(t, name, CommandIDToCommandName(commandID))
+	}
+
+	assert.Equal(t, UnknownCommand, CommandIDToCommandName(-1))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"go.uber.org/zap"
+	"inmem-db-go/internal/database/txcontext"
 )
 
 type parser interface {
@@ -42,7 +43,7 @@ func NewCompute(parser parser, analyzer analyzer, logger *zap.Logger) (*Compute,
 
 func (d *Compute) HandleQuery(ctx context.Context, queryStr string) (Query, error) {
 	if ctx.Err() != nil {
-		txID := ctx.Value("tx").(int64)
+		txID := txcontext.TxID(ctx)
 		d.logger.Debug("query canceled", zap.Int64("tx", txID))
 		return Query{}, ctx.Err()
 	}
@@ -59,3 +60,15 @@ func (d *Compute) HandleQuery(ctx context.Context, queryStr string) (Query, erro
 
 	return query, nil
 }
+
+// HandleTokens analyzes a query that is already split into tokens,
+// such as a command decoded from a binary protocol.
+func (d *Compute) HandleTokens(ctx context.Context, tokens []string) (Query, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		d.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return Query{}, ctx.Err()
+	}
+
+	return d.analyzer.AnalyzeQuery(ctx, tokens)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"inmem-db-go/internal/database/txcontext"
 	"testing"
 )
 
@@ -37,7 +38,7 @@ func TestNewCompute(t *testing.T) {
 func TestHandleQueryWithCancel(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	ctrl := gomock.NewController(t)
 	parser := NewMockparser(ctrl)
@@ -57,7 +58,7 @@ func TestHandleQueryWithCancel(t *testing.T) {
 func TestHandleQueryWithParsingError(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	ctrl := gomock.NewController(t)
 	parser := NewMockparser(ctrl)
@@ -77,7 +78,7 @@ func TestHandleQueryWithParsingError(t *testing.T) {
 func TestHandleQueryWithAnalyzingError(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	ctrl := gomock.NewController(t)
 	parser := NewMockparser(ctrl)
@@ -100,7 +101,7 @@ func TestHandleQueryWithAnalyzingError(t *testing.T) {
 func TestHandleQuery(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	ctrl := gomock.NewController(t)
 	parser := NewMockparser(ctrl)
@@ -119,3 +120,23 @@ func TestHandleQuery(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, NewQuery(GetCommandID, []string{"key"}), query)
 }
+
+func TestHandleTokens(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	parser := NewMockparser(ctrl)
+	analyzer := NewMockanalyzer(ctrl)
+	analyzer.EXPECT().
+		AnalyzeQuery(ctx, []string{"SET", "key", "hello world"}).
+		Return(NewQuery(SetCommandID, []string{"key", "hello world"}), nil)
+
+	compute, err := NewCompute(parser, analyzer, zap.NewNop())
+	require.NoError(t, err)
+
+	query, err := compute.HandleTokens(ctx, []string{"SET", "key", "hello world"})
+	require.NoError(t, err)
+	require.Equal(t, NewQuery(SetCommandID, []string{"key", "hello world"}), query)
+}
@@ -3,31 +3,82 @@ package compute
 import (
 	"context"
 	"errors"
+	"fmt"
 	"go.uber.org/zap"
+	"inmem-db-go/internal/database/txcontext"
 )
 
+// DefaultWordSymbols are allowed in unquoted words
+// besides letters, digits and underscore.
+const DefaultWordSymbols = "*/.:-"
+
+// SyntaxError reports the byte offset and the symbol where the query parsing failed,
+// for an unterminated quote it is the offset of the opening quote.
+type SyntaxError struct {
+	Err    error
+	Offset int
+	Symbol byte
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s %q at offset %d", e.Err.Error(), e.Symbol, e.Offset)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+type ParserOption func(*Parser)
+
+// WithWordSymbols replaces the symbols allowed in unquoted words besides
+// letters, digits and underscore, quoted tokens may contain any bytes.
+func WithWordSymbols(symbols string) ParserOption {
+	return func(parser *Parser) {
+		parser.wordSymbols = [256]bool{}
+		for i := 0; i < len(symbols); i++ {
+			parser.wordSymbols[symbols[i]] = true
+		}
+	}
+}
+
 type Parser struct {
-	logger *zap.Logger
+	wordSymbols [256]bool
+	logger      *zap.Logger
 }
 
-func NewParser(logger *zap.Logger) (*Parser, error) {
+func NewParser(logger *zap.Logger, options ...ParserOption) (*Parser, error) {
 	if logger == nil {
 		return nil, errors.New("logger is invalid")
 	}
 
-	return &Parser{
+	parser := &Parser{
 		logger: logger,
-	}, nil
+	}
+
+	WithWordSymbols(DefaultWordSymbols)(parser)
+	for _, option := range options {
+		option(parser)
+	}
+
+	for _, symbol := range []byte{' ', '\t', '\n', '"', '\'', '\\'} {
+		if parser.wordSymbols[symbol] {
+			return nil, errors.New("word symbols are invalid")
+		}
+	}
+
+	return parser, nil
 }
 
 func (p *Parser) ParseQuery(ctx context.Context, query string) ([]string, error) {
-	machine := newStateMachine()
+	machine := newStateMachine(p.isLetter)
 	tokens, err := machine.parse(query)
 	if err != nil {
+		txID := txcontext.TxID(ctx)
+		p.logger.Debug("invalid query", zap.Int64("tx", txID), zap.Error(err))
 		return nil, err
 	}
 
-	txID := ctx.Value("tx").(int64)
+	txID := txcontext.TxID(ctx)
 	p.logger.Debug(
 		"query parsed",
 		zap.Int64("tx", txID),
@@ -37,10 +88,18 @@ func (p *Parser) ParseQuery(ctx context.Context, query string) ([]string, error)
 	return tokens, nil
 }
 
+func (p *Parser) isLetter(symbol byte) bool {
+	return isLetter(symbol) || p.wordSymbols[symbol]
+}
+
 func isWhiteSpace(symbol byte) bool {
 	return symbol == '\t' || symbol == '\n' || symbol == ' '
 }
 
+func isQuote(symbol byte) bool {
+	return symbol == '"' || symbol == '\''
+}
+
 func isLetter(symbol byte) bool {
 	return (symbol >= 'a' && symbol <= 'z') ||
 		(symbol >= 'A' && symbol <= 'Z') ||
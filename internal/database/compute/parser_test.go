@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"inmem-db-go/internal/database/txcontext"
 	"testing"
 )
 
@@ -45,8 +46,32 @@ func TestParser(t *testing.T) {
 			expectedError: nil, expectedTokens: nil},
 		{name: "empty tokens command", query: " ",
 			expectedError: nil, expectedTokens: nil},
+		{name: "punctuation in words", query: "SET user:1 http://host/a.b-c*",
+			expectedError: nil, expectedTokens: []string{"SET", "user:1", "http://host/a.b-c*"}},
+		{name: "double quoted value", query: `SET a "hello world"`,
+			expectedError: nil, expectedTokens: []string{"SET", "a", "hello world"}},
+		{name: "single quoted value", query: `SET a '{"key": [1, 2]}'`,
+			expectedError: nil, expectedTokens: []string{"SET", "a", `{"key": [1, 2]}`}},
+		{name: "empty quoted value", query: `SET a ""`,
+			expectedError: nil, expectedTokens: []string{"SET", "a", ""}},
+		{name: "escapes", query: `SET a "\n\t\r\"\'\\\x00\xfF"`,
+			expectedError: nil, expectedTokens: []string{"SET", "a", "\n\t\r\"'\\\x00\xff"}},
+		{name: "non-ascii quoted value", query: `SET a "Б %"`,
+			expectedError: nil, expectedTokens: []string{"SET", "a", "Б %"}},
+		{name: "unterminated quote", query: `SET a "value`,
+			expectedError: errUnterminatedQuote, expectedTokens: nil},
+		{name: "unterminated escape", query: `SET a "value\`,
+			expectedError: errUnterminatedQuote, expectedTokens: nil},
+		{name: "invalid escape", query: `SET a "\q"`,
+			expectedError: errInvalidEscape, expectedTokens: nil},
+		{name: "invalid hex escape", query: `SET a "\x4g"`,
+			expectedError: errInvalidEscape, expectedTokens: nil},
+		{name: "quote inside word", query: `SET a b"c"`,
+			expectedError: errInvalidSymbol, expectedTokens: nil},
+		{name: "symbol after closing quote", query: `SET a "b"c`,
+			expectedError: errInvalidSymbol, expectedTokens: nil},
 	}
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
@@ -55,8 +80,45 @@ func TestParser(t *testing.T) {
 			require.NoError(t, err)
 
 			tokens, err := parser.ParseQuery(ctx, tc.query)
-			assert.Equal(t, tc.expectedError, err)
+			assert.ErrorIs(t, err, tc.expectedError)
 			assert.Equal(t, tc.expectedTokens, tokens)
 		})
 	}
 }
+
+func TestSyntaxError(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	parser, err := NewParser(zap.NewNop())
+	require.NoError(t, err)
+
+	_, err = parser.ParseQuery(ctx, "SET a %")
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, 6, syntaxErr.Offset)
+	assert.Equal(t, byte('%'), syntaxErr.Symbol)
+	assert.Equal(t, "invalid symbol '%' at offset 6", err.Error())
+
+	_, err = parser.ParseQuery(ctx, `GET 'key`)
+	assert.Equal(t, `unterminated quote '\'' at offset 4`, err.Error())
+}
+
+func TestWordSymbols(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	parser, err := NewParser(zap.NewNop(), WithWordSymbols("%"))
+	require.NoError(t, err)
+
+	tokens, err := parser.ParseQuery(ctx, "SET a_1 100%")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SET", "a_1", "100%"}, tokens)
+
+	_, err = parser.ParseQuery(ctx, "SET a b.c")
+	assert.ErrorIs(t, err, errInvalidSymbol)
+
+	_, err = NewParser(zap.NewNop(), WithWordSymbols("\"\\"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,64 @@
+package compute
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SetOptions are the options of SET key value [NX | XX] [GET] [EX seconds | PX milliseconds].
+type SetOptions struct {
+	// IfMissing and IfExists make the write conditional
+	IfMissing bool
+	IfExists  bool
+	// Get replies with the previous value
+	Get bool
+	// TTL is zero for keys without expiration
+	TTL time.Duration
+}
+
+// Conditional reports whether the write depends on the current value of the key.
+func (o SetOptions) Conditional() bool {
+	return o.IfMissing || o.IfExists || o.Get
+}
+
+// ParseSetOptions parses the arguments of SET following the key and the value,
+// options are case-insensitive and may go in any order.
+func ParseSetOptions(options []string) (SetOptions, error) {
+	var parsed SetOptions
+	for i := 0; i < len(options); i++ {
+		switch option := strings.ToUpper(options[i]); option {
+		case IfMissingOption, IfExistsOption:
+			if parsed.IfMissing || parsed.IfExists {
+				return SetOptions{}, errInvalidValue
+			}
+
+			parsed.IfMissing = option == IfMissingOption
+			parsed.IfExists = option == IfExistsOption
+		case GetOption:
+			parsed.Get = true
+		case ExpireSecondsOption, ExpireMillisecondsOption:
+			if i+1 == len(options) {
+				return SetOptions{}, errInvalidArguments
+			}
+
+			unit := time.Second
+			if option == ExpireMillisecondsOption {
+				unit = time.Millisecond
+			}
+
+			i++
+			ttl, err := strconv.ParseInt(options[i], 10, 64)
+			if err != nil || ttl <= 0 || ttl > math.MaxInt64/int64(unit) || parsed.TTL != 0 {
+				return SetOptions{}, errInvalidValue
+			}
+
+			parsed.TTL = time.Duration(ttl) * unit
+		default:
+			return SetOptions{}, errInvalidValue
+		}
+	}
+
+	return parsed, nil
+}
@@ -7,6 +7,9 @@ import (
 const (
 	foundLetterEvent = iota
 	foundWhiteSpaceEvent
+	foundQuoteEvent
+	foundBackslashEvent
+	foundOtherEvent
 	// must be last
 	eventsNumber
 )
@@ -15,6 +18,10 @@ const (
 	initialState = iota
 	wordState
 	whiteSpaceState
+	quotedState
+	escapeState
+	hexEscapeState
+	closedQuoteState
 	invalidState
 	// must be last
 	statesNumber
@@ -28,28 +35,76 @@ type transition struct {
 type stateMachine struct {
 	transitions [statesNumber][eventsNumber]transition
 	state       int
+	isLetter    func(byte) bool
 
 	tokens []string
 	sb     strings.Builder
+
+	// quote opening the current quoted token and its offset
+	quote       byte
+	quoteOffset int
+	// value of the \xHH escape being read and the number of its digits
+	hexValue  byte
+	hexDigits int
+	// reason of switching to the invalid state
+	err error
 }
 
-func newStateMachine() *stateMachine {
+func newStateMachine(isLetter func(byte) bool) *stateMachine {
 	machine := &stateMachine{
-		state: initialState,
+		state:    initialState,
+		isLetter: isLetter,
 	}
 
 	machine.transitions = [statesNumber][eventsNumber]transition{
 		initialState: {
 			foundLetterEvent:     transition{jump: machine.appendLetterJump},
 			foundWhiteSpaceEvent: transition{jump: machine.skipWhiteSpaceJump},
+			foundQuoteEvent:      transition{jump: machine.openQuoteJump},
+			foundBackslashEvent:  transition{jump: machine.invalidSymbolJump},
+			foundOtherEvent:      transition{jump: machine.invalidSymbolJump},
 		},
 		wordState: {
 			foundLetterEvent:     transition{jump: machine.appendLetterJump},
 			foundWhiteSpaceEvent: transition{jump: machine.skipWhiteSpaceJump, action: machine.addTokenAction},
+			foundQuoteEvent:      transition{jump: machine.invalidSymbolJump},
+			foundBackslashEvent:  transition{jump: machine.invalidSymbolJump},
+			foundOtherEvent:      transition{jump: machine.invalidSymbolJump},
 		},
 		whiteSpaceState: {
 			foundLetterEvent:     transition{jump: machine.appendLetterJump},
 			foundWhiteSpaceEvent: transition{jump: machine.skipWhiteSpaceJump},
+			foundQuoteEvent:      transition{jump: machine.openQuoteJump},
+			foundBackslashEvent:  transition{jump: machine.invalidSymbolJump},
+			foundOtherEvent:      transition{jump: machine.invalidSymbolJump},
+		},
+		quotedState: {
+			foundLetterEvent:     transition{jump: machine.appendQuotedJump},
+			foundWhiteSpaceEvent: transition{jump: machine.appendQuotedJump},
+			foundQuoteEvent:      transition{jump: machine.closeQuoteJump},
+			foundBackslashEvent:  transition{jump: machine.startEscapeJump},
+			foundOtherEvent:      transition{jump: machine.appendQuotedJump},
+		},
+		escapeState: {
+			foundLetterEvent:     transition{jump: machine.escapeJump},
+			foundWhiteSpaceEvent: transition{jump: machine.escapeJump},
+			foundQuoteEvent:      transition{jump: machine.escapeJump},
+			foundBackslashEvent:  transition{jump: machine.escapeJump},
+			foundOtherEvent:      transition{jump: machine.escapeJump},
+		},
+		hexEscapeState: {
+			foundLetterEvent:     transition{jump: machine.hexDigitJump},
+			foundWhiteSpaceEvent: transition{jump: machine.hexDigitJump},
+			foundQuoteEvent:      transition{jump: machine.hexDigitJump},
+			foundBackslashEvent:  transition{jump: machine.hexDigitJump},
+			foundOtherEvent:      transition{jump: machine.hexDigitJump},
+		},
+		closedQuoteState: {
+			foundLetterEvent:     transition{jump: machine.invalidSymbolJump},
+			foundWhiteSpaceEvent: transition{jump: machine.skipWhiteSpaceJump},
+			foundQuoteEvent:      transition{jump: machine.invalidSymbolJump},
+			foundBackslashEvent:  transition{jump: machine.invalidSymbolJump},
+			foundOtherEvent:      transition{jump: machine.invalidSymbolJump},
 		},
 		invalidState: {},
 	}
@@ -60,15 +115,32 @@ func newStateMachine() *stateMachine {
 func (sm *stateMachine) parse(query string) ([]string, error) {
 	for i := 0; i < len(query); i++ {
 		symbol := query[i]
-		if isWhiteSpace(symbol) {
+		switch {
+		case isWhiteSpace(symbol):
 			sm.processEvent(foundWhiteSpaceEvent, symbol)
-		} else if isLetter(symbol) {
+		case isQuote(symbol):
+			if sm.state == initialState || sm.state == whiteSpaceState {
+				sm.quoteOffset = i
+			}
+			sm.processEvent(foundQuoteEvent, symbol)
+		case symbol == '\\':
+			sm.processEvent(foundBackslashEvent, symbol)
+		case sm.isLetter(symbol):
 			sm.processEvent(foundLetterEvent, symbol)
-		} else {
-			return nil, errInvalidSymbol
+		default:
+			sm.processEvent(foundOtherEvent, symbol)
+		}
+
+		if sm.state == invalidState {
+			return nil, &SyntaxError{Err: sm.err, Offset: i, Symbol: symbol}
 		}
 	}
 
+	switch sm.state {
+	case quotedState, escapeState, hexEscapeState:
+		return nil, &SyntaxError{Err: errUnterminatedQuote, Offset: sm.quoteOffset, Symbol: sm.quote}
+	}
+
 	sm.processEvent(foundWhiteSpaceEvent, ' ')
 	return sm.tokens, nil
 }
@@ -90,6 +162,84 @@ func (sm *stateMachine) skipWhiteSpaceJump(byte) int {
 	return whiteSpaceState
 }
 
+func (sm *stateMachine) invalidSymbolJump(byte) int {
+	sm.err = errInvalidSymbol
+	return invalidState
+}
+
+func (sm *stateMachine) openQuoteJump(quote byte) int {
+	sm.quote = quote
+	return quotedState
+}
+
+func (sm *stateMachine) appendQuotedJump(symbol byte) int {
+	sm.sb.WriteByte(symbol)
+	return quotedState
+}
+
+// closeQuoteJump ends the token on the opening quote,
+// the other quote kind is a regular symbol inside it.
+func (sm *stateMachine) closeQuoteJump(quote byte) int {
+	if quote != sm.quote {
+		sm.sb.WriteByte(quote)
+		return quotedState
+	}
+
+	sm.addTokenAction()
+	return closedQuoteState
+}
+
+func (sm *stateMachine) startEscapeJump(byte) int {
+	return escapeState
+}
+
+func (sm *stateMachine) escapeJump(symbol byte) int {
+	switch symbol {
+	case 'n':
+		sm.sb.WriteByte('\n')
+	case 't':
+		sm.sb.WriteByte('\t')
+	case 'r':
+		sm.sb.WriteByte('\r')
+	case '0':
+		sm.sb.WriteByte(0)
+	case '\\', '"', '\'':
+		sm.sb.WriteByte(symbol)
+	case 'x':
+		sm.hexValue, sm.hexDigits = 0, 0
+		return hexEscapeState
+	default:
+		sm.err = errInvalidEscape
+		return invalidState
+	}
+
+	return quotedState
+}
+
+func (sm *stateMachine) hexDigitJump(symbol byte) int {
+	var digit byte
+	switch {
+	case symbol >= '0' && symbol <= '9':
+		digit = symbol - '0'
+	case symbol >= 'a' && symbol <= 'f':
+		digit = symbol - 'a' + 10
+	case symbol >= 'A' && symbol <= 'F':
+		digit = symbol - 'A' + 10
+	default:
+		sm.err = errInvalidEscape
+		return invalidState
+	}
+
+	sm.hexValue = sm.hexValue<<4 | digit
+	sm.hexDigits++
+	if sm.hexDigits < 2 {
+		return hexEscapeState
+	}
+
+	sm.sb.WriteByte(sm.hexValue)
+	return quotedState
+}
+
 func (sm *stateMachine) addTokenAction() {
 	sm.tokens = append(sm.tokens, sm.sb.String())
 	sm.sb.Reset()
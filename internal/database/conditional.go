@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/storage"
+	"time"
+)
+
+// executeConditionalSetQuery handles SET with NX, XX or GET options. It replies
+// with the previous value for GET and with OK or not found otherwise, not found
+// means that the condition didn't hold and nothing was written.
+func (d *Database) executeConditionalSetQuery(ctx context.Context, key, value string, options compute.SetOptions) result.Result {
+	previous, written, err := d.setIf(ctx, key, value, options)
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	if options.Get {
+		if previous == nil {
+			return result.NotFound()
+		}
+
+		return result.String(*previous)
+	}
+
+	if !written {
+		return result.NotFound()
+	}
+
+	return result.OK()
+}
+
+// executeSetNXQuery replies with 1 if the key was missing and is set now, 0 otherwise.
+func (d *Database) executeSetNXQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	_, written, err := d.setIf(ctx, arguments[0], arguments[1], compute.SetOptions{IfMissing: true})
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.Bool(written)
+}
+
+// executeGetSetQuery sets the key and replies with its previous value.
+func (d *Database) executeGetSetQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	return d.executeConditionalSetQuery(ctx, arguments[0], arguments[1], compute.SetOptions{Get: true})
+}
+
+// executeCASQuery sets the key only if its current value equals the expected one
+// and replies with 1 if it was written. The key keeps its expiration.
+func (d *Database) executeCASQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	key, expected, value := arguments[0], arguments[1], arguments[2]
+	written, err := d.storageLayer.Update(ctx, key, func(current string, found bool, expiresAt time.Time) (string, time.Time, error) {
+		if !found || current != expected {
+			return "", time.Time{}, storage.ErrSkipUpdate
+		}
+
+		return value, expiresAt, nil
+	})
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.Bool(written)
+}
+
+// setIf writes the key like SET with the options atomically, it returns
+// the previous value, nil for a missing key, and whether the key was written.
+// Without GET keys of any type count as existing and are replaced like SET
+// replaces them, the previous value is read for GET, so it needs a string.
+func (d *Database) setIf(ctx context.Context, key, value string, options compute.SetOptions) (*string, bool, error) {
+	var expiresAt time.Time
+	if options.TTL != 0 {
+		expiresAt = time.Now().Add(options.TTL)
+	}
+
+	if !options.Get {
+		written, err := d.storageLayer.Replace(ctx, key, func(exists bool) (string, time.Time, error) {
+			if options.IfMissing && exists || options.IfExists && !exists {
+				return "", time.Time{}, storage.ErrSkipUpdate
+			}
+
+			return value, expiresAt, nil
+		})
+
+		return nil, written, err
+	}
+
+	var previous *string
+	written, err := d.storageLayer.Update(ctx, key, func(current string, found bool, _ time.Time) (string, time.Time, error) {
+		if found {
+			previous = &current
+		}
+
+		if options.IfMissing && found || options.IfExists && !found {
+			return "", time.Time{}, storage.ErrSkipUpdate
+		}
+
+		return value, expiresAt, nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return previous, written, nil
+}
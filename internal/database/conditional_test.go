@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestConditionalSetQueries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	database := newTestDatabase(t)
+
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "SET lock owner_1 NX", expected: "[ok]"},
+		{query: "SET lock owner_2 NX", expected: "[not found]"},
+		{query: "GET lock", expected: "[ok] owner_1"},
+		{query: "SET missing value XX", expected: "[not found]"},
+		{query: "EXISTS missing", expected: "[ok] 0"},
+		{query: "SET lock owner_2 XX GET", expected: "[ok] owner_1"},
+		{query: "SET fresh value NX GET", expected: "[not found]"},
+		{query: "GET fresh", expected: "[ok] value"},
+		{query: "SET lock owner_3 GET EX 100", expected: "[ok] owner_2"},
+		{query: "TTL lock", expected: "[ok] 100"},
+		{query: "SETNX lock owner_4", expected: "[ok] 0"},
+		{query: "SETNX other owner_4", expected: "[ok] 1"},
+		{query: "GETSET lock owner_5", expected: "[ok] owner_3"},
+		{query: "TTL lock", expected: "[ok] -1"},
+		{query: "GETSET absent value", expected: "[not found]"},
+		{query: "SET counter 1 EX 100", expected: "[ok]"},
+		{query: "CAS counter 2 3", expected: "[ok] 0"},
+		{query: "CAS counter 1 3", expected: "[ok] 1"},
+		{query: "GET counter", expected: "[ok] 3"},
+		{query: "TTL counter", expected: "[ok] 100"},
+		{query: "CAS nothing 1 2", expected: "[ok] 0"},
+		{query: "SET lock owner NX XX", expected: "[error] invalid argument value"},
+	}
+
+	// queries depend on each other, so they run one by one
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, database.HandleQuery(ctx, tc.query), tc.query)
+	}
+}
+
+func TestConditionalSetOnOtherTypes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	database := newTestDatabase(t)
+
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "HSET hash field value", expected: "[ok] 1"},
+		{query: "SET hash value NX", expected: "[not found]"},
+		{query: "SETNX hash value", expected: "[ok] 0"},
+		{query: "HGET hash field", expected: "[ok] value"},
+		{query: "SET hash value XX GET", expected: "[error] operation against a key holding the wrong kind of value"},
+		{query: "SET hash string XX EX 100", expected: "[ok]"},
+		{query: "GET hash", expected: "[ok] string"},
+		{query: "TTL hash", expected: "[ok] 100"},
+		{query: "HLEN hash", expected: "[error] operation against a key holding the wrong kind of value"},
+	}
+
+	// queries depend on each other, so they run one by one
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, database.HandleQuery(ctx, tc.query), tc.query)
+	}
+}
+
+func TestConcurrentSetNX(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	database := newTestDatabase(t)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	acquired := 0
+	for i := 0; i < 20; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if database.HandleQuery(ctx, fmt.Sprintf("SETNX lock owner_%d", i)) == "[ok] 1" {
+				mutex.Lock()
+				acquired++
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	require.Equal(t, 1, acquired)
+}
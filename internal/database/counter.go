@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/result"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	errNotInteger = result.NewError(result.CodeInvalidArgument, "value is not an integer or out of range")
+	errNotFloat   = result.NewError(result.CodeInvalidArgument, "value is not a valid float")
+	errOverflow   = result.NewError(result.CodeInvalidArgument, "increment or decrement would overflow")
+)
+
+// executeCounterQuery applies INCR, DECR, INCRBY, DECRBY and INCRBYFLOAT
+// to the stored value atomically and replies with the new value. Missing
+// keys start at zero, the key keeps its expiration.
+func (d *Database) executeCounterQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	update := incrementBy(1)
+	switch query.CommandID() {
+	case compute.DecrCommandID:
+		update = incrementBy(-1)
+	case compute.IncrByCommandID:
+		increment, _ := strconv.ParseInt(arguments[1], 10, 64)
+		update = incrementBy(increment)
+	case compute.DecrByCommandID:
+		decrement, _ := strconv.ParseInt(arguments[1], 10, 64)
+		if decrement == math.MinInt64 {
+			return result.FromError(errOverflow)
+		}
+
+		update = incrementBy(-decrement)
+	case compute.IncrByFloatCommandID:
+		increment, _ := strconv.ParseFloat(arguments[1], 64)
+		update = incrementByFloat(increment)
+	}
+
+	var value string
+	_, err := d.storageLayer.Update(ctx, arguments[0], func(current string, found bool, expiresAt time.Time) (string, time.Time, error) {
+		var err error
+		value, err = update(current, found)
+		return value, expiresAt, err
+	})
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	if query.CommandID() == compute.IncrByFloatCommandID {
+		return result.String(value)
+	}
+
+	counter, _ := strconv.ParseInt(value, 10, 64)
+	return result.Integer(counter)
+}
+
+func incrementBy(increment int64) func(string, bool) (string, error) {
+	return func(value string, found bool) (string, error) {
+		var counter int64
+		if found {
+			var err error
+			if counter, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return "", errNotInteger
+			}
+		}
+
+		if increment > 0 && counter > math.MaxInt64-increment || increment < 0 && counter < math.MinInt64-increment {
+			return "", errOverflow
+		}
+
+		return strconv.FormatInt(counter+increment, 10), nil
+	}
+}
+
+func incrementByFloat(increment float64) func(string, bool) (string, error) {
+	return func(value string, found bool) (string, error) {
+		var counter float64
+		if found {
+			var err error
+			counter, err = strconv.ParseFloat(value, 64)
+			if err != nil || math.IsInf(counter, 0) || math.IsNaN(counter) {
+				return "", errNotFloat
+			}
+		}
+
+		counter += increment
+		if math.IsInf(counter, 0) {
+			return "", errOverflow
+		}
+
+		return strconv.FormatFloat(counter, 'f', -1, 64), nil
+	}
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"inmem-db-go/internal/database/result"
+	"sync"
+	"testing"
+)
+
+func TestCounterQueries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	database := newTestDatabase(t)
+
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "INCR counter", expected: "[ok] 1"},
+		{query: "INCRBY counter 10", expected: "[ok] 11"},
+		{query: "DECR counter", expected: "[ok] 10"},
+		{query: "DECRBY counter 15", expected: "[ok] -5"},
+		{query: "DECR missing", expected: "[ok] -1"},
+		{query: "INCRBYFLOAT float 0.1", expected: "[ok] 0.1"},
+		{query: "INCRBYFLOAT float 0.2", expected: "[ok] 0.30000000000000004"},
+		{query: "INCRBYFLOAT counter 1.5", expected: "[ok] -3.5"},
+		{query: "INCR counter", expected: "[error] value is not an integer or out of range"},
+		{query: "SET text abc", expected: "[ok]"},
+		{query: "INCRBYFLOAT text 1", expected: "[error] value is not a valid float"},
+		{query: "SET big 9223372036854775807", expected: "[ok]"},
+		{query: "INCR big", expected: "[error] increment or decrement would overflow"},
+		{query: "DECRBY zero -9223372036854775808", expected: "[error] increment or decrement would overflow"},
+		{query: "SET huge 1e308", expected: "[ok]"},
+		{query: "INCRBYFLOAT huge 1e308", expected: "[error] increment or decrement would overflow"},
+	}
+
+	// queries depend on each other, so they run one by one
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, database.HandleQuery(ctx, tc.query), tc.query)
+	}
+
+	assert.Equal(t, "[ok] 9223372036854775807", database.HandleQuery(ctx, "GET big"))
+	assert.Equal(t, result.CodeInvalidArgument, database.Execute(ctx, "INCR text").Code)
+	assert.Equal(t, result.CodeInvalidArgument, database.Execute(ctx, "INCRBY counter 1.5").Code)
+}
+
+func TestCounterQueryKeepsExpiration(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	database := newTestDatabase(t)
+
+	require.Equal(t, "[ok]", database.HandleQuery(ctx, "SET counter 1 EX 100"))
+	require.Equal(t, "[ok] 2", database.HandleQuery(ctx, "INCR counter"))
+	assert.Equal(t, "[ok] 100", database.HandleQuery(ctx, "TTL counter"))
+}
+
+func TestConcurrentIncr(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	database := newTestDatabase(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				database.HandleQuery(ctx, "INCR counter")
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, "[ok] 1000", database.HandleQuery(ctx, "GET counter"))
+}
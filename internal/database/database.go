@@ -6,26 +6,107 @@ import (
 	"fmt"
 	"go.uber.org/zap"
 	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/glob"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/storage"
+	"inmem-db-go/internal/database/txcontext"
+	"inmem-db-go/internal/metrics"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultScanCount is the number of keys SCAN takes without the COUNT option
+const defaultScanCount = 10
+
+var (
+	errSessionRequired = result.NewError(result.CodeTransaction, "transactions require a session")
+	errReadOnlyReplica = result.NewError(result.CodeReadOnly, "replica is read-only")
+	errNotReplica      = result.NewError(result.CodeNotReplica, "server is not a replica")
+	// an analyzed command without a handler
+	errInternalConfiguration = errors.New("internal configuration error")
 )
 
 type computeLayer interface {
 	HandleQuery(context.Context, string) (compute.Query, error)
+	HandleTokens(context.Context, []string) (compute.Query, error)
 }
 
 type storageLayer interface {
 	Set(ctx context.Context, key, value string) error
+	SetWithExpiration(ctx context.Context, key, value string, expiresAt time.Time) error
 	Get(ctx context.Context, key string) (string, error)
 	Del(ctx context.Context, key string) error
+	Update(ctx context.Context, key string, fn func(value string, found bool, expiresAt time.Time) (string, time.Time, error)) (bool, error)
+	Replace(ctx context.Context, key string, fn func(exists bool) (string, time.Time, error)) (bool, error)
+	Expire(ctx context.Context, key string, expiresAt time.Time) (bool, error)
+	Persist(ctx context.Context, key string) (bool, error)
+	Expiration(ctx context.Context, key string) (time.Time, bool, error)
+	Keys(ctx context.Context) ([]string, error)
+	Scan(ctx context.Context, cursor string, count int) ([]string, string, error)
+	Size(ctx context.Context) (int, error)
+	MSet(ctx context.Context, writes []storage.KeyState) error
+	MGet(ctx context.Context, keys []string) ([]storage.KeyState, error)
+	MDel(ctx context.Context, keys []string) ([]bool, error)
+	HSet(ctx context.Context, key string, fields map[string]string) (int, error)
+	HDel(ctx context.Context, key string, fields []string) (int, error)
+	HGet(ctx context.Context, key, field string) (string, error)
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	HLen(ctx context.Context, key string) (int, error)
+	Snapshot(ctx context.Context) error
+	Commit(ctx context.Context, reads, writes []storage.KeyState) error
+}
+
+type replicationLayer interface {
+	IsReplica() bool
+	Lag() int64
+	Promote() error
+}
+
+type DatabaseOption func(*Database)
+
+// WithReplication makes the database reject writes while
+// the replication follows a master.
+func WithReplication(replication replicationLayer) DatabaseOption {
+	return func(database *Database) {
+		database.replicationLayer = replication
+	}
+}
+
+// WithSlowLogThreshold logs queries running longer than the threshold
+// as slow, zero disables the slow log.
+func WithSlowLogThreshold(threshold time.Duration) DatabaseOption {
+	return func(database *Database) {
+		database.slowLogThreshold = threshold
+	}
+}
+
+// WithMetrics counts executed commands and errors and measures
+// the query latency per command.
+func WithMetrics(registry *metrics.Registry) DatabaseOption {
+	return func(database *Database) {
+		database.registry = registry
+	}
 }
 
 type Database struct {
-	computeLayer computeLayer
-	storageLayer storageLayer
-	idGenerator  *IDGenerator
-	logger       *zap.Logger
+	computeLayer     computeLayer
+	storageLayer     storageLayer
+	replicationLayer replicationLayer
+	idGenerator      *IDGenerator
+	slowLogThreshold time.Duration
+	infoSections     []namedInfoSection
+	commandStats     *commandStats
+	logger           *zap.Logger
+
+	registry *metrics.Registry
+	commands *metrics.CounterVec
+	failures *metrics.CounterVec
+	latency  *metrics.HistogramVec
 }
 
-func NewDatabase(computeLayer computeLayer, storageLayer storageLayer, logger *zap.Logger) (*Database, error) {
+func NewDatabase(computeLayer computeLayer, storageLayer storageLayer, logger *zap.Logger, options ...DatabaseOption) (*Database, error) {
 	if computeLayer == nil {
 		return nil, errors.New("compute is invalid")
 	}
@@ -38,56 +119,441 @@ func NewDatabase(computeLayer computeLayer, storageLayer storageLayer, logger *z
 		return nil, errors.New("logger is invalid")
 	}
 
-	return &Database{
+	database := &Database{
 		computeLayer: computeLayer,
 		storageLayer: storageLayer,
 		idGenerator:  NewIDGenerator(),
+		commandStats: newCommandStats(),
 		logger:       logger,
-	}, nil
+	}
+
+	for _, option := range options {
+		option(database)
+	}
+
+	for _, section := range database.infoSections {
+		if section.name == "" || section.section == nil {
+			return nil, errors.New("info section is invalid")
+		}
+	}
+
+	database.commands = database.registry.CounterVec("inmemdb_commands_total", "Executed commands.", "command")
+	database.failures = database.registry.CounterVec("inmemdb_errors_total", "Failed queries by error code.", "code")
+	database.latency = database.registry.HistogramVec(
+		"inmemdb_query_duration_seconds",
+		"Latency of queries from parsing to the reply.",
+		"command",
+		metrics.DefaultLatencyBuckets,
+	)
+
+	return database, nil
 }
 
-func (d *Database) HandleQuery(ctx context.Context, queryStr string) string {
+// Execute applies the query right away, transactions
+// are available only within a session, see NewSession.
+func (d *Database) Execute(ctx context.Context, queryStr string) result.Result {
+	startedAt := time.Now()
+	ctx = txcontext.WithTxID(ctx, d.idGenerator.Generate())
 	query, err := d.computeLayer.HandleQuery(ctx, queryStr)
 	if err != nil {
-		return fmt.Sprintf("[error] %s", err.Error())
+		return d.observeQuery(ctx, compute.UnknownCommandID, startedAt, result.FromError(err))
+	}
+
+	return d.observeQuery(ctx, query.CommandID(), startedAt, d.executeAnalyzed(ctx, query))
+}
+
+// ExecuteTokens is like Execute for a query that is already split into tokens.
+func (d *Database) ExecuteTokens(ctx context.Context, tokens []string) result.Result {
+	startedAt := time.Now()
+	ctx = txcontext.WithTxID(ctx, d.idGenerator.Generate())
+	query, err := d.computeLayer.HandleTokens(ctx, tokens)
+	if err != nil {
+		return d.observeQuery(ctx, compute.UnknownCommandID, startedAt, result.FromError(err))
 	}
 
+	return d.observeQuery(ctx, query.CommandID(), startedAt, d.executeAnalyzed(ctx, query))
+}
+
+// HandleQuery is like Execute with the reply in the text format.
+func (d *Database) HandleQuery(ctx context.Context, queryStr string) string {
+	return string(result.TextEncoder{}.Encode(d.Execute(ctx, queryStr)))
+}
+
+// observeQuery records the metrics of the query and logs it when it ran
+// for longer than the slow log threshold. Queries that failed to parse
+// are counted as UNKNOWN.
+func (d *Database) observeQuery(ctx context.Context, commandID int, startedAt time.Time, reply result.Result) result.Result {
+	duration := time.Since(startedAt)
+	command := compute.CommandIDToCommandName(commandID)
+
+	d.commandStats.observe(command, duration, reply.Status == result.StatusError)
+	d.commands.WithLabel(command).Inc()
+	d.latency.WithLabel(command).ObserveDuration(duration)
+	if reply.Status == result.StatusError {
+		d.failures.WithLabel(string(reply.Code)).Inc()
+	}
+
+	if d.slowLogThreshold > 0 && duration >= d.slowLogThreshold {
+		d.logger.Warn(
+			"slow query",
+			zap.Int64("tx", txcontext.TxID(ctx)),
+			zap.String("command", command),
+			zap.Duration("duration", duration),
+			zap.String("client", txcontext.ClientAddress(ctx)),
+		)
+	}
+
+	return reply
+}
+
+func (d *Database) executeAnalyzed(ctx context.Context, query compute.Query) result.Result {
+	if d.isReadOnly(query) {
+		return result.FromError(errReadOnlyReplica)
+	}
+
+	return d.execute(ctx, query)
+}
+
+func (d *Database) execute(ctx context.Context, query compute.Query) result.Result {
 	switch query.CommandID() {
 	case compute.SetCommandID:
-		return d.handleSetQuery(ctx, query)
+		return d.executeSetQuery(ctx, query)
 	case compute.GetCommandID:
-		return d.handleGetQuery(ctx, query)
+		return d.executeGetQuery(ctx, query)
 	case compute.DelCommandID:
-		return d.handleDelQuery(ctx, query)
+		return d.executeDelQuery(ctx, query)
+	case compute.SaveCommandID:
+		return d.executeSaveQuery(ctx)
+	case compute.ExpireCommandID:
+		return d.executeExpireQuery(ctx, query, time.Second)
+	case compute.PExpireCommandID:
+		return d.executeExpireQuery(ctx, query, time.Millisecond)
+	case compute.TTLCommandID:
+		return d.executeTTLQuery(ctx, query)
+	case compute.PersistCommandID:
+		return d.executePersistQuery(ctx, query)
+	case compute.ExistsCommandID:
+		return d.executeExistsQuery(ctx, query)
+	case compute.BeginCommandID, compute.CommitCommandID, compute.RollbackCommandID:
+		return result.FromError(errSessionRequired)
+	case compute.RoleCommandID:
+		return d.executeRoleQuery()
+	case compute.PromoteCommandID:
+		return d.executePromoteQuery()
+	case compute.InfoCommandID:
+		return d.executeInfoQuery(query)
+	case compute.KeysCommandID:
+		return d.executeKeysQuery(ctx, query)
+	case compute.ScanCommandID:
+		return d.executeScanQuery(ctx, query)
+	case compute.DBSizeCommandID:
+		return d.executeDBSizeQuery(ctx)
+	case compute.MSetCommandID:
+		return d.executeMSetQuery(ctx, query)
+	case compute.MGetCommandID:
+		return d.executeMGetQuery(ctx, query)
+	case compute.MDelCommandID:
+		return d.executeMDelQuery(ctx, query)
+	case compute.IncrCommandID, compute.DecrCommandID, compute.IncrByCommandID,
+		compute.DecrByCommandID, compute.IncrByFloatCommandID:
+		return d.executeCounterQuery(ctx, query)
+	case compute.SetNXCommandID:
+		return d.executeSetNXQuery(ctx, query)
+	case compute.GetSetCommandID:
+		return d.executeGetSetQuery(ctx, query)
+	case compute.CASCommandID:
+		return d.executeCASQuery(ctx, query)
+	case compute.AppendCommandID:
+		return d.executeAppendQuery(ctx, query)
+	case compute.StrLenCommandID:
+		return d.executeStrLenQuery(ctx, query)
+	case compute.GetRangeCommandID:
+		return d.executeGetRangeQuery(ctx, query)
+	case compute.SetRangeCommandID:
+		return d.executeSetRangeQuery(ctx, query)
+	case compute.HSetCommandID:
+		return d.executeHSetQuery(ctx, query)
+	case compute.HGetCommandID:
+		return d.executeHGetQuery(ctx, query)
+	case compute.HDelCommandID:
+		return d.executeHDelQuery(ctx, query)
+	case compute.HGetAllCommandID, compute.HKeysCommandID, compute.HValsCommandID:
+		return d.executeHGetAllQuery(ctx, query)
+	case compute.HLenCommandID:
+		return d.executeHLenQuery(ctx, query)
+	case compute.HExistsCommandID:
+		return d.executeHExistsQuery(ctx, query)
 	}
 
-	return "[error] internal configuration error"
+	return result.FromError(errInternalConfiguration)
 }
 
-func (d *Database) handleSetQuery(ctx context.Context, query compute.Query) string {
+func (d *Database) executeSetQuery(ctx context.Context, query compute.Query) result.Result {
 	arguments := query.Arguments()
-	if err := d.storageLayer.Set(ctx, arguments[0], arguments[1]); err != nil {
-		return fmt.Sprintf("[error] %s", err.Error())
+	options, _ := compute.ParseSetOptions(arguments[2:])
+	if options.Conditional() {
+		return d.executeConditionalSetQuery(ctx, arguments[0], arguments[1], options)
 	}
 
-	return "[ok]"
+	if options.TTL == 0 {
+		if err := d.storageLayer.Set(ctx, arguments[0], arguments[1]); err != nil {
+			return result.FromError(err)
+		}
+
+		return result.OK()
+	}
+
+	expiresAt := time.Now().Add(options.TTL)
+	if err := d.storageLayer.SetWithExpiration(ctx, arguments[0], arguments[1], expiresAt); err != nil {
+		return result.FromError(err)
+	}
+
+	return result.OK()
 }
 
-func (d *Database) handleGetQuery(ctx context.Context, query compute.Query) string {
+func (d *Database) executeGetQuery(ctx context.Context, query compute.Query) result.Result {
 	arguments := query.Arguments()
 	value, err := d.storageLayer.Get(ctx, arguments[0])
+	if errors.Is(err, storage.ErrNotFound) {
+		return result.NotFound()
+	} else if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.String(value)
+}
+
+func (d *Database) executeDelQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	err := d.storageLayer.Del(ctx, arguments[0])
+	if errors.Is(err, storage.ErrNotFound) {
+		return result.Count(0)
+	} else if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.Count(1)
+}
+
+func (d *Database) executeSaveQuery(ctx context.Context) result.Result {
+	if err := d.storageLayer.Snapshot(ctx); err != nil {
+		return result.FromError(err)
+	}
+
+	return result.OK()
+}
+
+func (d *Database) executeExpireQuery(ctx context.Context, query compute.Query, unit time.Duration) result.Result {
+	arguments := query.Arguments()
+	timeout, _ := strconv.ParseInt(arguments[1], 10, 64)
+	updated, err := d.storageLayer.Expire(ctx, arguments[0], time.Now().Add(time.Duration(timeout)*unit))
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.Bool(updated)
+}
+
+// executeTTLQuery replies with the remaining time to live in seconds,
+// -1 for keys without expiration and -2 for missing keys.
+func (d *Database) executeTTLQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	expiresAt, found, err := d.storageLayer.Expiration(ctx, arguments[0])
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	if !found {
+		return result.Integer(-2)
+	}
+
+	if expiresAt.IsZero() {
+		return result.Integer(-1)
+	}
+
+	ttl := time.Until(expiresAt).Round(time.Second)
+	return result.Integer(int64(ttl / time.Second))
+}
+
+func (d *Database) executePersistQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	updated, err := d.storageLayer.Persist(ctx, arguments[0])
 	if err != nil {
-		return fmt.Sprintf("[error] %s", err.Error())
+		return result.FromError(err)
 	}
 
-	return fmt.Sprintf("[ok] %s", value)
+	return result.Bool(updated)
 }
 
-func (d *Database) handleDelQuery(ctx context.Context, query compute.Query) string {
+// executeExistsQuery checks keys of any type, Get fails for
+// keys which are not strings, but they exist all the same.
+func (d *Database) executeExistsQuery(ctx context.Context, query compute.Query) result.Result {
 	arguments := query.Arguments()
-	if err := d.storageLayer.Del(ctx, arguments[0]); err != nil {
-		return fmt.Sprintf("[error] %s", err.Error())
+	_, err := d.storageLayer.Get(ctx, arguments[0])
+	if errors.Is(err, storage.ErrNotFound) {
+		return result.Bool(false)
+	} else if err != nil && result.CodeOf(err) != result.CodeWrongType {
+		return result.FromError(err)
+	}
+
+	return result.Bool(true)
+}
+
+// executeMSetQuery writes all pairs at once, concurrent
+// queries see either none or all of them.
+func (d *Database) executeMSetQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	writes := make([]storage.KeyState, 0, len(arguments)/2)
+	for i := 0; i < len(arguments); i += 2 {
+		writes = append(writes, storage.KeyState{Key: arguments[i], Value: arguments[i+1], Found: true})
+	}
+
+	if err := d.storageLayer.MSet(ctx, writes); err != nil {
+		return result.FromError(err)
+	}
+
+	return result.OK()
+}
+
+// executeMGetQuery replies with a value for every key, missing keys are nil.
+func (d *Database) executeMGetQuery(ctx context.Context, query compute.Query) result.Result {
+	states, err := d.storageLayer.MGet(ctx, query.Arguments())
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	values := make([]*string, len(states))
+	for i := range states {
+		if states[i].Found {
+			values[i] = &states[i].Value
+		}
+	}
+
+	return result.List(values)
+}
+
+// executeMDelQuery removes all keys at once and replies
+// with 1 for every removed key and 0 for missing ones.
+func (d *Database) executeMDelQuery(ctx context.Context, query compute.Query) result.Result {
+	deleted, err := d.storageLayer.MDel(ctx, query.Arguments())
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.List(boolValues(deleted))
+}
+
+// executeKeysQuery replies with the sorted keys matching the pattern,
+// it walks the whole storage, so SCAN suits large databases better.
+func (d *Database) executeKeysQuery(ctx context.Context, query compute.Query) result.Result {
+	pattern := query.Arguments()[0]
+	keys, err := d.storageLayer.Keys(ctx)
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	sort.Strings(keys)
+	matched := make([]*string, 0, len(keys))
+	for i := range keys {
+		if glob.Match(pattern, keys[i]) {
+			matched = append(matched, &keys[i])
+		}
+	}
+
+	return result.List(matched)
+}
+
+// executeScanQuery replies with the next cursor and a batch of keys, like
+// Redis the pattern is applied after the batch is taken, so a batch may be
+// empty before the scan is over.
+func (d *Database) executeScanQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	pattern, count := "*", defaultScanCount
+	for i := 1; i < len(arguments); i += 2 {
+		if strings.EqualFold(arguments[i], compute.MatchOption) {
+			pattern = arguments[i+1]
+		} else {
+			count, _ = strconv.Atoi(arguments[i+1])
+		}
+	}
+
+	keys, cursor, err := d.storageLayer.Scan(ctx, arguments[0], count)
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	matched := make([]*string, 0, len(keys))
+	for i := range keys {
+		if glob.Match(pattern, keys[i]) {
+			matched = append(matched, &keys[i])
+		}
+	}
+
+	return result.Cursor(cursor, matched)
+}
+
+func (d *Database) executeDBSizeQuery(ctx context.Context) result.Result {
+	size, err := d.storageLayer.Size(ctx)
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.Integer(int64(size))
+}
+
+// executeRoleQuery replies with the server role,
+// replicas also report the number of master records not applied yet.
+func (d *Database) executeRoleQuery() result.Result {
+	if d.replicationLayer == nil || !d.replicationLayer.IsReplica() {
+		return result.String("master")
+	}
+
+	return result.String(fmt.Sprintf("replica lag=%d", d.replicationLayer.Lag()))
+}
+
+func (d *Database) executePromoteQuery() result.Result {
+	if d.replicationLayer == nil {
+		return result.FromError(errNotReplica)
+	}
+
+	if err := d.replicationLayer.Promote(); err != nil {
+		return result.FromError(err)
+	}
+
+	return result.OK()
+}
+
+// isReadOnly reports whether the query modifies data on a replica.
+func (d *Database) isReadOnly(query compute.Query) bool {
+	if d.replicationLayer == nil || !d.replicationLayer.IsReplica() {
+		return false
+	}
+
+	switch query.CommandID() {
+	case compute.SetCommandID, compute.DelCommandID, compute.ExpireCommandID,
+		compute.PExpireCommandID, compute.PersistCommandID, compute.MSetCommandID,
+		compute.MDelCommandID, compute.IncrCommandID, compute.DecrCommandID,
+		compute.IncrByCommandID, compute.DecrByCommandID, compute.IncrByFloatCommandID,
+		compute.SetNXCommandID, compute.GetSetCommandID, compute.CASCommandID,
+		compute.AppendCommandID, compute.SetRangeCommandID, compute.HSetCommandID,
+		compute.HDelCommandID:
+		return true
+	}
+
+	return false
+}
+
+// boolValues turns flags into list values of 1 and 0.
+func boolValues(flags []bool) []*string {
+	one, zero := "1", "0"
+	values := make([]*string, len(flags))
+	for i, flag := range flags {
+		if flag {
+			values[i] = &one
+		} else {
+			values[i] = &zero
+		}
 	}
 
-	return "[ok]"
+	return values
 }
@@ -7,7 +7,9 @@ package database
 import (
 	context "context"
 	compute "inmem-db-go/internal/database/compute"
+	storage "inmem-db-go/internal/database/storage"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -50,6 +52,21 @@ func (mr *MockcomputeLayerMockRecorder) HandleQuery(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleQuery", reflect.TypeOf((*MockcomputeLayer)(nil).HandleQuery), arg0, arg1)
 }
 
+// HandleTokens mocks base method.
+func (m *MockcomputeLayer) HandleTokens(arg0 context.Context, arg1 []string) (compute.Query, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HandleTokens", arg0, arg1)
+	ret0, _ := ret[0].(compute.Query)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HandleTokens indicates an expected call of HandleTokens.
+func (mr *MockcomputeLayerMockRecorder) HandleTokens(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleTokens", reflect.TypeOf((*MockcomputeLayer)(nil).HandleTokens), arg0, arg1)
+}
+
 // MockstorageLayer is a mock of storageLayer interface.
 type MockstorageLayer struct {
 	ctrl     *gomock.Controller
@@ -73,6 +90,20 @@ func (m *MockstorageLayer) EXPECT() *MockstorageLayerMockRecorder {
 	return m.recorder
 }
 
+// Commit mocks base method.
+func (m *MockstorageLayer) Commit(ctx context.Context, reads, writes []storage.KeyState) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Commit", ctx, reads, writes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Commit indicates an expected call of Commit.
+func (mr *MockstorageLayerMockRecorder) Commit(ctx, reads, writes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockstorageLayer)(nil).Commit), ctx, reads, writes)
+}
+
 // Del mocks base method.
 func (m *MockstorageLayer) Del(ctx context.Context, key string) error {
 	m.ctrl.T.Helper()
@@ -87,6 +118,37 @@ func (mr *MockstorageLayerMockRecorder) Del(ctx, key interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockstorageLayer)(nil).Del), ctx, key)
 }
 
+// Expiration mocks base method.
+func (m *MockstorageLayer) Expiration(ctx context.Context, key string) (time.Time, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expiration", ctx, key)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Expiration indicates an expected call of Expiration.
+func (mr *MockstorageLayerMockRecorder) Expiration(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expiration", reflect.TypeOf((*MockstorageLayer)(nil).Expiration), ctx, key)
+}
+
+// Expire mocks base method.
+func (m *MockstorageLayer) Expire(ctx context.Context, key string, expiresAt time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expire", ctx, key, expiresAt)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Expire indicates an expected call of Expire.
+func (mr *MockstorageLayerMockRecorder) Expire(ctx, key, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expire", reflect.TypeOf((*MockstorageLayer)(nil).Expire), ctx, key, expiresAt)
+}
+
 // Get mocks base method.
 func (m *MockstorageLayer) Get(ctx context.Context, key string) (string, error) {
 	m.ctrl.T.Helper()
@@ -102,6 +164,186 @@ func (mr *MockstorageLayerMockRecorder) Get(ctx, key interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockstorageLayer)(nil).Get), ctx, key)
 }
 
+// HDel mocks base method.
+func (m *MockstorageLayer) HDel(ctx context.Context, key string, fields []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HDel", ctx, key, fields)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HDel indicates an expected call of HDel.
+func (mr *MockstorageLayerMockRecorder) HDel(ctx, key, fields interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HDel", reflect.TypeOf((*MockstorageLayer)(nil).HDel), ctx, key, fields)
+}
+
+// HGet mocks base method.
+func (m *MockstorageLayer) HGet(ctx context.Context, key, field string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HGet", ctx, key, field)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HGet indicates an expected call of HGet.
+func (mr *MockstorageLayerMockRecorder) HGet(ctx, key, field interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HGet", reflect.TypeOf((*MockstorageLayer)(nil).HGet), ctx, key, field)
+}
+
+// HGetAll mocks base method.
+func (m *MockstorageLayer) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HGetAll", ctx, key)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HGetAll indicates an expected call of HGetAll.
+func (mr *MockstorageLayerMockRecorder) HGetAll(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HGetAll", reflect.TypeOf((*MockstorageLayer)(nil).HGetAll), ctx, key)
+}
+
+// HLen mocks base method.
+func (m *MockstorageLayer) HLen(ctx context.Context, key string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HLen", ctx, key)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HLen indicates an expected call of HLen.
+func (mr *MockstorageLayerMockRecorder) HLen(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HLen", reflect.TypeOf((*MockstorageLayer)(nil).HLen), ctx, key)
+}
+
+// HSet mocks base method.
+func (m *MockstorageLayer) HSet(ctx context.Context, key string, fields map[string]string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HSet", ctx, key, fields)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HSet indicates an expected call of HSet.
+func (mr *MockstorageLayerMockRecorder) HSet(ctx, key, fields interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HSet", reflect.TypeOf((*MockstorageLayer)(nil).HSet), ctx, key, fields)
+}
+
+// Keys mocks base method.
+func (m *MockstorageLayer) Keys(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Keys", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Keys indicates an expected call of Keys.
+func (mr *MockstorageLayerMockRecorder) Keys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Keys", reflect.TypeOf((*MockstorageLayer)(nil).Keys), ctx)
+}
+
+// MDel mocks base method.
+func (m *MockstorageLayer) MDel(ctx context.Context, keys []string) ([]bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MDel", ctx, keys)
+	ret0, _ := ret[0].([]bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MDel indicates an expected call of MDel.
+func (mr *MockstorageLayerMockRecorder) MDel(ctx, keys interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MDel", reflect.TypeOf((*MockstorageLayer)(nil).MDel), ctx, keys)
+}
+
+// MGet mocks base method.
+func (m *MockstorageLayer) MGet(ctx context.Context, keys []string) ([]storage.KeyState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MGet", ctx, keys)
+	ret0, _ := ret[0].([]storage.KeyState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MGet indicates an expected call of MGet.
+func (mr *MockstorageLayerMockRecorder) MGet(ctx, keys interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MGet", reflect.TypeOf((*MockstorageLayer)(nil).MGet), ctx, keys)
+}
+
+// MSet mocks base method.
+func (m *MockstorageLayer) MSet(ctx context.Context, writes []storage.KeyState) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MSet", ctx, writes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MSet indicates an expected call of MSet.
+func (mr *MockstorageLayerMockRecorder) MSet(ctx, writes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MSet", reflect.TypeOf((*MockstorageLayer)(nil).MSet), ctx, writes)
+}
+
+// Persist mocks base method.
+func (m *MockstorageLayer) Persist(ctx context.Context, key string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Persist", ctx, key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Persist indicates an expected call of Persist.
+func (mr *MockstorageLayerMockRecorder) Persist(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Persist", reflect.TypeOf((*MockstorageLayer)(nil).Persist), ctx, key)
+}
+
+// Replace mocks base method.
+func (m *MockstorageLayer) Replace(ctx context.Context, key string, fn func(bool) (string, time.Time, error)) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", ctx, key, fn)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Replace indicates an expected call of Replace.
+func (mr *MockstorageLayerMockRecorder) Replace(ctx, key, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockstorageLayer)(nil).Replace), ctx, key, fn)
+}
+
+// Scan mocks base method.
+func (m *MockstorageLayer) Scan(ctx context.Context, cursor string, count int) ([]string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Scan", ctx, cursor, count)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockstorageLayerMockRecorder) Scan(ctx, cursor, count interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockstorageLayer)(nil).Scan), ctx, cursor, count)
+}
+
 // Set mocks base method.
 func (m *MockstorageLayer) Set(ctx context.Context, key, value string) error {
 	m.ctrl.T.Helper()
@@ -115,3 +357,126 @@ func (mr *MockstorageLayerMockRecorder) Set(ctx, key, value interface{}) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockstorageLayer)(nil).Set), ctx, key, value)
 }
+
+// SetWithExpiration mocks base method.
+func (m *MockstorageLayer) SetWithExpiration(ctx context.Context, key, value string, expiresAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetWithExpiration", ctx, key, value, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetWithExpiration indicates an expected call of SetWithExpiration.
+func (mr *MockstorageLayerMockRecorder) SetWithExpiration(ctx, key, value, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithExpiration", reflect.TypeOf((*MockstorageLayer)(nil).SetWithExpiration), ctx, key, value, expiresAt)
+}
+
+// Size mocks base method.
+func (m *MockstorageLayer) Size(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Size", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Size indicates an expected call of Size.
+func (mr *MockstorageLayerMockRecorder) Size(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Size", reflect.TypeOf((*MockstorageLayer)(nil).Size), ctx)
+}
+
+// Snapshot mocks base method.
+func (m *MockstorageLayer) Snapshot(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Snapshot", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Snapshot indicates an expected call of Snapshot.
+func (mr *MockstorageLayerMockRecorder) Snapshot(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Snapshot", reflect.TypeOf((*MockstorageLayer)(nil).Snapshot), ctx)
+}
+
+// Update mocks base method.
+func (m *MockstorageLayer) Update(ctx context.Context, key string, fn func(string, bool, time.Time) (string, time.Time, error)) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, key, fn)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockstorageLayerMockRecorder) Update(ctx, key, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockstorageLayer)(nil).Update), ctx, key, fn)
+}
+
+// MockreplicationLayer is a mock of replicationLayer interface.
+type MockreplicationLayer struct {
+	ctrl     *gomock.Controller
+	recorder *MockreplicationLayerMockRecorder
+}
+
+// MockreplicationLayerMockRecorder is the mock recorder for MockreplicationLayer.
+type MockreplicationLayerMockRecorder struct {
+	mock *MockreplicationLayer
+}
+
+// NewMockreplicationLayer creates a new mock instance.
+func NewMockreplicationLayer(ctrl *gomock.Controller) *MockreplicationLayer {
+	mock := &MockreplicationLayer{ctrl: ctrl}
+	mock.recorder = &MockreplicationLayerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockreplicationLayer) EXPECT() *MockreplicationLayerMockRecorder {
+	return m.recorder
+}
+
+// IsReplica mocks base method.
+func (m *MockreplicationLayer) IsReplica() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsReplica")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsReplica indicates an expected call of IsReplica.
+func (mr *MockreplicationLayerMockRecorder) IsReplica() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsReplica", reflect.TypeOf((*MockreplicationLayer)(nil).IsReplica))
+}
+
+// Lag mocks base method.
+func (m *MockreplicationLayer) Lag() int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lag")
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// Lag indicates an expected call of Lag.
+func (mr *MockreplicationLayerMockRecorder) Lag() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lag", reflect.TypeOf((*MockreplicationLayer)(nil).Lag))
+}
+
+// Promote mocks base method.
+func (m *MockreplicationLayer) Promote() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Promote")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Promote indicates an expected call of Promote.
+func (mr *MockreplicationLayerMockRecorder) Promote() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Promote", reflect.TypeOf((*MockreplicationLayer)(nil).Promote))
+}
@@ -1,14 +1,21 @@
 package database
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/storage"
+	"inmem-db-go/internal/database/txcontext"
+	"inmem-db-go/internal/metrics"
 	"testing"
+	"time"
 )
 
 func TestNewDatabase(t *testing.T) {
@@ -38,17 +45,17 @@ func TestNewDatabase(t *testing.T) {
 func TestHandleSetQuery(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	ctrl := gomock.NewController(t)
 	computeLayer := NewMockcomputeLayer(ctrl)
 	computeLayer.EXPECT().
-		HandleQuery(ctx, "SET one 1").
+		HandleQuery(gomock.Any(), "SET one 1").
 		Return(compute.NewQuery(compute.SetCommandID, []string{"one", "1"}), nil)
 
 	storageLayer := NewMockstorageLayer(ctrl)
 	storageLayer.EXPECT().
-		Set(ctx, "one", "1").
+		Set(gomock.Any(), "one", "1").
 		Return(nil)
 
 	database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop())
@@ -62,17 +69,17 @@ func TestHandleSetQuery(t *testing.T) {
 func TestHandleGetQuery(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	ctrl := gomock.NewController(t)
 	computeLayer := NewMockcomputeLayer(ctrl)
 	computeLayer.EXPECT().
-		HandleQuery(ctx, "GET one").
+		HandleQuery(gomock.Any(), "GET one").
 		Return(compute.NewQuery(compute.GetCommandID, []string{"one"}), nil)
 
 	storageLayer := NewMockstorageLayer(ctrl)
 	storageLayer.EXPECT().
-		Get(ctx, "one").
+		Get(gomock.Any(), "one").
 		Return("1", nil)
 
 	database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop())
@@ -86,17 +93,17 @@ func TestHandleGetQuery(t *testing.T) {
 func TestHandleDelQuery(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	ctrl := gomock.NewController(t)
 	computeLayer := NewMockcomputeLayer(ctrl)
 	computeLayer.EXPECT().
-		HandleQuery(ctx, "DEL one").
+		HandleQuery(gomock.Any(), "DEL one").
 		Return(compute.NewQuery(compute.DelCommandID, []string{"one"}), nil)
 
 	storageLayer := NewMockstorageLayer(ctrl)
 	storageLayer.EXPECT().
-		Del(ctx, "one").
+		Del(gomock.Any(), "one").
 		Return(nil)
 
 	database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop())
@@ -107,15 +114,216 @@ func TestHandleDelQuery(t *testing.T) {
 	assert.Equal(t, res, "[ok]")
 }
 
+func TestHandleNotFoundQueries(t *testing.T) {
+	testCases := []struct {
+		name   string
+		query  string
+		parsed compute.Query
+		expect func(*MockstorageLayer)
+		result string
+	}{
+		{
+			name:   "get missing key",
+			query:  "GET one",
+			parsed: compute.NewQuery(compute.GetCommandID, []string{"one"}),
+			expect: func(s *MockstorageLayer) {
+				s.EXPECT().Get(gomock.Any(), "one").Return("", storage.ErrNotFound)
+			},
+			result: "[not found]",
+		},
+		{
+			name:   "get empty value",
+			query:  "GET one",
+			parsed: compute.NewQuery(compute.GetCommandID, []string{"one"}),
+			expect: func(s *MockstorageLayer) {
+				s.EXPECT().Get(gomock.Any(), "one").Return("", nil)
+			},
+			result: `[ok] ""`,
+		},
+		{
+			name:   "del missing key",
+			query:  "DEL one",
+			parsed: compute.NewQuery(compute.DelCommandID, []string{"one"}),
+			expect: func(s *MockstorageLayer) {
+				s.EXPECT().Del(gomock.Any(), "one").Return(storage.ErrNotFound)
+			},
+			result: "[not found]",
+		},
+		{
+			name:   "exists missing key",
+			query:  "EXISTS one",
+			parsed: compute.NewQuery(compute.ExistsCommandID, []string{"one"}),
+			expect: func(s *MockstorageLayer) {
+				s.EXPECT().Get(gomock.Any(), "one").Return("", storage.ErrNotFound)
+			},
+			result: "[ok] 0",
+		},
+		{
+			name:   "exists key",
+			query:  "EXISTS one",
+			parsed: compute.NewQuery(compute.ExistsCommandID, []string{"one"}),
+			expect: func(s *MockstorageLayer) {
+				s.EXPECT().Get(gomock.Any(), "one").Return("", nil)
+			},
+			result: "[ok] 1",
+		},
+		{
+			name:   "exists with storage error",
+			query:  "EXISTS one",
+			parsed: compute.NewQuery(compute.ExistsCommandID, []string{"one"}),
+			expect: func(s *MockstorageLayer) {
+				s.EXPECT().Get(gomock.Any(), "one").Return("", context.Canceled)
+			},
+			result: "[error] context canceled",
+		},
+	}
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			computeLayer := NewMockcomputeLayer(ctrl)
+			computeLayer.EXPECT().
+				HandleQuery(gomock.Any(), tc.query).
+				Return(tc.parsed, nil)
+			storageLayer := NewMockstorageLayer(ctrl)
+			tc.expect(storageLayer)
+
+			database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop())
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.result, database.HandleQuery(ctx, tc.query))
+		})
+	}
+}
+
+func TestHandleSaveQuery(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	computeLayer := NewMockcomputeLayer(ctrl)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "SAVE").
+		Return(compute.NewQuery(compute.SaveCommandID, []string{}), nil)
+	storageLayer := NewMockstorageLayer(ctrl)
+	storageLayer.EXPECT().
+		Snapshot(gomock.Any()).
+		Return(errors.New("snapshots are disabled"))
+
+	database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop())
+	require.NoError(t, err)
+	require.NotNil(t, database)
+
+	res := database.HandleQuery(ctx, "SAVE")
+	assert.Equal(t, res, "[error] snapshots are disabled")
+}
+
+func TestHandleSetWithExpirationQuery(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	computeLayer := NewMockcomputeLayer(ctrl)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "SET one 1 PX 500").
+		Return(compute.NewQuery(compute.SetCommandID, []string{"one", "1", "PX", "500"}), nil)
+	storageLayer := NewMockstorageLayer(ctrl)
+	storageLayer.EXPECT().
+		SetWithExpiration(gomock.Any(), "one", "1", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _ string, expiresAt time.Time) error {
+			assert.WithinDuration(t, time.Now().Add(500*time.Millisecond), expiresAt, 100*time.Millisecond)
+			return nil
+		})
+
+	database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop())
+	require.NoError(t, err)
+
+	res := database.HandleQuery(ctx, "SET one 1 PX 500")
+	assert.Equal(t, "[ok]", res)
+}
+
+func TestHandleExpireQuery(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	computeLayer := NewMockcomputeLayer(ctrl)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "EXPIRE one 30").
+		Return(compute.NewQuery(compute.ExpireCommandID, []string{"one", "30"}), nil)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "PERSIST one").
+		Return(compute.NewQuery(compute.PersistCommandID, []string{"one"}), nil)
+	storageLayer := NewMockstorageLayer(ctrl)
+	storageLayer.EXPECT().
+		Expire(gomock.Any(), "one", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, expiresAt time.Time) (bool, error) {
+			assert.WithinDuration(t, time.Now().Add(30*time.Second), expiresAt, time.Second)
+			return true, nil
+		})
+	storageLayer.EXPECT().
+		Persist(gomock.Any(), "one").
+		Return(false, nil)
+
+	database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop())
+	require.NoError(t, err)
+
+	assert.Equal(t, "[ok] 1", database.HandleQuery(ctx, "EXPIRE one 30"))
+	assert.Equal(t, "[ok] 0", database.HandleQuery(ctx, "PERSIST one"))
+}
+
+func TestHandleTTLQuery(t *testing.T) {
+	testCases := []struct {
+		name      string
+		expiresAt time.Time
+		found     bool
+		result    string
+	}{
+		{name: "missing key", found: false, result: "[ok] -2"},
+		{name: "persistent key", expiresAt: time.Time{}, found: true, result: "[ok] -1"},
+		{name: "volatile key", expiresAt: time.Now().Add(time.Minute), found: true, result: "[ok] 60"},
+	}
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			computeLayer := NewMockcomputeLayer(ctrl)
+			computeLayer.EXPECT().
+				HandleQuery(gomock.Any(), "TTL one").
+				Return(compute.NewQuery(compute.TTLCommandID, []string{"one"}), nil)
+			storageLayer := NewMockstorageLayer(ctrl)
+			storageLayer.EXPECT().
+				Expiration(gomock.Any(), "one").
+				Return(tc.expiresAt, tc.found, nil)
+
+			database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop())
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.result, database.HandleQuery(ctx, "TTL one"))
+		})
+	}
+}
+
 func TestInvalidCommand(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	ctrl := gomock.NewController(t)
 	computeLayer := NewMockcomputeLayer(ctrl)
 	computeLayer.EXPECT().
-		HandleQuery(ctx, "TRUNCATE").
+		HandleQuery(gomock.Any(), "TRUNCATE").
 		Return(compute.Query{}, errors.New("invalid command"))
 
 	storageLayer := NewMockstorageLayer(ctrl)
@@ -127,3 +335,302 @@ func TestInvalidCommand(t *testing.T) {
 	res := database.HandleQuery(ctx, "TRUNCATE")
 	assert.Equal(t, res, "[error] invalid command")
 }
+
+func TestHandleQueryAssignsTxID(t *testing.T) {
+	t.Parallel()
+
+	var txIDs []int64
+	ctrl := gomock.NewController(t)
+	computeLayer := NewMockcomputeLayer(ctrl)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "GET one").
+		DoAndReturn(func(ctx context.Context, _ string) (compute.Query, error) {
+			txIDs = append(txIDs, txcontext.TxID(ctx))
+			return compute.Query{}, errors.New("invalid command")
+		}).
+		Times(2)
+	storageLayer := NewMockstorageLayer(ctrl)
+
+	database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop())
+	require.NoError(t, err)
+
+	database.HandleQuery(context.Background(), "GET one")
+	database.HandleQuery(context.Background(), "GET one")
+
+	require.Len(t, txIDs, 2)
+	assert.NotZero(t, txIDs[0])
+	assert.NotEqual(t, txIDs[0], txIDs[1])
+}
+
+func TestHandleQueryWithoutTxID(t *testing.T) {
+	t.Parallel()
+
+	database := newTestDatabase(t)
+
+	assert.Equal(t, "[ok]", database.HandleQuery(context.Background(), "SET one 1"))
+	assert.Equal(t, "[ok] 1", database.HandleQuery(context.Background(), "GET one"))
+	assert.Equal(t, "[error] invalid command", database.HandleQuery(context.Background(), "TRUNCATE"))
+}
+
+func TestReplicaRejectsWrites(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	replication := NewMockreplicationLayer(ctrl)
+	replication.EXPECT().IsReplica().Return(true).AnyTimes()
+	replication.EXPECT().Lag().Return(int64(3))
+
+	database := newTestDatabase(t, WithReplication(replication))
+	session := database.NewSession()
+	ctx := context.Background()
+
+	assert.Equal(t, "[error] replica is read-only", database.HandleQuery(ctx, "SET one 1"))
+	assert.Equal(t, "[error] replica is read-only", database.HandleQuery(ctx, "DEL one"))
+	assert.Equal(t, "[error] replica is read-only", database.HandleQuery(ctx, "EXPIRE one 10"))
+	assert.Equal(t, "[not found]", database.HandleQuery(ctx, "GET one"))
+	assert.Equal(t, `[ok] "replica lag=3"`, database.HandleQuery(ctx, "ROLE"))
+
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[error] replica is read-only", session.HandleQuery(ctx, "SET one 1"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "COMMIT"))
+}
+
+func TestHandlePromoteQuery(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	assert.Equal(t, "[ok] master", newTestDatabase(t).HandleQuery(ctx, "ROLE"))
+	assert.Equal(t, "[error] server is not a replica", newTestDatabase(t).HandleQuery(ctx, "PROMOTE"))
+
+	ctrl := gomock.NewController(t)
+	replication := NewMockreplicationLayer(ctrl)
+	gomock.InOrder(
+		replication.EXPECT().IsReplica().Return(true),
+		replication.EXPECT().Promote().Return(nil),
+		replication.EXPECT().IsReplica().Return(false),
+	)
+
+	database := newTestDatabase(t, WithReplication(replication))
+	assert.Equal(t, "[ok]", database.HandleQuery(ctx, "PROMOTE"))
+	assert.Equal(t, "[ok]", database.HandleQuery(ctx, "SET one 1"))
+}
+
+func TestExecuteErrorCodes(t *testing.T) {
+	t.Parallel()
+
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name         string
+		ctx          context.Context
+		query        string
+		expectedCode result.Code
+	}{
+		{name: "syntax error", ctx: context.Background(), query: "GET key%", expectedCode: result.CodeSyntax},
+		{name: "unterminated quote", ctx: context.Background(), query: `SET key "value`, expectedCode: result.CodeSyntax},
+		{name: "unknown command", ctx: context.Background(), query: "TRUNCATE", expectedCode: result.CodeUnknownCommand},
+		{name: "wrong arity", ctx: context.Background(), query: "GET", expectedCode: result.CodeWrongArity},
+		{name: "invalid argument", ctx: context.Background(), query: "EXPIRE key soon", expectedCode: result.CodeInvalidArgument},
+		{name: "overflowing timeout", ctx: context.Background(), query: "PEXPIRE key 9223372036855", expectedCode: result.CodeInvalidArgument},
+		{name: "canceled context", ctx: canceledCtx, query: "GET key", expectedCode: result.CodeCanceled},
+		{name: "transaction without session", ctx: context.Background(), query: "BEGIN", expectedCode: result.CodeTransaction},
+		{name: "promote without replication", ctx: context.Background(), query: "PROMOTE", expectedCode: result.CodeNotReplica},
+	}
+
+	database := newTestDatabase(t)
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			reply := database.Execute(tc.ctx, tc.query)
+			assert.Equal(t, result.StatusError, reply.Status)
+			assert.Equal(t, tc.expectedCode, reply.Code)
+		})
+	}
+}
+
+func TestSlowLog(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithClientAddress(context.Background(), "127.0.0.1:5555")
+
+	ctrl := gomock.NewController(t)
+	computeLayer := NewMockcomputeLayer(ctrl)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), gomock.Any()).
+		Return(compute.NewQuery(compute.GetCommandID, []string{"key"}), nil).
+		Times(2)
+
+	storageLayer := NewMockstorageLayer(ctrl)
+	storageLayer.EXPECT().
+		Get(gomock.Any(), "key").
+		DoAndReturn(func(context.Context, string) (string, error) {
+			time.Sleep(10 * time.Millisecond)
+			return "value", nil
+		})
+	storageLayer.EXPECT().
+		Get(gomock.Any(), "key").
+		Return("value", nil)
+
+	core, logs := observer.New(zap.WarnLevel)
+	database, err := NewDatabase(computeLayer, storageLayer, zap.New(core), WithSlowLogThreshold(5*time.Millisecond))
+	require.NoError(t, err)
+
+	database.Execute(ctx, "GET key")
+	require.Equal(t, 1, logs.Len())
+
+	entry := logs.All()[0]
+	assert.Equal(t, "slow query", entry.Message)
+	fields := entry.ContextMap()
+	assert.Equal(t, "GET", fields["command"])
+	assert.Equal(t, "127.0.0.1:5555", fields["client"])
+	assert.NotZero(t, fields["tx"])
+	assert.GreaterOrEqual(t, fields["duration"], 5*time.Millisecond)
+
+	database.Execute(ctx, "GET key")
+	assert.Equal(t, 1, logs.Len())
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	computeLayer := NewMockcomputeLayer(ctrl)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "GET key").
+		Return(compute.NewQuery(compute.GetCommandID, []string{"key"}), nil).
+		Times(2)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "DROP key").
+		Return(compute.Query{}, result.NewError(result.CodeUnknownCommand, "unknown command"))
+
+	storageLayer := NewMockstorageLayer(ctrl)
+	storageLayer.EXPECT().
+		Get(gomock.Any(), "key").
+		Return("value", nil)
+	storageLayer.EXPECT().
+		Get(gomock.Any(), "key").
+		Return("", errors.New("storage is broken"))
+
+	registry := metrics.NewRegistry()
+	database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop(), WithMetrics(registry))
+	require.NoError(t, err)
+
+	database.Execute(ctx, "GET key")
+	database.Execute(ctx, "GET key")
+	database.Execute(ctx, "DROP key")
+
+	var buffer bytes.Buffer
+	registry.WriteText(&buffer)
+	text := buffer.String()
+	assert.Contains(t, text, `inmemdb_commands_total{command="GET"} 2`)
+	assert.Contains(t, text, `inmemdb_commands_total{command="UNKNOWN"} 1`)
+	assert.Contains(t, text, `inmemdb_errors_total{code="INTERNAL"} 1`)
+	assert.Contains(t, text, `inmemdb_errors_total{code="UNKNOWN_COMMAND"} 1`)
+	assert.Contains(t, text, `inmemdb_query_duration_seconds_count{command="GET"} 2`)
+}
+
+func TestHandleInfoQuery(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	computeLayer := NewMockcomputeLayer(ctrl)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "INFO").
+		Return(compute.NewQuery(compute.InfoCommandID, nil), nil)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "INFO Server").
+		Return(compute.NewQuery(compute.InfoCommandID, []string{"Server"}), nil)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "INFO disk").
+		Return(compute.NewQuery(compute.InfoCommandID, []string{"disk"}), nil)
+
+	server := func() []InfoField {
+		return []InfoField{{Name: "version", Value: "1.0"}}
+	}
+
+	database, err := NewDatabase(computeLayer, NewMockstorageLayer(ctrl), zap.NewNop(), WithInfoSection("server", server))
+	require.NoError(t, err)
+
+	res := database.HandleQuery(ctx, "INFO")
+	assert.Equal(t, `[ok] "# Server" version:1.0 "# Replication" role:master "# Commandstats"`, res)
+
+	res = database.HandleQuery(ctx, "INFO Server")
+	assert.Equal(t, `[ok] "# Server" version:1.0`, res)
+
+	reply := database.Execute(ctx, "INFO disk")
+	assert.Equal(t, result.CodeInvalidArgument, reply.Code)
+
+	_, err = NewDatabase(computeLayer, NewMockstorageLayer(ctrl), zap.NewNop(), WithInfoSection("", server))
+	require.Error(t, err)
+}
+
+func TestCommandStats(t *testing.T) {
+	t.Parallel()
+
+	stats := newCommandStats()
+	stats.observe("GET", 10*time.Microsecond, false)
+	stats.observe("GET", 20*time.Microsecond, true)
+	stats.observe("DEL", time.Microsecond, false)
+
+	assert.Equal(t, []InfoField{
+		{Name: "cmdstat_del", Value: "calls=1,usec=1,usec_per_call=1.00,failed_calls=0"},
+		{Name: "cmdstat_get", Value: "calls=2,usec=30,usec_per_call=15.00,failed_calls=1"},
+	}, stats.info())
+}
+
+func TestHandleKeysQueries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	computeLayer := NewMockcomputeLayer(ctrl)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "KEYS user:*").
+		Return(compute.NewQuery(compute.KeysCommandID, []string{"user:*"}), nil)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "SCAN 0 MATCH user:* COUNT 3").
+		Return(compute.NewQuery(compute.ScanCommandID, []string{"0", "MATCH", "user:*", "COUNT", "3"}), nil)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "SCAN 1_61").
+		Return(compute.NewQuery(compute.ScanCommandID, []string{"1_61"}), nil)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "SCAN cursor").
+		Return(compute.NewQuery(compute.ScanCommandID, []string{"cursor"}), nil)
+	computeLayer.EXPECT().
+		HandleQuery(gomock.Any(), "DBSIZE").
+		Return(compute.NewQuery(compute.DBSizeCommandID, nil), nil)
+
+	storageLayer := NewMockstorageLayer(ctrl)
+	storageLayer.EXPECT().
+		Keys(gomock.Any()).
+		Return([]string{"user:2", "order:1", "user:1"}, nil)
+	storageLayer.EXPECT().
+		Scan(gomock.Any(), "0", 3).
+		Return([]string{"order:1", "user:1", "user:2"}, "1_75736572", nil)
+	storageLayer.EXPECT().
+		Scan(gomock.Any(), "1_61", defaultScanCount).
+		Return(nil, "0", nil)
+	storageLayer.EXPECT().
+		Scan(gomock.Any(), "cursor", defaultScanCount).
+		Return(nil, "", result.NewError(result.CodeInvalidArgument, "invalid cursor"))
+	storageLayer.EXPECT().
+		Size(gomock.Any()).
+		Return(3, nil)
+
+	database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop())
+	require.NoError(t, err)
+
+	assert.Equal(t, "[ok] user:1 user:2", database.HandleQuery(ctx, "KEYS user:*"))
+	assert.Equal(t, "[ok] 1_75736572 user:1 user:2", database.HandleQuery(ctx, "SCAN 0 MATCH user:* COUNT 3"))
+	assert.Equal(t, "[ok] 0", database.HandleQuery(ctx, "SCAN 1_61"))
+	assert.Equal(t, "[error] invalid cursor", database.HandleQuery(ctx, "SCAN cursor"))
+	assert.Equal(t, "[ok] 3", database.HandleQuery(ctx, "DBSIZE"))
+}
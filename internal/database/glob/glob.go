@@ -0,0 +1,110 @@
+// Package glob matches keys against the patterns of KEYS and SCAN MATCH.
+package glob
+
+// Match reports whether the key matches the pattern of KEYS and SCAN MATCH:
+// * matches any bytes, ? matches a single byte, [abc] and [a-z] match a byte
+// of the set, [^abc] or [!abc] a byte out of it, \ escapes the next symbol.
+// An unterminated [ is matched as is.
+func Match(pattern, key string) bool {
+	// the position after the last star and the key position it was tried at,
+	// on a mismatch the star takes one more byte of the key
+	starPattern, starKey := -1, 0
+	p, k := 0, 0
+	for k < len(key) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				p++
+				starPattern, starKey = p, k
+				continue
+			case '?':
+				p++
+				k++
+				continue
+			case '[':
+				if matched, next := matchClass(pattern, p, key[k]); next != -1 {
+					if matched {
+						p = next
+						k++
+						continue
+					}
+
+					break
+				}
+
+				if key[k] == '[' {
+					p++
+					k++
+					continue
+				}
+			default:
+				literal, next := pattern[p], p+1
+				if literal == '\\' && next < len(pattern) {
+					literal, next = pattern[next], next+1
+				}
+
+				if literal == key[k] {
+					p = next
+					k++
+					continue
+				}
+			}
+		}
+
+		if starPattern == -1 {
+			return false
+		}
+
+		starKey++
+		p, k = starPattern, starKey
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
+
+// matchClass matches the symbol against the class starting at the position
+// of [ and returns the position after the class, or -1 if it is unterminated.
+func matchClass(pattern string, start int, symbol byte) (bool, int) {
+	i := start + 1
+	negated := i < len(pattern) && (pattern[i] == '^' || pattern[i] == '!')
+	if negated {
+		i++
+	}
+
+	matched := false
+	for ; i < len(pattern) && pattern[i] != ']'; i++ {
+		low := pattern[i]
+		if low == '\\' && i+1 < len(pattern) {
+			i++
+			low = pattern[i]
+		}
+
+		high := low
+		if i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']' {
+			i += 2
+			high = pattern[i]
+			if high == '\\' && i+1 < len(pattern) {
+				i++
+				high = pattern[i]
+			}
+
+			if low > high {
+				low, high = high, low
+			}
+		}
+
+		if low <= symbol && symbol <= high {
+			matched = true
+		}
+	}
+
+	if i == len(pattern) {
+		return false, -1
+	}
+
+	return matched != negated, i + 1
+}
@@ -0,0 +1,51 @@
+package glob
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		pattern string
+		key     string
+		matched bool
+	}{
+		{pattern: "*", key: "", matched: true},
+		{pattern: "*", key: "user:1", matched: true},
+		{pattern: "user:*", key: "user:1", matched: true},
+		{pattern: "user:*", key: "order:1", matched: false},
+		{pattern: "*:1", key: "user:1", matched: true},
+		{pattern: "u*r*1", key: "user:1", matched: true},
+		{pattern: "u*r*2", key: "user:1", matched: false},
+		{pattern: "h?llo", key: "hello", matched: true},
+		{pattern: "h?llo", key: "hllo", matched: false},
+		{pattern: "h[ae]llo", key: "hallo", matched: true},
+		{pattern: "h[ae]llo", key: "hillo", matched: false},
+		{pattern: "h[^e]llo", key: "hallo", matched: true},
+		{pattern: "h[!e]llo", key: "hello", matched: false},
+		{pattern: "key[0-9]", key: "key7", matched: true},
+		{pattern: "key[9-0]", key: "key7", matched: true},
+		{pattern: "key[0-9]", key: "keyx", matched: false},
+		{pattern: "key[a-]", key: "key-", matched: true},
+		{pattern: `h\*llo`, key: "h*llo", matched: true},
+		{pattern: `h\*llo`, key: "hello", matched: false},
+		{pattern: `[\]]`, key: "]", matched: true},
+		{pattern: "h[llo", key: "h[llo", matched: true},
+		{pattern: "h[llo", key: "hallo", matched: false},
+		{pattern: `key\`, key: `key\`, matched: true},
+		{pattern: "user", key: "user:1", matched: false},
+		{pattern: "user:1*", key: "user:1", matched: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.pattern+" "+tc.key, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.matched, Match(tc.pattern, tc.key))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/storage"
+	"sort"
+)
+
+// executeHSetQuery sets the fields, the last value of a repeated field wins,
+// and replies with the number of fields added.
+func (d *Database) executeHSetQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	fields := make(map[string]string, len(arguments)/2)
+	for i := 1; i < len(arguments); i += 2 {
+		fields[arguments[i]] = arguments[i+1]
+	}
+
+	added, err := d.storageLayer.HSet(ctx, arguments[0], fields)
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.Integer(int64(added))
+}
+
+func (d *Database) executeHGetQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	value, err := d.storageLayer.HGet(ctx, arguments[0], arguments[1])
+	if errors.Is(err, storage.ErrNotFound) {
+		return result.NotFound()
+	} else if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.String(value)
+}
+
+// executeHDelQuery replies with the number of fields removed, the key
+// is removed together with its last field.
+func (d *Database) executeHDelQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	removed, err := d.storageLayer.HDel(ctx, arguments[0], arguments[1:])
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.Integer(int64(removed))
+}
+
+// executeHGetAllQuery replies to HGETALL with fields and values interleaved,
+// to HKEYS with fields and to HVALS with values. Fields are sorted, so
+// HKEYS and HVALS of the same hash go in the same order.
+func (d *Database) executeHGetAllQuery(ctx context.Context, query compute.Query) result.Result {
+	fields, err := d.storageLayer.HGetAll(ctx, query.Arguments()[0])
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	values := make([]*string, 0, 2*len(names))
+	for i := range names {
+		value := fields[names[i]]
+		switch query.CommandID() {
+		case compute.HGetAllCommandID:
+			values = append(values, &names[i], &value)
+		case compute.HKeysCommandID:
+			values = append(values, &names[i])
+		case compute.HValsCommandID:
+			values = append(values, &value)
+		}
+	}
+
+	return result.List(values)
+}
+
+func (d *Database) executeHLenQuery(ctx context.Context, query compute.Query) result.Result {
+	length, err := d.storageLayer.HLen(ctx, query.Arguments()[0])
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.Integer(int64(length))
+}
+
+func (d *Database) executeHExistsQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	_, err := d.storageLayer.HGet(ctx, arguments[0], arguments[1])
+	if errors.Is(err, storage.ErrNotFound) {
+		return result.Bool(false)
+	} else if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.Bool(true)
+}
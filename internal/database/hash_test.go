@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHashQueries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	database := newTestDatabase(t)
+
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "HSET user name alice age 30", expected: "[ok] 2"},
+		{query: "HSET user age 31 city paris", expected: "[ok] 1"},
+		{query: "HGET user age", expected: "[ok] 31"},
+		{query: "HGET user missing", expected: "[not found]"},
+		{query: "HGET missing name", expected: "[not found]"},
+		{query: "HLEN user", expected: "[ok] 3"},
+		{query: "HLEN missing", expected: "[ok] 0"},
+		{query: "HEXISTS user name", expected: "[ok] 1"},
+		{query: "HEXISTS user missing", expected: "[ok] 0"},
+		{query: "HGETALL user", expected: "[ok] age 31 city paris name alice"},
+		{query: "HKEYS user", expected: "[ok] age city name"},
+		{query: "HVALS user", expected: "[ok] 31 paris alice"},
+		{query: "HGETALL missing", expected: "[ok]"},
+		{query: "HDEL user age missing age", expected: "[ok] 1"},
+		{query: "EXISTS user", expected: "[ok] 1"},
+		{query: "KEYS *", expected: "[ok] user"},
+		{query: "DBSIZE", expected: "[ok] 1"},
+		{query: "EXPIRE user 100", expected: "[ok] 1"},
+		{query: "TTL user", expected: "[ok] 100"},
+		{query: "HSET user name bob", expected: "[ok] 0"},
+		{query: "TTL user", expected: "[ok] 100"},
+		{query: "HDEL user city name", expected: "[ok] 2"},
+		{query: "EXISTS user", expected: "[ok] 0"},
+		{query: "HSET user name", expected: "[error] invalid arguments"},
+		{query: "HSET user name alice", expected: "[ok] 1"},
+		{query: "DEL user", expected: "[ok]"},
+		{query: "HLEN user", expected: "[ok] 0"},
+	}
+
+	// queries depend on each other, so they run one by one
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, database.HandleQuery(ctx, tc.query), tc.query)
+	}
+}
+
+func TestHashWrongType(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	database := newTestDatabase(t)
+
+	const wrongType = "[error] operation against a key holding the wrong kind of value"
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "SET string value", expected: "[ok]"},
+		{query: "HSET hash field 1", expected: "[ok] 1"},
+		{query: "HSET string field value", expected: wrongType},
+		{query: "HGET string field", expected: wrongType},
+		{query: "HDEL string field", expected: wrongType},
+		{query: "HGETALL string", expected: wrongType},
+		{query: "HLEN string", expected: wrongType},
+		{query: "HEXISTS string field", expected: wrongType},
+		{query: "GET hash", expected: wrongType},
+		{query: "INCR hash", expected: wrongType},
+		{query: "APPEND hash value", expected: wrongType},
+		{query: "STRLEN hash", expected: wrongType},
+		{query: "GETSET hash value", expected: wrongType},
+		{query: "SET hash value NX GET", expected: wrongType},
+		{query: "MGET string hash", expected: "[ok] value (nil)"},
+		{query: "EXISTS hash", expected: "[ok] 1"},
+		{query: "MDEL hash missing", expected: "[ok] 1 0"},
+		{query: "HSET hash field 1", expected: "[ok] 1"},
+		{query: "SET hash value", expected: "[ok]"},
+		{query: "GET hash", expected: "[ok] value"},
+		{query: "HGET hash field", expected: wrongType},
+	}
+
+	// queries depend on each other, so they run one by one
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, database.HandleQuery(ctx, tc.query), tc.query)
+	}
+}
+
+func TestHashExistsInTransaction(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	database := newTestDatabase(t)
+	require.Equal(t, "[ok] 1", database.HandleQuery(ctx, "HSET hash field value"))
+
+	session := database.NewSession()
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[ok] 1", session.HandleQuery(ctx, "EXISTS hash"))
+	assert.Equal(t, "[ok] 0", session.HandleQuery(ctx, "EXISTS missing"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "COMMIT"))
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"fmt"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/result"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	replicationInfoSection  = "replication"
+	commandStatsInfoSection = "commandstats"
+	// allInfoSections selects every section like INFO without arguments
+	allInfoSections = "all"
+)
+
+var errUnknownInfoSection = result.NewError(result.CodeInvalidArgument, "unknown info section")
+
+// InfoField is a "name:value" line of the INFO reply.
+type InfoField struct {
+	Name  string
+	Value string
+}
+
+// InfoSection returns the current fields of an INFO section.
+type InfoSection func() []InfoField
+
+type namedInfoSection struct {
+	name    string
+	section InfoSection
+}
+
+// WithInfoSection adds a section to the INFO reply, sections are listed in
+// the order they are added, followed by the replication and command statistics
+// sections of the database itself.
+func WithInfoSection(name string, section InfoSection) DatabaseOption {
+	return func(database *Database) {
+		database.infoSections = append(database.infoSections, namedInfoSection{
+			name:    strings.ToLower(name),
+			section: section,
+		})
+	}
+}
+
+// executeInfoQuery replies with a list of "# Section" headers followed by
+// "name:value" fields, INFO section limits the reply to a single section.
+func (d *Database) executeInfoQuery(query compute.Query) result.Result {
+	selected := allInfoSections
+	if arguments := query.Arguments(); len(arguments) != 0 {
+		selected = strings.ToLower(arguments[0])
+	}
+
+	sections := make([]namedInfoSection, 0, len(d.infoSections)+2)
+	sections = append(sections, d.infoSections...)
+	sections = append(sections,
+		namedInfoSection{name: replicationInfoSection, section: d.replicationInfo},
+		namedInfoSection{name: commandStatsInfoSection, section: d.commandStats.info},
+	)
+
+	var lines []*string
+	for _, section := range sections {
+		if selected != allInfoSections && selected != section.name {
+			continue
+		}
+
+		header := "# " + strings.ToUpper(section.name[:1]) + section.name[1:]
+		lines = append(lines, &header)
+		for _, field := range section.section() {
+			line := field.Name + ":" + field.Value
+			lines = append(lines, &line)
+		}
+	}
+
+	if lines == nil {
+		return result.FromError(errUnknownInfoSection)
+	}
+
+	return result.List(lines)
+}
+
+func (d *Database) replicationInfo() []InfoField {
+	if d.replicationLayer == nil || !d.replicationLayer.IsReplica() {
+		return []InfoField{{Name: "role", Value: "master"}}
+	}
+
+	return []InfoField{
+		{Name: "role", Value: "replica"},
+		{Name: "lag", Value: fmt.Sprint(d.replicationLayer.Lag())},
+	}
+}
+
+// commandStats counts calls of every command for INFO commandstats.
+type commandStats struct {
+	mutex sync.Mutex
+	stats map[string]*commandStat
+}
+
+type commandStat struct {
+	calls    int64
+	failed   int64
+	duration time.Duration
+}
+
+func newCommandStats() *commandStats {
+	return &commandStats{
+		stats: make(map[string]*commandStat),
+	}
+}
+
+func (s *commandStats) observe(command string, duration time.Duration, failed bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stat, found := s.stats[command]
+	if !found {
+		stat = &commandStat{}
+		s.stats[command] = stat
+	}
+
+	stat.calls++
+	stat.duration += duration
+	if failed {
+		stat.failed++
+	}
+}
+
+// info formats the statistics like Redis: cmdstat_get:calls=2,usec=15,usec_per_call=7.50,failed_calls=0
+func (s *commandStats) info() []InfoField {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	commands := make([]string, 0, len(s.stats))
+	for command := range s.stats {
+		commands = append(commands, command)
+	}
+
+	sort.Strings(commands)
+
+	fields := make([]InfoField, 0, len(commands))
+	for _, command := range commands {
+		stat := s.stats[command]
+		usec := stat.duration.Microseconds()
+		fields = append(fields, InfoField{
+			Name: "cmdstat_" + strings.ToLower(command),
+			Value: fmt.Sprintf(
+				"calls=%d,usec=%d,usec_per_call=%.2f,failed_calls=%d",
+				stat.calls, usec, float64(usec)/float64(stat.calls), stat.failed,
+			),
+		})
+	}
+
+	return fields
+}
@@ -0,0 +1,163 @@
+package result
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+const nilValue = "(nil)"
+
+type Encoder interface {
+	Encode(Result) []byte
+}
+
+// TextEncoder produces replies of the text protocol: "[ok]", "[ok] value",
+// "[not found]" and "[error] message". A cursor goes before its list values, list values are separated by spaces,
+// values with spaces, quotes or control characters are quoted, so a reply always takes one line.
+type TextEncoder struct{}
+
+func (TextEncoder) Encode(result Result) []byte {
+	switch result.Status {
+	case StatusNotFound:
+		return []byte("[not found]")
+	case StatusError:
+		return []byte("[error] " + result.Message)
+	}
+
+	switch result.Kind {
+	case KindString:
+		return appendQuoted([]byte("[ok] "), result.Value)
+	case KindInteger:
+		return []byte("[ok] " + strconv.FormatInt(result.Integer, 10))
+	case KindList, KindCursor:
+		reply := []byte("[ok]")
+		if result.Kind == KindCursor {
+			reply = append(reply, ' ')
+			reply = appendQuoted(reply, result.Value)
+		}
+
+		for _, value := range result.Values {
+			reply = append(reply, ' ')
+			if value == nil {
+				reply = append(reply, nilValue...)
+			} else {
+				reply = appendQuoted(reply, *value)
+			}
+		}
+
+		return reply
+	}
+
+	return []byte("[ok]")
+}
+
+// JSONEncoder produces one line JSON objects like
+// {"status":"ok","value":"1"} or {"status":"error","error":{"code":"SYNTAX","message":"..."}}.
+type JSONEncoder struct{}
+
+type jsonResult struct {
+	Status   string     `json:"status"`
+	Value    *string    `json:"value,omitempty"`
+	Integer  *int64     `json:"integer,omitempty"`
+	Values   *[]*string `json:"values,omitempty"`
+	Affected *int64     `json:"affected,omitempty"`
+	Error    *jsonError `json:"error,omitempty"`
+}
+
+type jsonError struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+func (JSONEncoder) Encode(result Result) []byte {
+	reply := jsonResult{}
+	switch result.Status {
+	case StatusOK:
+		reply.Status = "ok"
+	case StatusNotFound:
+		reply.Status = "not_found"
+	case StatusError:
+		reply.Status = "error"
+		reply.Error = &jsonError{Code: result.Code, Message: result.Message}
+	}
+
+	switch result.Kind {
+	case KindString:
+		reply.Value = &result.Value
+	case KindInteger:
+		reply.Integer = &result.Integer
+	case KindCount:
+		reply.Affected = &result.Affected
+	case KindList, KindCursor:
+		if result.Kind == KindCursor {
+			reply.Value = &result.Value
+		}
+
+		values := result.Values
+		if values == nil {
+			values = []*string{}
+		}
+
+		reply.Values = &values
+	}
+
+	// the reply has only strings and numbers, so it is always encoded
+	data, _ := json.Marshal(reply)
+	return data
+}
+
+// ParseEncoder returns the encoder by the format name: text or json.
+func ParseEncoder(format string) (Encoder, error) {
+	switch format {
+	case "text":
+		return TextEncoder{}, nil
+	case "json":
+		return JSONEncoder{}, nil
+	}
+
+	return nil, errors.New("reply format is invalid")
+}
+
+// appendQuoted appends the value as is when it is a plain word and
+// in double quotes with escapes otherwise.
+func appendQuoted(buffer []byte, value string) []byte {
+	if isPlainWord(value) {
+		return append(buffer, value...)
+	}
+
+	const hexDigits = "0123456789abcdef"
+	buffer = append(buffer, '"')
+	for i := 0; i < len(value); i++ {
+		switch symbol := value[i]; {
+		case symbol == '"' || symbol == '\\':
+			buffer = append(buffer, '\\', symbol)
+		case symbol == '\n':
+			buffer = append(buffer, '\\', 'n')
+		case symbol == '\t':
+			buffer = append(buffer, '\\', 't')
+		case symbol == '\r':
+			buffer = append(buffer, '\\', 'r')
+		case symbol < ' ' || symbol == 0x7f:
+			buffer = append(buffer, '\\', 'x', hexDigits[symbol>>4], hexDigits[symbol&0xf])
+		default:
+			buffer = append(buffer, symbol)
+		}
+	}
+
+	return append(buffer, '"')
+}
+
+func isPlainWord(value string) bool {
+	if value == "" || value == nilValue {
+		return false
+	}
+
+	for i := 0; i < len(value); i++ {
+		if symbol := value[i]; symbol <= ' ' || symbol == '"' || symbol == '\'' || symbol == '\\' || symbol == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,58 @@
+package result
+
+import (
+	"context"
+	"errors"
+)
+
+// Code identifies the kind of a failed query, codes are
+// a stable part of the protocol unlike error messages.
+type Code string
+
+const (
+	CodeSyntax          Code = "SYNTAX"
+	CodeUnknownCommand  Code = "UNKNOWN_COMMAND"
+	CodeWrongArity      Code = "WRONG_ARITY"
+	CodeInvalidArgument Code = "INVALID_ARGUMENT"
+	CodeCanceled        Code = "CANCELED"
+	CodeConflict        Code = "CONFLICT"
+	CodeTransaction     Code = "TRANSACTION"
+	CodeReadOnly        Code = "READ_ONLY"
+	CodeNotReplica      Code = "NOT_REPLICA"
+	CodeOutOfMemory     Code = "OUT_OF_MEMORY"
+	CodeNotSupported    Code = "NOT_SUPPORTED"
+	CodeWrongType       Code = "WRONG_TYPE"
+	CodeInternal        Code = "INTERNAL"
+)
+
+// Error is an error with a code, the layers define their
+// errors with it, so the code survives wrapping.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func NewError(code Code, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// CodeOf returns the code of the first Error in the err chain,
+// errors without a code are internal.
+func CodeOf(err error) Code {
+	var codedErr *Error
+	switch {
+	case errors.As(err, &codedErr):
+		return codedErr.Code
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return CodeCanceled
+	}
+
+	return CodeInternal
+}
@@ -0,0 +1,88 @@
+package result
+
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusNotFound
+	StatusError
+)
+
+// Kind tells which field holds the value of the result.
+type Kind int
+
+const (
+	// KindNone is a plain acknowledgement without a value
+	KindNone Kind = iota
+	KindString
+	KindInteger
+	// KindCount is an acknowledgement reporting the number
+	// of affected keys, the text format shows only the status
+	KindCount
+	KindList
+	// KindCursor is a list of values with the cursor
+	// to continue an iteration with in Value
+	KindCursor
+)
+
+// Result is the outcome of a query, encoders turn it
+// into the reply of a particular format.
+type Result struct {
+	Status  Status
+	Kind    Kind
+	Value   string
+	Integer int64
+	// Values of a list, nil elements are missing values
+	Values   []*string
+	Affected int64
+	Code     Code
+	Message  string
+}
+
+func OK() Result {
+	return Result{Status: StatusOK}
+}
+
+func NotFound() Result {
+	return Result{Status: StatusNotFound}
+}
+
+func String(value string) Result {
+	return Result{Status: StatusOK, Kind: KindString, Value: value}
+}
+
+func Integer(value int64) Result {
+	return Result{Status: StatusOK, Kind: KindInteger, Integer: value}
+}
+
+// Bool is an integer result of 1 or 0.
+func Bool(value bool) Result {
+	if value {
+		return Integer(1)
+	}
+
+	return Integer(0)
+}
+
+// Count reports the number of affected keys,
+// no affected keys are reported as not found.
+func Count(affected int64) Result {
+	if affected == 0 {
+		return Result{Status: StatusNotFound, Kind: KindCount}
+	}
+
+	return Result{Status: StatusOK, Kind: KindCount, Affected: affected}
+}
+
+func List(values []*string) Result {
+	return Result{Status: StatusOK, Kind: KindList, Values: values}
+}
+
+// Cursor is a batch of an iteration, the cursor continues it.
+func Cursor(cursor string, values []*string) Result {
+	return Result{Status: StatusOK, Kind: KindCursor, Value: cursor, Values: values}
+}
+
+func FromError(err error) Result {
+	return Result{Status: StatusError, Code: CodeOf(err), Message: err.Error()}
+}
@@ -0,0 +1,153 @@
+package result
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCodeOf(t *testing.T) {
+	t.Parallel()
+
+	syntaxErr := NewError(CodeSyntax, "invalid symbol")
+
+	assert.Equal(t, CodeSyntax, CodeOf(syntaxErr))
+	assert.Equal(t, CodeSyntax, CodeOf(fmt.Errorf("%w at offset 3", syntaxErr)))
+	assert.Equal(t, CodeCanceled, CodeOf(context.Canceled))
+	assert.Equal(t, CodeCanceled, CodeOf(context.DeadlineExceeded))
+	assert.Equal(t, CodeInternal, CodeOf(errors.New("disk is full")))
+}
+
+func TestEncoders(t *testing.T) {
+	t.Parallel()
+
+	value := "hello world"
+	plain := "a"
+	testCases := []struct {
+		name         string
+		result       Result
+		expectedText string
+		expectedJSON string
+	}{
+		{
+			name:         "ok",
+			result:       OK(),
+			expectedText: "[ok]",
+			expectedJSON: `{"status":"ok"}`,
+		},
+		{
+			name:         "not found",
+			result:       NotFound(),
+			expectedText: "[not found]",
+			expectedJSON: `{"status":"not_found"}`,
+		},
+		{
+			name:         "value looking like an error",
+			result:       String("[error] value"),
+			expectedText: `[ok] "[error] value"`,
+			expectedJSON: `{"status":"ok","value":"[error] value"}`,
+		},
+		{
+			name:         "empty value",
+			result:       String(""),
+			expectedText: `[ok] ""`,
+			expectedJSON: `{"status":"ok","value":""}`,
+		},
+		{
+			name:         "plain value",
+			result:       String(plain),
+			expectedText: "[ok] a",
+			expectedJSON: `{"status":"ok","value":"a"}`,
+		},
+		{
+			name:         "integer",
+			result:       Integer(-2),
+			expectedText: "[ok] -2",
+			expectedJSON: `{"status":"ok","integer":-2}`,
+		},
+		{
+			name:         "affected keys",
+			result:       Count(1),
+			expectedText: "[ok]",
+			expectedJSON: `{"status":"ok","affected":1}`,
+		},
+		{
+			name:         "no affected keys",
+			result:       Count(0),
+			expectedText: "[not found]",
+			expectedJSON: `{"status":"not_found","affected":0}`,
+		},
+		{
+			name:         "list",
+			result:       List([]*string{&plain, &value, nil}),
+			expectedText: `[ok] a "hello world" (nil)`,
+			expectedJSON: `{"status":"ok","values":["a","hello world",null]}`,
+		},
+		{
+			name:         "empty list",
+			result:       List(nil),
+			expectedText: "[ok]",
+			expectedJSON: `{"status":"ok","values":[]}`,
+		},
+		{
+			name:         "cursor",
+			result:       Cursor("1_61", []*string{&plain, &value}),
+			expectedText: `[ok] 1_61 a "hello world"`,
+			expectedJSON: `{"status":"ok","value":"1_61","values":["a","hello world"]}`,
+		},
+		{
+			name:         "finished cursor",
+			result:       Cursor("0", nil),
+			expectedText: "[ok] 0",
+			expectedJSON: `{"status":"ok","value":"0","values":[]}`,
+		},
+		{
+			name:         "error",
+			result:       FromError(NewError(CodeWrongArity, "invalid arguments")),
+			expectedText: "[error] invalid arguments",
+			expectedJSON: `{"status":"error","error":{"code":"WRONG_ARITY","message":"invalid arguments"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expectedText, string(TextEncoder{}.Encode(tc.result)))
+			assert.Equal(t, tc.expectedJSON, string(JSONEncoder{}.Encode(tc.result)))
+		})
+	}
+}
+
+func TestTextEncoderQuotesListValues(t *testing.T) {
+	t.Parallel()
+
+	values := []string{"", "(nil)", "a\"b\\c", "line\nbreak", "\x00\x7f"}
+	pointers := make([]*string, len(values))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	reply := TextEncoder{}.Encode(List(pointers))
+	assert.Equal(t, `[ok] "" "(nil)" "a\"b\\c" "line\nbreak" "\x00\x7f"`, string(reply))
+}
+
+func TestTextEncoderQuotesStringValue(t *testing.T) {
+	t.Parallel()
+
+	value := "line\nbreak\r\n"
+	reply := string(TextEncoder{}.Encode(String(value)))
+	assert.Equal(t, `[ok] "line\nbreak\r\n"`, reply)
+	assert.NotContains(t, reply, "\n")
+
+	// the quoted value is a valid Go string literal
+	unquoted, err := strconv.Unquote(strings.TrimPrefix(reply, "[ok] "))
+	require.NoError(t, err)
+	assert.Equal(t, value, unquoted)
+}
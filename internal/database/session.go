@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/storage"
+	"inmem-db-go/internal/database/txcontext"
+	"time"
+)
+
+var (
+	errTransactionStarted    = result.NewError(result.CodeTransaction, "transaction is already started")
+	errNoTransaction         = result.NewError(result.CodeTransaction, "transaction is not started")
+	errNotAllowedTransaction = result.NewError(result.CodeTransaction, "command is not allowed in transaction")
+)
+
+// Session keeps the state of one client, such as its open transaction.
+// It must not be used by several goroutines at once.
+type Session struct {
+	database    *Database
+	transaction *transaction
+}
+
+func (d *Database) NewSession() *Session {
+	return &Session{
+		database: d,
+	}
+}
+
+// Execute is like Database.Execute, but the queries
+// between BEGIN and COMMIT are applied as a transaction.
+func (s *Session) Execute(ctx context.Context, queryStr string) result.Result {
+	startedAt := time.Now()
+	ctx = s.withTxID(ctx)
+	query, err := s.database.computeLayer.HandleQuery(ctx, queryStr)
+	if err != nil {
+		return s.database.observeQuery(ctx, compute.UnknownCommandID, startedAt, result.FromError(err))
+	}
+
+	return s.database.observeQuery(ctx, query.CommandID(), startedAt, s.execute(ctx, query))
+}
+
+// ExecuteTokens is like Execute for a query that is already split into tokens.
+func (s *Session) ExecuteTokens(ctx context.Context, tokens []string) result.Result {
+	startedAt := time.Now()
+	ctx = s.withTxID(ctx)
+	query, err := s.database.computeLayer.HandleTokens(ctx, tokens)
+	if err != nil {
+		return s.database.observeQuery(ctx, compute.UnknownCommandID, startedAt, result.FromError(err))
+	}
+
+	return s.database.observeQuery(ctx, query.CommandID(), startedAt, s.execute(ctx, query))
+}
+
+// HandleQuery is like Execute with the reply in the text format.
+func (s *Session) HandleQuery(ctx context.Context, queryStr string) string {
+	return string(result.TextEncoder{}.Encode(s.Execute(ctx, queryStr)))
+}
+
+// withTxID assigns a new id to the query, queries of a transaction share its id.
+func (s *Session) withTxID(ctx context.Context) context.Context {
+	if s.transaction != nil {
+		return txcontext.WithTxID(ctx, s.transaction.id)
+	}
+
+	return txcontext.WithTxID(ctx, s.database.idGenerator.Generate())
+}
+
+func (s *Session) execute(ctx context.Context, query compute.Query) result.Result {
+	if s.database.isReadOnly(query) {
+		return result.FromError(errReadOnlyReplica)
+	}
+
+	switch query.CommandID() {
+	case compute.BeginCommandID:
+		return s.executeBeginQuery()
+	case compute.CommitCommandID:
+		return s.executeCommitQuery(ctx)
+	case compute.RollbackCommandID:
+		return s.executeRollbackQuery()
+	}
+
+	if s.transaction == nil {
+		return s.database.execute(ctx, query)
+	}
+
+	switch query.CommandID() {
+	case compute.SetCommandID:
+		return s.executeSetQuery(ctx, query)
+	case compute.GetCommandID:
+		return s.executeGetQuery(ctx, query)
+	case compute.DelCommandID:
+		return s.executeDelQuery(ctx, query)
+	case compute.ExistsCommandID:
+		return s.executeExistsQuery(ctx, query)
+	case compute.MSetCommandID:
+		return s.executeMSetQuery(query)
+	case compute.MGetCommandID:
+		return s.executeMGetQuery(ctx, query)
+	case compute.MDelCommandID:
+		return s.executeMDelQuery(ctx, query)
+	}
+
+	return result.FromError(errNotAllowedTransaction)
+}
+
+func (s *Session) executeBeginQuery() result.Result {
+	if s.transaction != nil {
+		return result.FromError(errTransactionStarted)
+	}
+
+	s.transaction = newTransaction(s.database.idGenerator.Generate())
+	s.database.logger.Debug("transaction started", zap.Int64("tx", s.transaction.id))
+	return result.OK()
+}
+
+func (s *Session) executeCommitQuery(ctx context.Context) result.Result {
+	if s.transaction == nil {
+		return result.FromError(errNoTransaction)
+	}
+
+	tx := s.transaction
+	s.transaction = nil
+
+	if err := s.database.storageLayer.Commit(ctx, tx.readSet(), tx.writeSet()); err != nil {
+		return result.FromError(err)
+	}
+
+	s.database.logger.Debug("transaction committed", zap.Int64("tx", tx.id))
+	return result.OK()
+}
+
+func (s *Session) executeRollbackQuery() result.Result {
+	if s.transaction == nil {
+		return result.FromError(errNoTransaction)
+	}
+
+	s.database.logger.Debug("transaction rolled back", zap.Int64("tx", s.transaction.id))
+	s.transaction = nil
+	return result.OK()
+}
+
+func (s *Session) executeSetQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	if len(arguments) != 2 {
+		return result.FromError(errNotAllowedTransaction)
+	}
+
+	s.transaction.set(arguments[0], arguments[1])
+	return result.OK()
+}
+
+func (s *Session) executeGetQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	value, err := s.transaction.get(ctx, s.database.storageLayer, arguments[0])
+	if errors.Is(err, storage.ErrNotFound) {
+		return result.NotFound()
+	} else if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.String(value)
+}
+
+// executeDelQuery removes keys of any type like Database.executeDelQuery.
+func (s *Session) executeDelQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	exists, err := s.transaction.exists(ctx, s.database.storageLayer, arguments[0])
+	if err != nil {
+		return result.FromError(err)
+	} else if !exists {
+		return result.Count(0)
+	}
+
+	s.transaction.del(arguments[0])
+	return result.Count(1)
+}
+
+// executeExistsQuery checks keys of any type like Database.executeExistsQuery.
+func (s *Session) executeExistsQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	exists, err := s.transaction.exists(ctx, s.database.storageLayer, arguments[0])
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.Bool(exists)
+}
+
+func (s *Session) executeMSetQuery(query compute.Query) result.Result {
+	arguments := query.Arguments()
+	for i := 0; i < len(arguments); i += 2 {
+		s.transaction.set(arguments[i], arguments[i+1])
+	}
+
+	return result.OK()
+}
+
+// executeMGetQuery returns nil for keys of other types like Database.executeMGetQuery.
+func (s *Session) executeMGetQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	values := make([]*string, len(arguments))
+	for i, key := range arguments {
+		value, err := s.transaction.get(ctx, s.database.storageLayer, key)
+		if errors.Is(err, storage.ErrNotFound) || result.CodeOf(err) == result.CodeWrongType {
+			continue
+		} else if err != nil {
+			return result.FromError(err)
+		}
+
+		values[i] = &value
+	}
+
+	return result.List(values)
+}
+
+// executeMDelQuery removes keys of any type like Database.executeMDelQuery.
+func (s *Session) executeMDelQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	deleted := make([]bool, len(arguments))
+	for i, key := range arguments {
+		exists, err := s.transaction.exists(ctx, s.database.storageLayer, key)
+		if err != nil {
+			return result.FromError(err)
+		} else if !exists {
+			continue
+		}
+
+		s.transaction.del(key)
+		deleted[i] = true
+	}
+
+	return result.List(boolValues(deleted))
+}
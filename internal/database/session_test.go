@@ -0,0 +1,255 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/storage"
+	"inmem-db-go/internal/database/storage/engine/in_memory"
+	"inmem-db-go/internal/database/txcontext"
+	"testing"
+)
+
+func newTestDatabase(t *testing.T, options ...DatabaseOption) *Database {
+	t.Helper()
+
+	parser, err := compute.NewParser(zap.NewNop())
+	require.NoError(t, err)
+	analyzer, err := compute.NewAnalyzer(zap.NewNop())
+	require.NoError(t, err)
+	computeLayer, err := compute.NewCompute(parser, analyzer, zap.NewNop())
+	require.NoError(t, err)
+
+	engine, err := in_memory.NewEngine(in_memory.HashTableBuilder, zap.NewNop())
+	require.NoError(t, err)
+	storageLayer, err := storage.NewStorage(engine, zap.NewNop())
+	require.NoError(t, err)
+
+	database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop(), options...)
+	require.NoError(t, err)
+	return database
+}
+
+func TestSessionTransactionCommit(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	computeLayer := NewMockcomputeLayer(ctrl)
+	computeLayer.EXPECT().HandleQuery(gomock.Any(), "BEGIN").
+		Return(compute.NewQuery(compute.BeginCommandID, []string{}), nil)
+	computeLayer.EXPECT().HandleQuery(gomock.Any(), "SET one 1").
+		Return(compute.NewQuery(compute.SetCommandID, []string{"one", "1"}), nil)
+	computeLayer.EXPECT().HandleQuery(gomock.Any(), "GET one").
+		Return(compute.NewQuery(compute.GetCommandID, []string{"one"}), nil)
+	computeLayer.EXPECT().HandleQuery(gomock.Any(), "DEL two").
+		Return(compute.NewQuery(compute.DelCommandID, []string{"two"}), nil)
+	computeLayer.EXPECT().HandleQuery(gomock.Any(), "COMMIT").
+		Return(compute.NewQuery(compute.CommitCommandID, []string{}), nil)
+
+	storageLayer := NewMockstorageLayer(ctrl)
+	storageLayer.EXPECT().Get(gomock.Any(), "two").Return("2", nil)
+	storageLayer.EXPECT().
+		Commit(gomock.Any(), []storage.KeyState{{Key: "two", Value: "2", Found: true}}, gomock.Len(2)).
+		Return(nil)
+
+	database, err := NewDatabase(computeLayer, storageLayer, zap.NewNop())
+	require.NoError(t, err)
+
+	session := database.NewSession()
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "SET one 1"))
+	assert.Equal(t, "[ok] 1", session.HandleQuery(ctx, "GET one"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "DEL two"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "COMMIT"))
+}
+
+func TestSessionTransactionErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	session := newTestDatabase(t).NewSession()
+
+	assert.Equal(t, "[error] transaction is not started", session.HandleQuery(ctx, "COMMIT"))
+	assert.Equal(t, "[error] transaction is not started", session.HandleQuery(ctx, "ROLLBACK"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[error] transaction is already started", session.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[error] command is not allowed in transaction", session.HandleQuery(ctx, "EXPIRE one 10"))
+	assert.Equal(t, "[error] command is not allowed in transaction", session.HandleQuery(ctx, "SET one 1 EX 10"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "ROLLBACK"))
+}
+
+func TestSessionTransactionIsolation(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	database := newTestDatabase(t)
+	session := database.NewSession()
+
+	assert.Equal(t, "[ok]", database.HandleQuery(ctx, "SET one 1"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "SET one 2"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "DEL one"))
+	assert.Equal(t, "[not found]", session.HandleQuery(ctx, "GET one"))
+	assert.Equal(t, "[ok] 0", session.HandleQuery(ctx, "EXISTS one"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "SET two 2"))
+
+	assert.Equal(t, "[ok] 1", database.HandleQuery(ctx, "GET one"))
+	assert.Equal(t, "[not found]", database.HandleQuery(ctx, "GET two"))
+
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "ROLLBACK"))
+	assert.Equal(t, "[ok] 1", database.HandleQuery(ctx, "GET one"))
+	assert.Equal(t, "[not found]", session.HandleQuery(ctx, "GET two"))
+
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "DEL one"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "SET two 2"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "COMMIT"))
+	assert.Equal(t, "[not found]", database.HandleQuery(ctx, "GET one"))
+	assert.Equal(t, "[ok] 2", database.HandleQuery(ctx, "GET two"))
+}
+
+func TestSessionTransactionConflict(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	database := newTestDatabase(t)
+	first := database.NewSession()
+	second := database.NewSession()
+
+	assert.Equal(t, "[ok]", database.HandleQuery(ctx, "SET balance 10"))
+
+	assert.Equal(t, "[ok]", first.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[ok]", second.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[ok] 10", first.HandleQuery(ctx, "GET balance"))
+	assert.Equal(t, "[ok] 10", second.HandleQuery(ctx, "GET balance"))
+	assert.Equal(t, "[ok]", first.HandleQuery(ctx, "SET balance 5"))
+	assert.Equal(t, "[ok]", second.HandleQuery(ctx, "SET balance 7"))
+
+	assert.Equal(t, "[ok]", first.HandleQuery(ctx, "COMMIT"))
+	assert.Equal(t, "[error] transaction conflict", second.HandleQuery(ctx, "COMMIT"))
+	assert.Equal(t, "[ok] 5", database.HandleQuery(ctx, "GET balance"))
+
+	// the failed transaction is finished, so a new one can be started
+	assert.Equal(t, "[ok]", second.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[ok]", second.HandleQuery(ctx, "ROLLBACK"))
+}
+
+func TestSessionTransactionKeysOfOtherTypes(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	database := newTestDatabase(t)
+	session := database.NewSession()
+
+	assert.Equal(t, "[ok] 1", database.HandleQuery(ctx, "HSET first field value"))
+	assert.Equal(t, "[ok] 1", database.HandleQuery(ctx, "HSET second field value"))
+	assert.Equal(t, "[ok] 1", database.HandleQuery(ctx, "HSET third field value"))
+
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[ok] 1", session.HandleQuery(ctx, "EXISTS first"))
+	assert.Equal(t, "[ok] (nil) (nil)", session.HandleQuery(ctx, "MGET first missing"))
+	assert.Equal(t,
+		"[error] operation against a key holding the wrong kind of value",
+		session.HandleQuery(ctx, "GET first"),
+	)
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "DEL first"))
+	assert.Equal(t, "[ok] 1 0 0", session.HandleQuery(ctx, "MDEL second missing first"))
+	assert.Equal(t, "[ok] 0", session.HandleQuery(ctx, "EXISTS first"))
+	assert.Equal(t, "[ok] 1", database.HandleQuery(ctx, "EXISTS first"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "COMMIT"))
+
+	assert.Equal(t, "[ok] 0", database.HandleQuery(ctx, "EXISTS first"))
+	assert.Equal(t, "[ok] 0", database.HandleQuery(ctx, "EXISTS second"))
+
+	// the key read as a hash was replaced by a string before the commit
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "DEL third"))
+	assert.Equal(t, "[ok]", database.HandleQuery(ctx, "DEL third"))
+	assert.Equal(t, "[ok]", database.HandleQuery(ctx, "SET third value"))
+	assert.Equal(t, "[error] transaction conflict", session.HandleQuery(ctx, "COMMIT"))
+	assert.Equal(t, "[ok] value", database.HandleQuery(ctx, "GET third"))
+}
+
+func TestTransactionQueriesRequireSession(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	database := newTestDatabase(t)
+
+	assert.Equal(t, "[error] transactions require a session", database.HandleQuery(ctx, "BEGIN"))
+}
+
+func TestSessionExecuteTokens(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	session := newTestDatabase(t).NewSession()
+
+	assert.Equal(t, result.OK(), session.ExecuteTokens(ctx, []string{"BEGIN"}))
+	assert.Equal(t, result.OK(), session.ExecuteTokens(ctx, []string{"SET", "key", "hello world"}))
+	assert.Equal(t, result.OK(), session.ExecuteTokens(ctx, []string{"COMMIT"}))
+	assert.Equal(t, result.String("hello world"), session.ExecuteTokens(ctx, []string{"GET", "key"}))
+	assert.Equal(t, result.OK(), session.ExecuteTokens(ctx, []string{"SET", "other", "", "ex", "10"}))
+	assert.Equal(t, result.Count(0), session.ExecuteTokens(ctx, []string{"DEL", "missing"}))
+
+	reply := session.ExecuteTokens(ctx, []string{})
+	assert.Equal(t, result.StatusError, reply.Status)
+	assert.Equal(t, result.CodeUnknownCommand, reply.Code)
+}
+
+func TestMultiKeyQueries(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	database := newTestDatabase(t)
+	session := database.NewSession()
+
+	assert.Equal(t, "[ok]", database.HandleQuery(ctx, "MSET one 1 two 2 one 3"))
+	assert.Equal(t, "[ok] 3 2 (nil)", database.HandleQuery(ctx, "MGET one two three"))
+	assert.Equal(t, "[ok] 1 0 0", database.HandleQuery(ctx, "MDEL one three one"))
+	assert.Equal(t, "[ok] (nil) 2", database.HandleQuery(ctx, "MGET one two"))
+	assert.Equal(t, "[error] invalid arguments", database.HandleQuery(ctx, "MSET one"))
+
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "BEGIN"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "MSET one 1 three 3"))
+	assert.Equal(t, "[ok] 1 0 1", session.HandleQuery(ctx, "MDEL two two three"))
+	assert.Equal(t, "[ok] 1 (nil) (nil)", session.HandleQuery(ctx, "MGET one two three"))
+	assert.Equal(t, "[ok] (nil) 2", database.HandleQuery(ctx, "MGET one two"))
+	assert.Equal(t, "[ok]", session.HandleQuery(ctx, "COMMIT"))
+	assert.Equal(t, "[ok] 1 (nil) (nil)", database.HandleQuery(ctx, "MGET one two three"))
+}
+
+func TestMSetIsAtomic(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	database := newTestDatabase(t)
+	require.Equal(t, "[ok]", database.HandleQuery(ctx, "MSET one 0 two 0"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 1000; i++ {
+			database.HandleQuery(ctx, fmt.Sprintf("MSET one %d two %d", i, i))
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		reply := database.Execute(ctx, "MGET one two")
+		require.Len(t, reply.Values, 2)
+		require.Equal(t, *reply.Values[0], *reply.Values[1])
+	}
+}
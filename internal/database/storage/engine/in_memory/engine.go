@@ -4,20 +4,97 @@ import (
 	"context"
 	"errors"
 	"go.uber.org/zap"
+	"inmem-db-go/internal/database/txcontext"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// sweeper checks a sample of volatile keys per round and starts
+	// another round right away while a noticeable part of them is expired
+	expirationSampleSize     = 20
+	expirationRepeatRatio    = 4
+	expirationRoundsPerCycle = 16
 )
 
 type hashTable interface {
 	Set(string, string)
+	SetWithExpiration(string, string, time.Time)
 	Get(string) (string, bool)
-	Del(string)
+	GetString(string) (string, bool, error)
+	Exists(string) bool
+	Del(string) bool
+	Update(string, func(string, bool, time.Time) (string, time.Time, error)) error
+	Replace(string, func(bool) (string, time.Time, error)) error
+	Expire(string, time.Time) bool
+	Persist(string) bool
+	Expiration(string) (time.Time, bool)
+	DelExpired(int) (int, int)
+	Evict(EvictionPolicy, int, func(string) error) (bool, error)
+	KeySize(string) int64
+	MemoryUsage() int64
+	Len() int
+	Shards() int
+	ScanShard(shard int, after string, first bool, count int) ([]string, string, bool)
+	Range(func(string, string, time.Time) bool)
+	HashGet(string, string) (string, bool, error)
+	HashGetAll(string) (map[string]string, error)
+	HashLen(string) (int, error)
+	HashUpdate(string, func(map[string]string) (map[string]string, []string, error)) error
+	RangeHashes(func(string, map[string]string, time.Time) bool)
+}
+
+// EngineStats is a snapshot of the engine counters.
+type EngineStats struct {
+	// Keys counts expired keys until they are reclaimed
+	Keys         int
+	KeysPerShard []int
+	UsedMemory   int64
+	MaxMemory    int64
+	EvictedKeys  int64
+}
+
+type EngineOption func(*Engine)
+
+// WithMaxMemory limits the approximate memory taken by the keys,
+// 0 means no limit. See MakeRoom.
+func WithMaxMemory(bytes int64) EngineOption {
+	return func(engine *Engine) {
+		engine.maxMemory = bytes
+	}
+}
+
+// WithEvictionPolicy sets the policy applied at the memory limit,
+// nil keeps the default noeviction policy.
+func WithEvictionPolicy(policy EvictionPolicy) EngineOption {
+	return func(engine *Engine) {
+		engine.evictionPolicy = policy
+	}
+}
+
+// WithEvictionSamples sets the number of keys the policy chooses from.
+func WithEvictionSamples(samples int) EngineOption {
+	return func(engine *Engine) {
+		engine.evictionSamples = samples
+	}
 }
 
+// Engine relies on the table for locking, so the table
+// returned by tableBuilder must be safe for concurrent use.
 type Engine struct {
-	hashTable hashTable
-	logger    *zap.Logger
+	// the table is replaced as a whole when a snapshot is restored
+	hashTable    atomic.Pointer[hashTable]
+	tableBuilder func() hashTable
+
+	maxMemory       int64
+	evictionPolicy  EvictionPolicy
+	evictionSamples int
+	evictedKeys     atomic.Int64
+
+	logger *zap.Logger
 }
 
-func NewEngine(tableBuilder func() hashTable, logger *zap.Logger) (*Engine, error) {
+func NewEngine(tableBuilder func() hashTable, logger *zap.Logger, options ...EngineOption) (*Engine, error) {
 	if tableBuilder == nil {
 		return nil, errors.New("hash table builder is invalid")
 	}
@@ -26,30 +103,329 @@ func NewEngine(tableBuilder func() hashTable, logger *zap.Logger) (*Engine, erro
 		return nil, errors.New("logger is invalid")
 	}
 
-	return &Engine{
-		hashTable: tableBuilder(),
-		logger:    logger,
-	}, nil
+	engine := &Engine{
+		tableBuilder:    tableBuilder,
+		evictionSamples: DefaultEvictionSamples,
+		logger:          logger,
+	}
+
+	for _, option := range options {
+		option(engine)
+	}
+
+	if engine.maxMemory < 0 {
+		return nil, errors.New("max memory is invalid")
+	}
+
+	if engine.evictionSamples <= 0 {
+		return nil, errors.New("eviction samples number is invalid")
+	}
+
+	engine.setTable(tableBuilder())
+	return engine, nil
 }
 
 func (e *Engine) Set(ctx context.Context, key, value string) {
-	e.hashTable.Set(key, value)
+	e.table().Set(key, value)
 
-	txID := ctx.Value("tx").(int64)
+	txID := txcontext.TxID(ctx)
 	e.logger.Debug("success set query", zap.Int64("tx", txID))
 }
 
+func (e *Engine) SetWithExpiration(ctx context.Context, key, value string, expiresAt time.Time) {
+	e.table().SetWithExpiration(key, value, expiresAt)
+
+	txID := txcontext.TxID(ctx)
+	e.logger.Debug("success set query", zap.Int64("tx", txID), zap.Time("expires_at", expiresAt))
+}
+
 func (e *Engine) Get(ctx context.Context, key string) (string, bool) {
-	value, found := e.hashTable.Get(key)
+	value, found := e.table().Get(key)
 
-	txID := ctx.Value("tx").(int64)
+	txID := txcontext.TxID(ctx)
 	e.logger.Debug("success get query", zap.Int64("tx", txID))
 	return value, found
 }
 
-func (e *Engine) Del(ctx context.Context, key string) {
-	e.hashTable.Del(key)
+// GetString fails with ErrWrongType for keys of other types, Get reports them as missing.
+func (e *Engine) GetString(ctx context.Context, key string) (string, bool, error) {
+	value, found, err := e.table().GetString(key)
+
+	txID := txcontext.TxID(ctx)
+	e.logger.Debug("success get query", zap.Int64("tx", txID))
+	return value, found, err
+}
+
+// Exists reports whether a key of any type exists.
+func (e *Engine) Exists(ctx context.Context, key string) bool {
+	found := e.table().Exists(key)
+
+	txID := txcontext.TxID(ctx)
+	e.logger.Debug("success exists query", zap.Int64("tx", txID), zap.Bool("found", found))
+	return found
+}
+
+// Del removes the key of any type and reports whether it existed.
+func (e *Engine) Del(ctx context.Context, key string) bool {
+	found := e.table().Del(key)
+
+	txID := txcontext.TxID(ctx)
+	e.logger.Debug("success del query", zap.Int64("tx", txID), zap.Bool("found", found))
+	return found
+}
+
+// Update replaces the value and the expiration of the key with the ones computed by fn
+// from the current ones atomically, see HashTable.Update. Missing keys are passed as not found.
+func (e *Engine) Update(ctx context.Context, key string, fn func(string, bool, time.Time) (string, time.Time, error)) error {
+	txID := txcontext.TxID(ctx)
+	if err := e.table().Update(key, fn); err != nil {
+		e.logger.Debug("failed update query", zap.Int64("tx", txID), zap.Error(err))
+		return err
+	}
+
+	e.logger.Debug("success update query", zap.Int64("tx", txID))
+	return nil
+}
+
+// Replace writes the value and the expiration computed by fn atomically,
+// see HashTable.Replace. Keys of any type are passed as existing.
+func (e *Engine) Replace(ctx context.Context, key string, fn func(bool) (string, time.Time, error)) error {
+	txID := txcontext.TxID(ctx)
+	if err := e.table().Replace(key, fn); err != nil {
+		e.logger.Debug("failed replace query", zap.Int64("tx", txID), zap.Error(err))
+		return err
+	}
+
+	e.logger.Debug("success replace query", zap.Int64("tx", txID))
+	return nil
+}
+
+func (e *Engine) Expire(ctx context.Context, key string, expiresAt time.Time) bool {
+	updated := e.table().Expire(key, expiresAt)
+
+	txID := txcontext.TxID(ctx)
+	e.logger.Debug("success expire query", zap.Int64("tx", txID), zap.Bool("updated", updated))
+	return updated
+}
+
+func (e *Engine) Persist(ctx context.Context, key string) bool {
+	updated := e.table().Persist(key)
+
+	txID := txcontext.TxID(ctx)
+	e.logger.Debug("success persist query", zap.Int64("tx", txID), zap.Bool("updated", updated))
+	return updated
+}
+
+func (e *Engine) Expiration(ctx context.Context, key string) (time.Time, bool) {
+	expiresAt, found := e.table().Expiration(key)
+
+	txID := txcontext.TxID(ctx)
+	e.logger.Debug("success expiration query", zap.Int64("tx", txID))
+	return expiresAt, found
+}
+
+// HashGet returns the value of the hash field, it fails with ErrWrongType for string keys.
+func (e *Engine) HashGet(ctx context.Context, key, field string) (string, bool, error) {
+	value, found, err := e.table().HashGet(key, field)
+
+	txID := txcontext.TxID(ctx)
+	e.logger.Debug("success hash get query", zap.Int64("tx", txID), zap.Bool("found", found))
+	return value, found, err
+}
+
+// HashGetAll returns a copy of the hash fields, nil for missing keys.
+func (e *Engine) HashGetAll(ctx context.Context, key string) (map[string]string, error) {
+	fields, err := e.table().HashGetAll(key)
+
+	txID := txcontext.TxID(ctx)
+	e.logger.Debug("success hash get all query", zap.Int64("tx", txID), zap.Int("fields", len(fields)))
+	return fields, err
+}
+
+func (e *Engine) HashLen(ctx context.Context, key string) (int, error) {
+	length, err := e.table().HashLen(key)
+
+	txID := txcontext.TxID(ctx)
+	e.logger.Debug("success hash len query", zap.Int64("tx", txID), zap.Int("fields", length))
+	return length, err
+}
+
+// HashUpdate sets and deletes the hash fields returned by fn atomically,
+// see HashTable.HashUpdate. It fails with ErrWrongType for string keys.
+func (e *Engine) HashUpdate(ctx context.Context, key string, fn func(map[string]string) (map[string]string, []string, error)) error {
+	txID := txcontext.TxID(ctx)
+	if err := e.table().HashUpdate(key, fn); err != nil {
+		e.logger.Debug("failed hash update query", zap.Int64("tx", txID), zap.Error(err))
+		return err
+	}
+
+	e.logger.Debug("success hash update query", zap.Int64("tx", txID))
+	return nil
+}
+
+// Keys returns the alive keys of all types in no particular order.
+func (e *Engine) Keys(ctx context.Context) []string {
+	var keys []string
+	table := e.table()
+	table.Range(func(key, _ string, _ time.Time) bool {
+		keys = append(keys, key)
+		return true
+	})
+
+	table.RangeHashes(func(key string, _ map[string]string, _ time.Time) bool {
+		keys = append(keys, key)
+		return true
+	})
+
+	txID := txcontext.TxID(ctx)
+	e.logger.Debug("success keys query", zap.Int64("tx", txID), zap.Int("keys", len(keys)))
+	return keys
+}
+
+// Scan returns about count alive keys starting at the cursor and the cursor
+// to continue with, StartCursor begins the scan and is returned at its end.
+// Shards are scanned one by one in key order, so every key present during
+// the whole scan is returned exactly once, keys added or removed meanwhile
+// may be missed. Every call visits a bounded number of keys, so the reply
+// may hold fewer keys than count, even none, before the end.
+func (e *Engine) Scan(ctx context.Context, cursor string, count int) ([]string, string, error) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+
+	txID := txcontext.TxID(ctx)
+	table := e.table()
+	position, err := parseScanCursor(cursor, table.Shards())
+	if err != nil {
+		e.logger.Debug("invalid scan cursor", zap.Int64("tx", txID), zap.String("cursor", cursor))
+		return nil, "", err
+	}
+
+	var keys []string
+	for len(keys) < count {
+		found, last, more := table.ScanShard(position.shard, position.after, !position.started, count-len(keys))
+		keys = append(keys, found...)
+		if more {
+			position = scanCursor{shard: position.shard, after: last, started: true}
+			break
+		}
+
+		// the shard is over, the scan goes on with the next one
+		position = scanCursor{shard: position.shard + 1}
+		if position.shard == table.Shards() {
+			e.logger.Debug("success scan query", zap.Int64("tx", txID), zap.Int("keys", len(keys)))
+			return keys, StartCursor, nil
+		}
+	}
+
+	e.logger.Debug("success scan query", zap.Int64("tx", txID), zap.Int("keys", len(keys)))
+	return keys, position.String(), nil
+}
+
+// Size returns the number of keys, expired keys are counted until they are reclaimed.
+func (e *Engine) Size(ctx context.Context) int {
+	size := e.table().Len()
+
+	txID := txcontext.TxID(ctx)
+	e.logger.Debug("success size query", zap.Int64("tx", txID), zap.Int("size", size))
+	return size
+}
+
+// MakeRoom evicts keys until the key with the value fits into the memory limit,
+// it fails with ErrOutOfMemory when the policy has nothing left to evict.
+// Overwriting a key is charged only the difference with its current size.
+// Unless nil, evict is called with every key before it is evicted, so the
+// eviction may be logged, the key is kept and MakeRoom fails if evict fails.
+// Concurrent writes may exceed the limit slightly, like in Redis
+// the limit is enforced before a write, not by the write itself.
+func (e *Engine) MakeRoom(ctx context.Context, key, value string, evict func(key string) error) error {
+	if e.maxMemory == 0 {
+		return nil
+	}
+
+	txID := txcontext.TxID(ctx)
+	size := entrySize(key, value)
+	table := e.table()
+	// the key itself may be evicted, so its size is checked on every round
+	for table.MemoryUsage()+size-table.KeySize(key) > e.maxMemory {
+		if size > e.maxMemory || e.evictionPolicy == nil {
+			e.logger.Debug("out of memory", zap.Int64("tx", txID), zap.Int64("used", table.MemoryUsage()))
+			return ErrOutOfMemory
+		}
+
+		evicted, err := table.Evict(e.evictionPolicy, e.evictionSamples, evict)
+		if err != nil {
+			return err
+		} else if !evicted {
+			e.logger.Debug("out of memory", zap.Int64("tx", txID), zap.Int64("used", table.MemoryUsage()))
+			return ErrOutOfMemory
+		}
+
+		e.evictedKeys.Add(1)
+	}
+
+	return nil
+}
+
+func (e *Engine) Stats() EngineStats {
+	table := e.table()
+	var keysPerShard []int
+	if sharded, ok := table.(interface{ ShardLens() []int }); ok {
+		keysPerShard = sharded.ShardLens()
+	} else {
+		keysPerShard = []int{table.Len()}
+	}
+
+	var keys int
+	for _, shardKeys := range keysPerShard {
+		keys += shardKeys
+	}
+
+	return EngineStats{
+		Keys:         keys,
+		KeysPerShard: keysPerShard,
+		UsedMemory:   table.MemoryUsage(),
+		MaxMemory:    e.maxMemory,
+		EvictedKeys:  e.evictedKeys.Load(),
+	}
+}
+
+// RunExpirationSweeper reclaims expired keys in the background until ctx is done.
+// Every cycle removes expired keys from small random samples, so the table is never
+// locked for a full scan.
+func (e *Engine) RunExpirationSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.sweepExpired()
+		}
+	}
+}
+
+func (e *Engine) sweepExpired() {
+	total := 0
+	for round := 0; round < expirationRoundsPerCycle; round++ {
+		checked, removed := e.table().DelExpired(expirationSampleSize)
+		total += removed
+		if checked == 0 || removed*expirationRepeatRatio < checked {
+			break
+		}
+	}
+
+	if total != 0 {
+		e.logger.Debug("expired keys removed", zap.Int("keys", total))
+	}
+}
+
+func (e *Engine) table() hashTable {
+	return *e.hashTable.Load()
+}
 
-	txID := ctx.Value("tx").(int64)
-	e.logger.Debug("success del query", zap.Int64("tx", txID))
+func (e *Engine) setTable(table hashTable) {
+	e.hashTable.Store(&table)
 }
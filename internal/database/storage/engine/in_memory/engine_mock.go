@@ -6,6 +6,7 @@ package in_memory
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,9 +35,11 @@ func (m *MockhashTable) EXPECT() *MockhashTableMockRecorder {
 }
 
 // Del mocks base method.
-func (m *MockhashTable) Del(arg0 string) {
+func (m *MockhashTable) Del(arg0 string) bool {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Del", arg0)
+	ret := m.ctrl.Call(m, "Del", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
 }
 
 // Del indicates an expected call of Del.
@@ -45,6 +48,79 @@ func (mr *MockhashTableMockRecorder) Del(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockhashTable)(nil).Del), arg0)
 }
 
+// DelExpired mocks base method.
+func (m *MockhashTable) DelExpired(arg0 int) (int, int) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DelExpired", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(int)
+	return ret0, ret1
+}
+
+// DelExpired indicates an expected call of DelExpired.
+func (mr *MockhashTableMockRecorder) DelExpired(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelExpired", reflect.TypeOf((*MockhashTable)(nil).DelExpired), arg0)
+}
+
+// Evict mocks base method.
+func (m *MockhashTable) Evict(arg0 EvictionPolicy, arg1 int, arg2 func(string) error) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Evict", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Evict indicates an expected call of Evict.
+func (mr *MockhashTableMockRecorder) Evict(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Evict", reflect.TypeOf((*MockhashTable)(nil).Evict), arg0, arg1, arg2)
+}
+
+// Exists mocks base method.
+func (m *MockhashTable) Exists(arg0 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exists", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Exists indicates an expected call of Exists.
+func (mr *MockhashTableMockRecorder) Exists(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockhashTable)(nil).Exists), arg0)
+}
+
+// Expiration mocks base method.
+func (m *MockhashTable) Expiration(arg0 string) (time.Time, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expiration", arg0)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Expiration indicates an expected call of Expiration.
+func (mr *MockhashTableMockRecorder) Expiration(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expiration", reflect.TypeOf((*MockhashTable)(nil).Expiration), arg0)
+}
+
+// Expire mocks base method.
+func (m *MockhashTable) Expire(arg0 string, arg1 time.Time) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expire", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Expire indicates an expected call of Expire.
+func (mr *MockhashTableMockRecorder) Expire(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expire", reflect.TypeOf((*MockhashTable)(nil).Expire), arg0, arg1)
+}
+
 // Get mocks base method.
 func (m *MockhashTable) Get(arg0 string) (string, bool) {
 	m.ctrl.T.Helper()
@@ -60,6 +136,192 @@ func (mr *MockhashTableMockRecorder) Get(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockhashTable)(nil).Get), arg0)
 }
 
+// GetString mocks base method.
+func (m *MockhashTable) GetString(arg0 string) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetString", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetString indicates an expected call of GetString.
+func (mr *MockhashTableMockRecorder) GetString(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetString", reflect.TypeOf((*MockhashTable)(nil).GetString), arg0)
+}
+
+// HashGet mocks base method.
+func (m *MockhashTable) HashGet(arg0, arg1 string) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HashGet", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// HashGet indicates an expected call of HashGet.
+func (mr *MockhashTableMockRecorder) HashGet(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashGet", reflect.TypeOf((*MockhashTable)(nil).HashGet), arg0, arg1)
+}
+
+// HashGetAll mocks base method.
+func (m *MockhashTable) HashGetAll(arg0 string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HashGetAll", arg0)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HashGetAll indicates an expected call of HashGetAll.
+func (mr *MockhashTableMockRecorder) HashGetAll(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashGetAll", reflect.TypeOf((*MockhashTable)(nil).HashGetAll), arg0)
+}
+
+// HashLen mocks base method.
+func (m *MockhashTable) HashLen(arg0 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HashLen", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HashLen indicates an expected call of HashLen.
+func (mr *MockhashTableMockRecorder) HashLen(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashLen", reflect.TypeOf((*MockhashTable)(nil).HashLen), arg0)
+}
+
+// HashUpdate mocks base method.
+func (m *MockhashTable) HashUpdate(arg0 string, arg1 func(map[string]string) (map[string]string, []string, error)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HashUpdate", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HashUpdate indicates an expected call of HashUpdate.
+func (mr *MockhashTableMockRecorder) HashUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashUpdate", reflect.TypeOf((*MockhashTable)(nil).HashUpdate), arg0, arg1)
+}
+
+// KeySize mocks base method.
+func (m *MockhashTable) KeySize(arg0 string) int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KeySize", arg0)
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// KeySize indicates an expected call of KeySize.
+func (mr *MockhashTableMockRecorder) KeySize(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeySize", reflect.TypeOf((*MockhashTable)(nil).KeySize), arg0)
+}
+
+// Len mocks base method.
+func (m *MockhashTable) Len() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Len")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Len indicates an expected call of Len.
+func (mr *MockhashTableMockRecorder) Len() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Len", reflect.TypeOf((*MockhashTable)(nil).Len))
+}
+
+// MemoryUsage mocks base method.
+func (m *MockhashTable) MemoryUsage() int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MemoryUsage")
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// MemoryUsage indicates an expected call of MemoryUsage.
+func (mr *MockhashTableMockRecorder) MemoryUsage() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MemoryUsage", reflect.TypeOf((*MockhashTable)(nil).MemoryUsage))
+}
+
+// Persist mocks base method.
+func (m *MockhashTable) Persist(arg0 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Persist", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Persist indicates an expected call of Persist.
+func (mr *MockhashTableMockRecorder) Persist(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Persist", reflect.TypeOf((*MockhashTable)(nil).Persist), arg0)
+}
+
+// Range mocks base method.
+func (m *MockhashTable) Range(arg0 func(string, string, time.Time) bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Range", arg0)
+}
+
+// Range indicates an expected call of Range.
+func (mr *MockhashTableMockRecorder) Range(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Range", reflect.TypeOf((*MockhashTable)(nil).Range), arg0)
+}
+
+// RangeHashes mocks base method.
+func (m *MockhashTable) RangeHashes(arg0 func(string, map[string]string, time.Time) bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RangeHashes", arg0)
+}
+
+// RangeHashes indicates an expected call of RangeHashes.
+func (mr *MockhashTableMockRecorder) RangeHashes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RangeHashes", reflect.TypeOf((*MockhashTable)(nil).RangeHashes), arg0)
+}
+
+// Replace mocks base method.
+func (m *MockhashTable) Replace(arg0 string, arg1 func(bool) (string, time.Time, error)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replace indicates an expected call of Replace.
+func (mr *MockhashTableMockRecorder) Replace(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockhashTable)(nil).Replace), arg0, arg1)
+}
+
+// ScanShard mocks base method.
+func (m *MockhashTable) ScanShard(shard int, after string, first bool, count int) ([]string, string, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScanShard", shard, after, first, count)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(bool)
+	return ret0, ret1, ret2
+}
+
+// ScanShard indicates an expected call of ScanShard.
+func (mr *MockhashTableMockRecorder) ScanShard(shard, after, first, count interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScanShard", reflect.TypeOf((*MockhashTable)(nil).ScanShard), shard, after, first, count)
+}
+
 // Set mocks base method.
 func (m *MockhashTable) Set(arg0, arg1 string) {
 	m.ctrl.T.Helper()
@@ -71,3 +333,43 @@ func (mr *MockhashTableMockRecorder) Set(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockhashTable)(nil).Set), arg0, arg1)
 }
+
+// SetWithExpiration mocks base method.
+func (m *MockhashTable) SetWithExpiration(arg0, arg1 string, arg2 time.Time) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetWithExpiration", arg0, arg1, arg2)
+}
+
+// SetWithExpiration indicates an expected call of SetWithExpiration.
+func (mr *MockhashTableMockRecorder) SetWithExpiration(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithExpiration", reflect.TypeOf((*MockhashTable)(nil).SetWithExpiration), arg0, arg1, arg2)
+}
+
+// Shards mocks base method.
+func (m *MockhashTable) Shards() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Shards")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Shards indicates an expected call of Shards.
+func (mr *MockhashTableMockRecorder) Shards() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shards", reflect.TypeOf((*MockhashTable)(nil).Shards))
+}
+
+// Update mocks base method.
+func (m *MockhashTable) Update(arg0 string, arg1 func(string, bool, time.Time) (string, time.Time, error)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockhashTableMockRecorder) Update(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockhashTable)(nil).Update), arg0, arg1)
+}
@@ -2,10 +2,13 @@ package in_memory
 
 import (
 	"context"
+	"fmt"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"inmem-db-go/internal/database/txcontext"
 	"testing"
+	"time"
 )
 
 // mockgen -source=engine.go -destination=engine_mock.go -package=in_memory
@@ -26,7 +29,7 @@ func TestNewEngine(t *testing.T) {
 func TestSetQuery(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	tableBuilder := func() hashTable {
 		ctrl := gomock.NewController(t)
@@ -44,7 +47,7 @@ func TestSetQuery(t *testing.T) {
 func TestGetQuery(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	tableBuilder := func() hashTable {
 		ctrl := gomock.NewController(t)
@@ -69,17 +72,78 @@ func TestGetQuery(t *testing.T) {
 func TestDelQuery(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	tableBuilder := func() hashTable {
 		ctrl := gomock.NewController(t)
 		table := NewMockhashTable(ctrl)
-		table.EXPECT().Del("key_1")
+		table.EXPECT().Del("key_1").Return(true)
+		table.EXPECT().Del("key_2").Return(false)
 		return table
 	}
 
 	engine, err := NewEngine(tableBuilder, zap.NewNop())
 	require.NoError(t, err)
 
-	engine.Del(ctx, "key_1")
+	require.True(t, engine.Del(ctx, "key_1"))
+	require.False(t, engine.Del(ctx, "key_2"))
+}
+
+func TestExpireQuery(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	expiresAt := time.Now().Add(time.Minute)
+
+	tableBuilder := func() hashTable {
+		ctrl := gomock.NewController(t)
+		table := NewMockhashTable(ctrl)
+		table.EXPECT().SetWithExpiration("key_1", "value_1", expiresAt)
+		table.EXPECT().Expire("key_1", expiresAt).Return(true)
+		table.EXPECT().Expiration("key_1").Return(expiresAt, true)
+		table.EXPECT().Persist("key_1").Return(true)
+		return table
+	}
+
+	engine, err := NewEngine(tableBuilder, zap.NewNop())
+	require.NoError(t, err)
+
+	engine.SetWithExpiration(ctx, "key_1", "value_1", expiresAt)
+	require.True(t, engine.Expire(ctx, "key_1", expiresAt))
+
+	deadline, found := engine.Expiration(ctx, "key_1")
+	require.True(t, found)
+	require.Equal(t, expiresAt, deadline)
+
+	require.True(t, engine.Persist(ctx, "key_1"))
+}
+
+func TestExpirationSweeper(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	engine, err := NewEngine(HashTableBuilder, zap.NewNop())
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		engine.SetWithExpiration(ctx, fmt.Sprintf("key_%d", i), "value", time.Now().Add(-time.Second))
+	}
+	engine.Set(ctx, "persistent", "value")
+
+	sweeperCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go engine.RunExpirationSweeper(sweeperCtx, time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		table := engine.table().(*HashTable)
+		table.mutex.RLock()
+		defer table.mutex.RUnlock()
+
+		return len(table.data) == 1
+	}, time.Second, time.Millisecond)
+
+	value, found := engine.Get(ctx, "persistent")
+	require.True(t, found)
+	require.Equal(t, "value", value)
 }
@@ -0,0 +1,159 @@
+package in_memory
+
+import (
+	"errors"
+	"inmem-db-go/internal/database/result"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultEvictionSamples = 5
+
+	// entryOverhead approximates the memory taken by a key besides its data:
+	// map slots, string headers and access statistics
+	entryOverhead = 96
+
+	// approximate LFU keeps a logarithmic counter like Redis does: new keys start
+	// with lfuInitialFrequency, so they are not evicted right away, the counter
+	// grows slower the higher it is and decays by one per lfuDecayPeriod of idleness
+	lfuInitialFrequency = 5
+	lfuLogFactor        = 10
+	lfuMaxFrequency     = 255
+	lfuDecayPeriod      = time.Minute
+)
+
+var ErrOutOfMemory = result.NewError(result.CodeOutOfMemory, "out of memory")
+
+// KeyStats describes a key sampled for eviction, zero ExpiresAt means a persistent key.
+type KeyStats struct {
+	ExpiresAt  time.Time
+	AccessedAt time.Time
+	Frequency  uint32
+}
+
+// EvictionPolicy chooses keys to evict once the engine reaches its memory limit.
+// Like in Redis the choice is approximate: the policy ranks a small random sample
+// of keys and the key with the highest score is evicted.
+type EvictionPolicy interface {
+	// Volatile reports whether only keys with expiration may be evicted.
+	Volatile() bool
+	Score(stats KeyStats, now time.Time) int64
+}
+
+// LRUPolicy evicts the least recently used keys.
+type LRUPolicy struct{}
+
+func (LRUPolicy) Volatile() bool {
+	return false
+}
+
+func (LRUPolicy) Score(stats KeyStats, now time.Time) int64 {
+	return int64(now.Sub(stats.AccessedAt))
+}
+
+// LFUPolicy evicts the least frequently used keys, the frequency
+// is approximated with a logarithmic counter that decays over time.
+type LFUPolicy struct{}
+
+func (LFUPolicy) Volatile() bool {
+	return false
+}
+
+func (LFUPolicy) Score(stats KeyStats, now time.Time) int64 {
+	decay := int64(now.Sub(stats.AccessedAt) / lfuDecayPeriod)
+	return -max(int64(stats.Frequency)-decay, 0)
+}
+
+// RandomPolicy evicts random keys.
+type RandomPolicy struct{}
+
+func (RandomPolicy) Volatile() bool {
+	return false
+}
+
+func (RandomPolicy) Score(KeyStats, time.Time) int64 {
+	return rand.Int63()
+}
+
+// VolatileTTLPolicy evicts keys with the nearest expiration,
+// keys without expiration are never evicted.
+type VolatileTTLPolicy struct{}
+
+func (VolatileTTLPolicy) Volatile() bool {
+	return true
+}
+
+func (VolatileTTLPolicy) Score(stats KeyStats, now time.Time) int64 {
+	return int64(now.Sub(stats.ExpiresAt))
+}
+
+// ParseEvictionPolicy returns the policy by its Redis name, the noeviction
+// policy is represented by nil: writes fail with ErrOutOfMemory at the limit.
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	switch name {
+	case "noeviction":
+		return nil, nil
+	case "allkeys-lru":
+		return LRUPolicy{}, nil
+	case "allkeys-lfu":
+		return LFUPolicy{}, nil
+	case "allkeys-random":
+		return RandomPolicy{}, nil
+	case "volatile-ttl":
+		return VolatileTTLPolicy{}, nil
+	}
+
+	return nil, errors.New("eviction policy is invalid")
+}
+
+// accessStats is updated atomically, so reads may update it under the read lock.
+type accessStats struct {
+	accessedAt atomic.Int64
+	frequency  atomic.Uint32
+}
+
+func newAccessStats(now time.Time) *accessStats {
+	stats := &accessStats{}
+	stats.accessedAt.Store(now.UnixNano())
+	stats.frequency.Store(lfuInitialFrequency)
+	return stats
+}
+
+func (s *accessStats) touch(now time.Time) {
+	s.accessedAt.Store(now.UnixNano())
+
+	frequency := s.frequency.Load()
+	if frequency >= lfuMaxFrequency {
+		return
+	}
+
+	// a lost increment under contention is fine for an approximate counter
+	base := max(int(frequency)-lfuInitialFrequency, 0)
+	if rand.Intn(base*lfuLogFactor+1) == 0 {
+		s.frequency.CompareAndSwap(frequency, frequency+1)
+	}
+}
+
+func (s *accessStats) keyStats(expiresAt time.Time) KeyStats {
+	return KeyStats{
+		ExpiresAt:  expiresAt,
+		AccessedAt: time.Unix(0, s.accessedAt.Load()),
+		Frequency:  s.frequency.Load(),
+	}
+}
+
+func entrySize(key, value string) int64 {
+	return int64(len(key) + len(value) + entryOverhead)
+}
+
+// hashSize counts every field of the hash as an entry of its own.
+func hashSize(key string, fields map[string]string) int64 {
+	size := entrySize(key, "")
+	for field, value := range fields {
+		size += entrySize(field, value)
+	}
+
+	return size
+}
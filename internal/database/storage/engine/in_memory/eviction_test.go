@@ -0,0 +1,261 @@
+package in_memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/txcontext"
+	"testing"
+	"time"
+)
+
+func TestMemoryUsage(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	table.Set("key", "value")
+	assert.Equal(t, entrySize("key", "value"), table.MemoryUsage())
+
+	table.SetWithExpiration("key", "longer value", time.Now().Add(time.Minute))
+	assert.Equal(t, entrySize("key", "longer value"), table.MemoryUsage())
+
+	table.Set("other", "")
+	table.Del("key")
+	assert.Equal(t, entrySize("other", ""), table.MemoryUsage())
+
+	table.SetWithExpiration("expired", "value", time.Now().Add(-time.Second))
+	table.DelExpired(10)
+	assert.Equal(t, entrySize("other", ""), table.MemoryUsage())
+}
+
+func TestEvictionPolicies(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	testCases := []struct {
+		name    string
+		policy  EvictionPolicy
+		evicted KeyStats
+		kept    KeyStats
+	}{
+		{
+			name:    "lru evicts idle keys",
+			policy:  LRUPolicy{},
+			evicted: KeyStats{AccessedAt: now.Add(-time.Hour)},
+			kept:    KeyStats{AccessedAt: now},
+		},
+		{
+			name:    "lfu evicts rarely used keys",
+			policy:  LFUPolicy{},
+			evicted: KeyStats{AccessedAt: now, Frequency: 5},
+			kept:    KeyStats{AccessedAt: now, Frequency: 20},
+		},
+		{
+			name:    "lfu frequency decays",
+			policy:  LFUPolicy{},
+			evicted: KeyStats{AccessedAt: now.Add(-time.Hour), Frequency: 20},
+			kept:    KeyStats{AccessedAt: now, Frequency: 10},
+		},
+		{
+			name:    "volatile-ttl evicts keys expiring first",
+			policy:  VolatileTTLPolicy{},
+			evicted: KeyStats{ExpiresAt: now.Add(time.Second)},
+			kept:    KeyStats{ExpiresAt: now.Add(time.Hour)},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Greater(t, tc.policy.Score(tc.evicted, now), tc.policy.Score(tc.kept, now))
+		})
+	}
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy, err := ParseEvictionPolicy("noeviction")
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+
+	policy, err = ParseEvictionPolicy("allkeys-lfu")
+	require.NoError(t, err)
+	assert.Equal(t, LFUPolicy{}, policy)
+
+	_, err = ParseEvictionPolicy("volatile-lru")
+	assert.Error(t, err)
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	for i := 0; i < 3; i++ {
+		table.Set(fmt.Sprintf("key_%d", i), "value")
+	}
+
+	table.access["key_0"].accessedAt.Store(time.Now().Add(-time.Hour).UnixNano())
+	evicted, err := table.Evict(LRUPolicy{}, 3, nil)
+	require.NoError(t, err)
+	require.True(t, evicted)
+
+	_, found := table.Get("key_0")
+	assert.False(t, found)
+	assert.Equal(t, 2*entrySize("key_1", "value"), table.MemoryUsage())
+}
+
+func TestEvictVolatileOnly(t *testing.T) {
+	t.Parallel()
+
+	table := NewShardedHashTable(4)
+	table.Set("persistent", "value")
+	evicted, err := table.Evict(VolatileTTLPolicy{}, DefaultEvictionSamples, nil)
+	require.NoError(t, err)
+	assert.False(t, evicted)
+
+	table.SetWithExpiration("volatile", "value", time.Now().Add(time.Hour))
+	evicted, err = table.Evict(VolatileTTLPolicy{}, DefaultEvictionSamples, nil)
+	require.NoError(t, err)
+	assert.True(t, evicted)
+
+	_, found := table.Get("volatile")
+	assert.False(t, found)
+	_, found = table.Get("persistent")
+	assert.True(t, found)
+}
+
+func TestMakeRoom(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	maxMemory := 10 * entrySize("key_0", "value")
+
+	testCases := []struct {
+		name          string
+		policy        EvictionPolicy
+		expectedError error
+	}{
+		{name: "noeviction", policy: nil, expectedError: ErrOutOfMemory},
+		{name: "allkeys-lru", policy: LRUPolicy{}},
+		{name: "allkeys-lfu", policy: LFUPolicy{}},
+		{name: "allkeys-random", policy: RandomPolicy{}},
+		{name: "volatile-ttl without volatile keys", policy: VolatileTTLPolicy{}, expectedError: ErrOutOfMemory},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			engine, err := NewEngine(
+				ShardedHashTableBuilder(4),
+				zap.NewNop(),
+				WithMaxMemory(maxMemory),
+				WithEvictionPolicy(tc.policy),
+			)
+			require.NoError(t, err)
+
+			for i := 0; i < 10; i++ {
+				require.NoError(t, engine.MakeRoom(ctx, fmt.Sprintf("key_%d", i), "value", nil))
+				engine.Set(ctx, fmt.Sprintf("key_%d", i), "value")
+			}
+
+			err = engine.MakeRoom(ctx, "key_a", "value", nil)
+			assert.ErrorIs(t, err, tc.expectedError)
+
+			stats := engine.Stats()
+			assert.Equal(t, 10-int(stats.EvictedKeys), stats.Keys)
+			assert.Len(t, stats.KeysPerShard, 4)
+			assert.LessOrEqual(t, stats.UsedMemory, maxMemory)
+			assert.Equal(t, maxMemory, stats.MaxMemory)
+			if tc.expectedError == nil {
+				assert.Equal(t, int64(1), stats.EvictedKeys)
+			} else {
+				assert.Zero(t, stats.EvictedKeys)
+			}
+		})
+	}
+}
+
+func TestMakeRoomForTooLargeValue(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	engine, err := NewEngine(HashTableBuilder, zap.NewNop(), WithMaxMemory(1024), WithEvictionPolicy(LRUPolicy{}))
+	require.NoError(t, err)
+
+	engine.Set(ctx, "key", "value")
+	assert.ErrorIs(t, engine.MakeRoom(ctx, "key", string(make([]byte, 1024)), nil), ErrOutOfMemory)
+
+	// nothing is evicted for a value that never fits
+	_, found := engine.Get(ctx, "key")
+	assert.True(t, found)
+}
+
+func TestMakeRoomForOverwrite(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	engine, err := NewEngine(
+		HashTableBuilder,
+		zap.NewNop(),
+		WithMaxMemory(2*entrySize("key_0", "value")),
+		WithEvictionPolicy(LRUPolicy{}),
+	)
+	require.NoError(t, err)
+
+	engine.Set(ctx, "key_1", "value")
+	engine.Set(ctx, "key_0", "value")
+
+	// a value of the same size replaces the current one in place
+	require.NoError(t, engine.MakeRoom(ctx, "key_0", "other", nil))
+	assert.Zero(t, engine.Stats().EvictedKeys)
+
+	// a larger value needs room for the difference only
+	require.NoError(t, engine.MakeRoom(ctx, "key_0", "larger", nil))
+	assert.Equal(t, int64(1), engine.Stats().EvictedKeys)
+	_, found := engine.Get(ctx, "key_0")
+	assert.True(t, found)
+}
+
+func TestMakeRoomCallsEvict(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	errFailed := errors.New("failed")
+	engine, err := NewEngine(
+		HashTableBuilder,
+		zap.NewNop(),
+		WithMaxMemory(entrySize("key_0", "value")),
+		WithEvictionPolicy(LRUPolicy{}),
+	)
+	require.NoError(t, err)
+
+	engine.Set(ctx, "key_0", "value")
+	err = engine.MakeRoom(ctx, "key_1", "value", func(key string) error {
+		assert.Equal(t, "key_0", key)
+		return errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+
+	// the key is kept when the eviction fails
+	_, found := engine.Get(ctx, "key_0")
+	assert.True(t, found)
+
+	var evicted []string
+	err = engine.MakeRoom(ctx, "key_1", "value", func(key string) error {
+		evicted = append(evicted, key)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key_0"}, evicted)
+
+	_, found = engine.Get(ctx, "key_0")
+	assert.False(t, found)
+}
@@ -0,0 +1,216 @@
+package in_memory
+
+import (
+	"inmem-db-go/internal/database/result"
+	"maps"
+	"time"
+)
+
+var ErrWrongType = result.NewError(result.CodeWrongType, "operation against a key holding the wrong kind of value")
+
+// GetString is Get which fails with ErrWrongType for keys of other types
+// instead of reporting them as missing.
+func (s *HashTable) GetString(key string) (string, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if _, isHash := s.hashes[key]; isHash && !s.isExpired(key, time.Now()) {
+		return "", false, ErrWrongType
+	}
+
+	value, found := s.get(key)
+	if stats := s.access[key]; found && stats != nil {
+		stats.touch(time.Now())
+	}
+
+	return value, found, nil
+}
+
+// Exists reports whether an alive key of any type is stored.
+func (s *HashTable) Exists(key string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.exists(key)
+}
+
+// HashGet returns the value of the hash field, missing keys have no fields.
+func (s *HashTable) HashGet(key, field string) (string, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	fields, err := s.hash(key)
+	if err != nil {
+		return "", false, err
+	}
+
+	value, found := fields[field]
+	return value, found, nil
+}
+
+// HashGetAll returns a copy of the hash fields, nil is returned for missing keys.
+func (s *HashTable) HashGetAll(key string) (map[string]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	fields, err := s.hash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return maps.Clone(fields), nil
+}
+
+func (s *HashTable) HashLen(key string) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	fields, err := s.hash(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(fields), nil
+}
+
+// HashUpdate sets and deletes the hash fields returned by fn under the lock,
+// fn gets the current fields, nil for a missing key, and must not modify them.
+// A missing key is created as a persistent hash, a hash left without fields
+// is removed. Nothing is changed if fn returns an error.
+func (s *HashTable) HashUpdate(key string, fn func(fields map[string]string) (map[string]string, []string, error)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fields, err := s.hash(key)
+	if err != nil {
+		return err
+	}
+
+	set, deleted, err := fn(fields)
+	if err != nil {
+		return err
+	}
+
+	if fields == nil {
+		if len(set) == 0 {
+			return nil
+		}
+
+		// an expired key is replaced by the new hash
+		s.remove(key)
+		fields = make(map[string]string, len(set))
+		if s.hashes == nil {
+			s.hashes = make(map[string]map[string]string)
+		}
+
+		if s.access == nil {
+			s.access = make(map[string]*accessStats)
+		}
+
+		s.hashes[key] = fields
+		s.index.insert(key)
+		s.access[key] = newAccessStats(time.Now())
+		s.used.Add(entrySize(key, ""))
+	}
+
+	for field, value := range set {
+		if previous, found := fields[field]; found {
+			s.used.Add(-entrySize(field, previous))
+		}
+
+		fields[field] = value
+		s.used.Add(entrySize(field, value))
+	}
+
+	for _, field := range deleted {
+		if value, found := fields[field]; found {
+			s.used.Add(-entrySize(field, value))
+			delete(fields, field)
+		}
+	}
+
+	if len(fields) == 0 {
+		s.remove(key)
+	}
+
+	return nil
+}
+
+// RangeHashes calls fn for every alive hash key until fn returns false,
+// zero expiration time is passed for persistent keys. The table is read
+// locked while fn is called, so fn must neither modify the table nor the fields.
+func (s *HashTable) RangeHashes(fn func(key string, fields map[string]string, expiresAt time.Time) bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+	for key, fields := range s.hashes {
+		if s.isExpired(key, now) {
+			continue
+		}
+
+		if !fn(key, fields, s.expires[key]) {
+			return
+		}
+	}
+}
+
+// hash returns the fields of the key and touches its access statistics,
+// nil is returned for missing keys and ErrWrongType for string keys.
+// The table must be locked.
+func (s *HashTable) hash(key string) (map[string]string, error) {
+	now := time.Now()
+	if s.isExpired(key, now) {
+		return nil, nil
+	}
+
+	if _, found := s.data[key]; found {
+		return nil, ErrWrongType
+	}
+
+	fields, found := s.hashes[key]
+	if stats := s.access[key]; found && stats != nil {
+		stats.touch(now)
+	}
+
+	return fields, nil
+}
+
+func (s *ShardedHashTable) GetString(key string) (string, bool, error) {
+	return s.shard(key).GetString(key)
+}
+
+func (s *ShardedHashTable) Exists(key string) bool {
+	return s.shard(key).Exists(key)
+}
+
+func (s *ShardedHashTable) HashGet(key, field string) (string, bool, error) {
+	return s.shard(key).HashGet(key, field)
+}
+
+func (s *ShardedHashTable) HashGetAll(key string) (map[string]string, error) {
+	return s.shard(key).HashGetAll(key)
+}
+
+func (s *ShardedHashTable) HashLen(key string) (int, error) {
+	return s.shard(key).HashLen(key)
+}
+
+func (s *ShardedHashTable) HashUpdate(key string, fn func(fields map[string]string) (map[string]string, []string, error)) error {
+	return s.shard(key).HashUpdate(key, fn)
+}
+
+// RangeHashes visits the shards one by one like Range.
+func (s *ShardedHashTable) RangeHashes(fn func(key string, fields map[string]string, expiresAt time.Time) bool) {
+	proceed := true
+	for _, shard := range s.shards {
+		shard.RangeHashes(func(key string, fields map[string]string, expiresAt time.Time) bool {
+			proceed = fn(key, fields, expiresAt)
+			return proceed
+		})
+
+		if !proceed {
+			return
+		}
+	}
+}
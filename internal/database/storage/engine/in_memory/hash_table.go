@@ -1,28 +1,398 @@
 package in_memory
 
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
 var HashTableBuilder = func() hashTable {
 	return NewHashTable()
 }
 
+// HashTable is a single map guarded by one lock, it is safe
+// for concurrent use but all queries contend on the same mutex.
 type HashTable struct {
-	data map[string]string
+	mutex sync.RWMutex
+	data  map[string]string
+	// hashes keeps keys of the hash type, a key
+	// is either in data or in hashes
+	hashes map[string]map[string]string
+	// expires keeps deadlines of volatile keys only, so
+	// the sweeper doesn't have to look at persistent ones
+	expires map[string]time.Time
+	// access keeps statistics for eviction policies, they
+	// are updated by reads under the read lock
+	access map[string]*accessStats
+	// index orders the keys of both types for scans
+	index keyIndex
+	// used is the approximate memory taken by the keys
+	used atomic.Int64
 }
 
 func NewHashTable() *HashTable {
 	return &HashTable{
-		data: make(map[string]string),
+		data:    make(map[string]string),
+		hashes:  make(map[string]map[string]string),
+		expires: make(map[string]time.Time),
+		access:  make(map[string]*accessStats),
 	}
 }
 
 func (s *HashTable) Set(key, value string) {
-	s.data[key] = value
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.store(key, value)
+	delete(s.expires, key)
+}
+
+func (s *HashTable) SetWithExpiration(key, value string, expiresAt time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.store(key, value)
+	s.expires[key] = expiresAt
 }
 
+// Get reports expired keys as missing, they are physically removed by DelExpired.
+// Keys of other types are reported as missing too, see GetString.
 func (s *HashTable) Get(key string) (string, bool) {
-	value, found := s.data[key]
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	value, found := s.get(key)
+	if stats := s.access[key]; found && stats != nil {
+		stats.touch(time.Now())
+	}
+
 	return value, found
 }
 
-func (s *HashTable) Del(key string) {
+// Del removes the key and reports whether it existed.
+func (s *HashTable) Del(key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	found := s.exists(key)
+	s.remove(key)
+	return found
+}
+
+func (s *HashTable) Expire(key string, expiresAt time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.exists(key) {
+		return false
+	}
+
+	s.expires[key] = expiresAt
+	return true
+}
+
+func (s *HashTable) Persist(key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.exists(key) {
+		return false
+	}
+
+	if _, found := s.expires[key]; !found {
+		return false
+	}
+
+	delete(s.expires, key)
+	return true
+}
+
+// Expiration returns the key deadline, zero time is returned for persistent keys.
+func (s *HashTable) Expiration(key string) (time.Time, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if !s.exists(key) {
+		return time.Time{}, false
+	}
+
+	return s.expires[key], true
+}
+
+// Update replaces the value and the expiration of the key with the ones returned
+// by fn under the lock, so concurrent updates of the key are applied one by one.
+// Zero expiration time makes the key persistent, nothing is changed if fn returns an error.
+// It fails with ErrWrongType for keys of other types without calling fn.
+func (s *HashTable) Update(key string, fn func(value string, found bool, expiresAt time.Time) (string, time.Time, error)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, isHash := s.hashes[key]; isHash && !s.isExpired(key, time.Now()) {
+		return ErrWrongType
+	}
+
+	value, found := s.get(key)
+	expiresAt := s.expires[key]
+	if !found {
+		expiresAt = time.Time{}
+	}
+
+	updated, expiresAt, err := fn(value, found, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	s.store(key, updated)
+	if expiresAt.IsZero() {
+		delete(s.expires, key)
+	} else {
+		s.expires[key] = expiresAt
+	}
+
+	return nil
+}
+
+// Replace writes the value and the expiration returned by fn under the lock like
+// Update, but a key of any type is replaced, fn only gets whether an alive key exists.
+func (s *HashTable) Replace(key string, fn func(exists bool) (string, time.Time, error)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, expiresAt, err := fn(s.exists(key))
+	if err != nil {
+		return err
+	}
+
+	s.store(key, value)
+	if expiresAt.IsZero() {
+		delete(s.expires, key)
+	} else {
+		s.expires[key] = expiresAt
+	}
+
+	return nil
+}
+
+// DelExpired checks at most limit volatile keys and removes the expired
+// ones. It returns the number of checked and removed keys.
+func (s *HashTable) DelExpired(limit int) (int, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	checked, removed := 0, 0
+	for key, expiresAt := range s.expires {
+		if checked == limit {
+			break
+		}
+
+		checked++
+		if !now.Before(expiresAt) {
+			s.remove(key)
+			removed++
+		}
+	}
+
+	return checked, removed
+}
+
+// Evict removes the key the policy ranks highest among at most samples keys,
+// expired keys are removed first. It returns false when there is nothing to evict.
+// Unless nil, evict is called with the victim under the lock before it is removed,
+// the victim is kept if evict fails.
+func (s *HashTable) Evict(policy EvictionPolicy, samples int, evict func(key string) error) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	var victim string
+	var victimScore int64
+	found := false
+	checked := 0
+	consider := func(key string) bool {
+		if s.isExpired(key, now) {
+			victim, found = key, true
+			return false
+		}
+
+		score := policy.Score(s.keyStats(key), now)
+		if !found || score > victimScore {
+			victim, victimScore, found = key, score, true
+		}
+
+		checked++
+		return checked < samples
+	}
+
+	// map iteration starts at a random position, so the sample is random
+	if policy.Volatile() {
+		for key := range s.expires {
+			if !consider(key) {
+				break
+			}
+		}
+	} else {
+		// the type sampled first is chosen at random too, so
+		// the keys of both types are evicted in proportion
+		hashesFirst := rand.Intn(len(s.data)+len(s.hashes)+1) < len(s.hashes)
+		proceed := true
+		if hashesFirst {
+			for key := range s.hashes {
+				if proceed = consider(key); !proceed {
+					break
+				}
+			}
+		}
+
+		for key := range s.data {
+			if !proceed {
+				break
+			}
+
+			proceed = consider(key)
+		}
+
+		for key := range s.hashes {
+			if hashesFirst || !proceed || !consider(key) {
+				break
+			}
+		}
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	if evict != nil {
+		if err := evict(victim); err != nil {
+			return false, err
+		}
+	}
+
+	s.remove(victim)
+	return true, nil
+}
+
+// KeySize returns the approximate memory taken by the key entry, 0 for
+// missing keys. Hash fields are not counted, so writes replacing a hash
+// are charged more than they take, which only makes eviction eager.
+func (s *HashTable) KeySize(key string) int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if value, found := s.data[key]; found {
+		return entrySize(key, value)
+	}
+
+	if _, found := s.hashes[key]; found {
+		return entrySize(key, "")
+	}
+
+	return 0
+}
+
+// MemoryUsage returns the approximate memory taken by the keys in bytes.
+func (s *HashTable) MemoryUsage() int64 {
+	return s.used.Load()
+}
+
+// Len returns the number of stored keys, expired keys are counted until they are removed.
+func (s *HashTable) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.data) + len(s.hashes)
+}
+
+// Range calls fn for every alive string key until fn returns false,
+// zero expiration time is passed for persistent keys.
+// The table is read locked while fn is called, so fn must not modify it.
+func (s *HashTable) Range(fn func(key, value string, expiresAt time.Time) bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+	for key, value := range s.data {
+		if s.isExpired(key, now) {
+			continue
+		}
+
+		if !fn(key, value, s.expires[key]) {
+			return
+		}
+	}
+}
+
+// store writes the value and resets its access statistics, the table must be locked.
+// A key of another type is replaced by the value.
+func (s *HashTable) store(key, value string) {
+	if _, found := s.hashes[key]; found {
+		s.remove(key)
+	}
+
+	if previous, found := s.data[key]; found {
+		s.used.Add(-entrySize(key, previous))
+	} else {
+		s.index.insert(key)
+	}
+
+	s.data[key] = value
+	s.used.Add(entrySize(key, value))
+	if s.access == nil {
+		s.access = make(map[string]*accessStats)
+	}
+
+	s.access[key] = newAccessStats(time.Now())
+}
+
+// remove deletes the key even if it is expired, the table must be locked.
+func (s *HashTable) remove(key string) {
+	if value, found := s.data[key]; found {
+		s.used.Add(-entrySize(key, value))
+		s.index.delete(key)
+	}
+
+	if fields, found := s.hashes[key]; found {
+		s.used.Add(-hashSize(key, fields))
+		s.index.delete(key)
+	}
+
 	delete(s.data, key)
+	delete(s.hashes, key)
+	delete(s.expires, key)
+	delete(s.access, key)
+}
+
+func (s *HashTable) keyStats(key string) KeyStats {
+	if stats := s.access[key]; stats != nil {
+		return stats.keyStats(s.expires[key])
+	}
+
+	return KeyStats{ExpiresAt: s.expires[key]}
+}
+
+func (s *HashTable) get(key string) (string, bool) {
+	if s.isExpired(key, time.Now()) {
+		return "", false
+	}
+
+	value, found := s.data[key]
+	return value, found
+}
+
+// exists reports whether an alive key of any type is stored, the table must be locked.
+func (s *HashTable) exists(key string) bool {
+	if s.isExpired(key, time.Now()) {
+		return false
+	}
+
+	_, isString := s.data[key]
+	_, isHash := s.hashes[key]
+	return isString || isHash
+}
+
+func (s *HashTable) isExpired(key string, now time.Time) bool {
+	expiresAt, found := s.expires[key]
+	return found && !now.Before(expiresAt)
 }
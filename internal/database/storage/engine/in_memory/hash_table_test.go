@@ -1,8 +1,11 @@
 package in_memory
 
 import (
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestSet(t *testing.T) {
@@ -95,3 +98,152 @@ func TestDel(t *testing.T) {
 		})
 	}
 }
+
+func TestDelReportsExistence(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	table.Set("key", "value")
+	table.Set("empty", "")
+	table.SetWithExpiration("expired", "value", time.Now().Add(-time.Second))
+
+	require.True(t, table.Del("key"))
+	require.False(t, table.Del("key"))
+	require.True(t, table.Del("empty"))
+	require.False(t, table.Del("expired"))
+	require.False(t, table.Del("missing"))
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	expiresAt := time.Now().Add(time.Hour)
+	table.SetWithExpiration("volatile", "1", expiresAt)
+	table.SetWithExpiration("expired", "1", time.Now().Add(-time.Second))
+
+	appendOne := func(value string, found bool, expiresAt time.Time) (string, time.Time, error) {
+		return value + "1", expiresAt, nil
+	}
+
+	require.NoError(t, table.Update("volatile", appendOne))
+	require.NoError(t, table.Update("expired", appendOne))
+	require.NoError(t, table.Update("missing", appendOne))
+
+	value, _ := table.Get("volatile")
+	require.Equal(t, "11", value)
+	deadline, _ := table.Expiration("volatile")
+	require.Equal(t, expiresAt, deadline)
+
+	value, _ = table.Get("expired")
+	require.Equal(t, "1", value)
+	deadline, _ = table.Expiration("expired")
+	require.True(t, deadline.IsZero())
+
+	value, _ = table.Get("missing")
+	require.Equal(t, "1", value)
+
+	err := table.Update("volatile", func(value string, found bool, _ time.Time) (string, time.Time, error) {
+		return value, time.Time{}, nil
+	})
+	require.NoError(t, err)
+	deadline, _ = table.Expiration("volatile")
+	require.True(t, deadline.IsZero())
+
+	errFailed := errors.New("failed")
+	err = table.Update("volatile", func(value string, found bool, _ time.Time) (string, time.Time, error) {
+		require.True(t, found)
+		return "", expiresAt, errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+	value, _ = table.Get("volatile")
+	require.Equal(t, "11", value)
+	deadline, _ = table.Expiration("volatile")
+	require.True(t, deadline.IsZero())
+}
+
+func TestRange(t *testing.T) {
+	t.Parallel()
+
+	table := &HashTable{
+		data: map[string]string{
+			"key_1": "value_1",
+			"key_2": "value_2",
+		},
+	}
+
+	visited := make(map[string]string)
+	table.Range(func(key, value string, expiresAt time.Time) bool {
+		visited[key] = value
+		return true
+	})
+	require.Equal(t, table.data, visited)
+
+	calls := 0
+	table.Range(func(string, string, time.Time) bool {
+		calls++
+		return false
+	})
+	require.Equal(t, 1, calls)
+}
+
+func TestExpiration(t *testing.T) {
+	t.Parallel()
+
+	past := time.Now().Add(-time.Second)
+	future := time.Now().Add(time.Hour)
+
+	table := NewHashTable()
+	table.Set("persistent", "value")
+	table.SetWithExpiration("volatile", "value", future)
+	table.SetWithExpiration("expired", "value", past)
+
+	_, found := table.Get("expired")
+	require.False(t, found)
+	value, found := table.Get("volatile")
+	require.True(t, found)
+	require.Equal(t, "value", value)
+
+	expiresAt, found := table.Expiration("persistent")
+	require.True(t, found)
+	require.True(t, expiresAt.IsZero())
+	expiresAt, found = table.Expiration("volatile")
+	require.True(t, found)
+	require.Equal(t, future, expiresAt)
+	_, found = table.Expiration("expired")
+	require.False(t, found)
+
+	require.False(t, table.Expire("expired", future))
+	require.False(t, table.Expire("missing", future))
+	require.True(t, table.Expire("persistent", future))
+
+	require.True(t, table.Persist("persistent"))
+	require.False(t, table.Persist("persistent"))
+	require.False(t, table.Persist("expired"))
+
+	table.Set("volatile", "new_value")
+	expiresAt, _ = table.Expiration("volatile")
+	require.True(t, expiresAt.IsZero())
+}
+
+func TestDelExpired(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	for i := 0; i < 10; i++ {
+		table.SetWithExpiration(fmt.Sprintf("expired_%d", i), "value", time.Now().Add(-time.Second))
+		table.SetWithExpiration(fmt.Sprintf("volatile_%d", i), "value", time.Now().Add(time.Hour))
+	}
+	table.Set("persistent", "value")
+
+	checked, removedFirst := table.DelExpired(5)
+	require.Equal(t, 5, checked)
+	require.LessOrEqual(t, removedFirst, 5)
+
+	checked, removed := table.DelExpired(100)
+	require.Equal(t, 20-removedFirst, checked)
+	require.Equal(t, 10-removedFirst, removed)
+
+	require.Len(t, table.data, 11)
+	require.Len(t, table.expires, 10)
+}
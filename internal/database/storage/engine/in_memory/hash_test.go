@@ -0,0 +1,200 @@
+package in_memory
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func setFields(fields map[string]string) func(map[string]string) (map[string]string, []string, error) {
+	return func(map[string]string) (map[string]string, []string, error) {
+		return fields, nil, nil
+	}
+}
+
+func deleteFields(fields ...string) func(map[string]string) (map[string]string, []string, error) {
+	return func(map[string]string) (map[string]string, []string, error) {
+		return nil, fields, nil
+	}
+}
+
+func TestHashUpdate(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	require.NoError(t, table.HashUpdate("hash", setFields(map[string]string{"field_1": "value_1", "field_2": "value_2"})))
+	require.NoError(t, table.HashUpdate("hash", setFields(map[string]string{"field_2": "updated"})))
+
+	value, found, err := table.HashGet("hash", "field_2")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "updated", value)
+
+	fields, err := table.HashGetAll("hash")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"field_1": "value_1", "field_2": "updated"}, fields)
+
+	// the copy doesn't share the fields with the table
+	fields["field_3"] = "value_3"
+	length, err := table.HashLen("hash")
+	require.NoError(t, err)
+	assert.Equal(t, 2, length)
+	assert.Equal(t, 1, table.Len())
+	assert.Equal(t, hashSize("hash", map[string]string{"field_1": "value_1", "field_2": "updated"}), table.MemoryUsage())
+
+	require.NoError(t, table.HashUpdate("hash", deleteFields("field_1", "missing")))
+	_, found, err = table.HashGet("hash", "field_1")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// the key is removed with the last field
+	require.NoError(t, table.HashUpdate("hash", deleteFields("field_2")))
+	assert.False(t, table.Exists("hash"))
+	assert.Equal(t, 0, table.Len())
+	assert.Equal(t, int64(0), table.MemoryUsage())
+
+	// nothing is created without fields
+	require.NoError(t, table.HashUpdate("empty", setFields(nil)))
+	assert.False(t, table.Exists("empty"))
+}
+
+func TestHashUpdateError(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	require.NoError(t, table.HashUpdate("hash", setFields(map[string]string{"field": "value"})))
+
+	err := table.HashUpdate("hash", func(fields map[string]string) (map[string]string, []string, error) {
+		assert.Equal(t, map[string]string{"field": "value"}, fields)
+		return map[string]string{"field": "updated"}, nil, ErrOutOfMemory
+	})
+	require.ErrorIs(t, err, ErrOutOfMemory)
+
+	value, _, err := table.HashGet("hash", "field")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+}
+
+func TestHashWrongType(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	table.Set("string", "value")
+	require.NoError(t, table.HashUpdate("hash", setFields(map[string]string{"field": "value"})))
+
+	_, _, err := table.HashGet("string", "field")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = table.HashGetAll("string")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = table.HashLen("string")
+	assert.ErrorIs(t, err, ErrWrongType)
+	assert.ErrorIs(t, table.HashUpdate("string", setFields(map[string]string{"field": "value"})), ErrWrongType)
+
+	_, found := table.Get("hash")
+	assert.False(t, found)
+	_, _, err = table.GetString("hash")
+	assert.ErrorIs(t, err, ErrWrongType)
+	err = table.Update("hash", func(string, bool, time.Time) (string, time.Time, error) {
+		return "value", time.Time{}, nil
+	})
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	value, found, err := table.GetString("string")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "value", value)
+
+	// set replaces a key of any type
+	table.Set("hash", "value")
+	value, found, err = table.GetString("hash")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, entrySize("string", "value")+entrySize("hash", "value"), table.MemoryUsage())
+}
+
+func TestHashExpiration(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	require.NoError(t, table.HashUpdate("hash", setFields(map[string]string{"field": "value"})))
+	require.True(t, table.Expire("hash", time.Now().Add(time.Hour)))
+
+	// updates keep the expiration
+	require.NoError(t, table.HashUpdate("hash", setFields(map[string]string{"other": "value"})))
+	expiresAt, found := table.Expiration("hash")
+	require.True(t, found)
+	assert.False(t, expiresAt.IsZero())
+
+	require.True(t, table.Expire("hash", time.Now().Add(-time.Second)))
+	assert.False(t, table.Exists("hash"))
+	_, found, err := table.HashGet("hash", "field")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// an expired hash is replaced by a new persistent one
+	require.NoError(t, table.HashUpdate("hash", setFields(map[string]string{"new": "value"})))
+	fields, err := table.HashGetAll("hash")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"new": "value"}, fields)
+	expiresAt, found = table.Expiration("hash")
+	require.True(t, found)
+	assert.True(t, expiresAt.IsZero())
+
+	require.True(t, table.Expire("hash", time.Now().Add(-time.Second)))
+	checked, removed := table.DelExpired(10)
+	assert.Equal(t, 1, checked)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, 0, table.Len())
+	assert.Equal(t, int64(0), table.MemoryUsage())
+}
+
+func TestHashScanAndEvict(t *testing.T) {
+	t.Parallel()
+
+	table := NewShardedHashTable(4)
+	table.Set("string", "value")
+	require.NoError(t, table.HashUpdate("hash", setFields(map[string]string{"field": "value"})))
+
+	var keys []string
+	for shard := 0; shard < table.Shards(); shard++ {
+		found, _, more := table.ScanShard(shard, "", true, 10)
+		assert.False(t, more)
+		keys = append(keys, found...)
+	}
+	assert.ElementsMatch(t, []string{"string", "hash"}, keys)
+
+	for _, expected := range []bool{true, true, false} {
+		evicted, err := table.Evict(RandomPolicy{}, DefaultEvictionSamples, nil)
+		require.NoError(t, err)
+		assert.Equal(t, expected, evicted)
+	}
+	assert.Equal(t, int64(0), table.MemoryUsage())
+}
+
+func TestReplaceHash(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	require.NoError(t, table.HashUpdate("hash", setFields(map[string]string{"field": "value"})))
+
+	err := table.Replace("hash", func(exists bool) (string, time.Time, error) {
+		assert.True(t, exists)
+		return "value", time.Time{}, nil
+	})
+	require.NoError(t, err)
+
+	value, found, err := table.GetString("hash")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, entrySize("hash", "value"), table.MemoryUsage())
+
+	err = table.Replace("missing", func(exists bool) (string, time.Time, error) {
+		assert.False(t, exists)
+		return "", time.Time{}, ErrOutOfMemory
+	})
+	require.ErrorIs(t, err, ErrOutOfMemory)
+	assert.False(t, table.Exists("missing"))
+}
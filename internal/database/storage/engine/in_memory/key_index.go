@@ -0,0 +1,110 @@
+package in_memory
+
+import "math/rand"
+
+const (
+	keyIndexMaxLevel = 32
+	// every level links about a quarter of the nodes of the level below
+	keyIndexLevelRatio = 4
+)
+
+// keyIndex keeps the keys of a table ordered in a skip list, so that
+// a scan resumes after any key in O(log n) instead of sorting the table
+// on every call. The zero value is an empty index, the table lock guards it.
+type keyIndex struct {
+	head  keyIndexNode
+	level int
+}
+
+type keyIndexNode struct {
+	key  string
+	next []*keyIndexNode
+}
+
+// insert adds the key unless it is already indexed.
+func (i *keyIndex) insert(key string) {
+	if i.head.next == nil {
+		i.head.next = make([]*keyIndexNode, keyIndexMaxLevel)
+	}
+
+	var previous [keyIndexMaxLevel]*keyIndexNode
+	node := i.findPrevious(key, &previous)
+	if next := node.next[0]; next != nil && next.key == key {
+		return
+	}
+
+	level := randomKeyIndexLevel()
+	for ; i.level < level; i.level++ {
+		previous[i.level] = &i.head
+	}
+
+	inserted := &keyIndexNode{key: key, next: make([]*keyIndexNode, level)}
+	for l := 0; l < level; l++ {
+		inserted.next[l] = previous[l].next[l]
+		previous[l].next[l] = inserted
+	}
+}
+
+// delete removes the key if it is indexed.
+func (i *keyIndex) delete(key string) {
+	if i.level == 0 {
+		return
+	}
+
+	var previous [keyIndexMaxLevel]*keyIndexNode
+	node := i.findPrevious(key, &previous).next[0]
+	if node == nil || node.key != key {
+		return
+	}
+
+	for l := range node.next {
+		previous[l].next[l] = node.next[l]
+	}
+
+	for i.level > 0 && i.head.next[i.level-1] == nil {
+		i.level--
+	}
+}
+
+// seek returns the node of the smallest key greater than after,
+// or of the smallest key when first is set, nil if there is none.
+func (i *keyIndex) seek(after string, first bool) *keyIndexNode {
+	if i.level == 0 {
+		return nil
+	}
+
+	node := &i.head
+	if !first {
+		for level := i.level - 1; level >= 0; level-- {
+			for node.next[level] != nil && node.next[level].key <= after {
+				node = node.next[level]
+			}
+		}
+	}
+
+	return node.next[0]
+}
+
+// findPrevious returns the last node with a key less than the key
+// and fills previous with such nodes of every level in use.
+func (i *keyIndex) findPrevious(key string, previous *[keyIndexMaxLevel]*keyIndexNode) *keyIndexNode {
+	node := &i.head
+	for level := i.level - 1; level >= 0; level-- {
+		for node.next[level] != nil && node.next[level].key < key {
+			node = node.next[level]
+		}
+
+		previous[level] = node
+	}
+
+	return node
+}
+
+func randomKeyIndexLevel() int {
+	level := 1
+	for level < keyIndexMaxLevel && rand.Intn(keyIndexLevelRatio) == 0 {
+		level++
+	}
+
+	return level
+}
@@ -0,0 +1,57 @@
+package in_memory
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func indexedKeys(index *keyIndex, after string, first bool) []string {
+	var keys []string
+	for node := index.seek(after, first); node != nil; node = node.next[0] {
+		keys = append(keys, node.key)
+	}
+
+	return keys
+}
+
+func TestKeyIndex(t *testing.T) {
+	t.Parallel()
+
+	var index keyIndex
+	assert.Nil(t, index.seek("", true))
+	index.delete("missing")
+
+	expected := make(map[string]struct{})
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key_%d", rand.Intn(500))
+		if rand.Intn(3) == 0 {
+			index.delete(key)
+			delete(expected, key)
+		} else {
+			index.insert(key)
+			expected[key] = struct{}{}
+		}
+	}
+
+	sorted := make([]string, 0, len(expected))
+	for key := range expected {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	assert.Equal(t, sorted, indexedKeys(&index, "", true))
+	assert.Equal(t, sorted[1:], indexedKeys(&index, sorted[0], false))
+	// the key to continue after doesn't have to be indexed
+	assert.Equal(t, sorted[1:], indexedKeys(&index, sorted[0]+"\x00", false))
+	assert.Empty(t, indexedKeys(&index, sorted[len(sorted)-1], false))
+
+	for _, key := range sorted {
+		index.delete(key)
+	}
+
+	assert.Nil(t, index.seek("", true))
+	assert.Zero(t, index.level)
+}
@@ -0,0 +1,103 @@
+package in_memory
+
+import (
+	"encoding/hex"
+	"inmem-db-go/internal/database/result"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// StartCursor starts a scan and is returned once the scan is complete.
+	StartCursor      = "0"
+	DefaultScanCount = 10
+
+	// scanVisitsPerKey bounds the keys a scan call visits per requested key,
+	// expired keys which are not reclaimed yet are visited but not returned
+	scanVisitsPerKey = 10
+)
+
+// cursorSeparator divides the shard and the last returned key of a cursor,
+// the underscore is a word symbol of the parser regardless of its settings
+const cursorSeparator = "_"
+
+var ErrInvalidCursor = result.NewError(result.CodeInvalidArgument, "invalid cursor")
+
+// scanCursor points into the keys of a shard ordered ascending:
+// the scan continues after the key, or from the smallest key
+// of the shard when the scan of the shard has not started.
+type scanCursor struct {
+	shard   int
+	after   string
+	started bool
+}
+
+// String encodes the cursor as "shard" or "shard_hex(key)", keys are
+// hex encoded so that any of them can be passed back as a plain word.
+func (c scanCursor) String() string {
+	if !c.started {
+		return strconv.Itoa(c.shard)
+	}
+
+	return strconv.Itoa(c.shard) + cursorSeparator + hex.EncodeToString([]byte(c.after))
+}
+
+func parseScanCursor(cursor string, shards int) (scanCursor, error) {
+	shardPart, keyPart, started := strings.Cut(cursor, cursorSeparator)
+	shard, err := strconv.Atoi(shardPart)
+	if err != nil || shard < 0 || shard >= shards {
+		return scanCursor{}, ErrInvalidCursor
+	}
+
+	after, err := hex.DecodeString(keyPart)
+	if err != nil {
+		return scanCursor{}, ErrInvalidCursor
+	}
+
+	return scanCursor{shard: shard, after: string(after), started: started}, nil
+}
+
+func (s *HashTable) Shards() int {
+	return 1
+}
+
+func (s *HashTable) ScanShard(_ int, after string, first bool, count int) ([]string, string, bool) {
+	return s.Scan(after, first, count)
+}
+
+func (s *ShardedHashTable) Shards() int {
+	return len(s.shards)
+}
+
+func (s *ShardedHashTable) ScanShard(shard int, after string, first bool, count int) ([]string, string, bool) {
+	return s.shards[shard].Scan(after, first, count)
+}
+
+// Scan returns up to count alive keys which are greater than after, or from the
+// smallest one when first is set, in ascending order. It resumes in the ordered
+// key index and visits at most scanVisitsPerKey*count keys, so every call holds
+// the read lock for a bounded time even if most of the keys are expired. The last
+// visited key is returned to continue with and whether keys may be left after it.
+func (s *HashTable) Scan(after string, first bool, count int) ([]string, string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+	keys := make([]string, 0, count)
+	last := after
+	visits := 0
+	for node := s.index.seek(after, first); node != nil; node = node.next[0] {
+		if len(keys) == count || visits == scanVisitsPerKey*count {
+			return keys, last, true
+		}
+
+		visits++
+		last = node.key
+		if !s.isExpired(node.key, now) {
+			keys = append(keys, node.key)
+		}
+	}
+
+	return keys, last, false
+}
@@ -0,0 +1,159 @@
+package in_memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	table := NewShardedHashTable(4)
+	for i := 0; i < 100; i++ {
+		table.Set(fmt.Sprintf("key_%d", i), "value")
+	}
+
+	table.SetWithExpiration("expired", "value", time.Now().Add(-time.Second))
+
+	engine, err := NewEngine(func() hashTable { return table }, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, 101, engine.Size(ctx))
+
+	seen := make(map[string]int)
+	cursor := StartCursor
+	for {
+		keys, next, err := engine.Scan(ctx, cursor, 7)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(keys), 7)
+		for _, key := range keys {
+			seen[key]++
+		}
+
+		// keys written during the scan don't break it
+		table.Set(fmt.Sprintf("new_%d", len(seen)), "value")
+		table.Del("key_0")
+
+		cursor = next
+		if cursor == StartCursor {
+			break
+		}
+	}
+
+	for i := 1; i < 100; i++ {
+		assert.Equal(t, 1, seen[fmt.Sprintf("key_%d", i)])
+	}
+
+	assert.NotContains(t, seen, "expired")
+}
+
+func TestScanInvalidCursor(t *testing.T) {
+	t.Parallel()
+
+	engine, err := NewEngine(func() hashTable { return NewShardedHashTable(4) }, zap.NewNop())
+	require.NoError(t, err)
+
+	for _, cursor := range []string{"", "4", "-1", "one", "1_zz"} {
+		_, _, err := engine.Scan(context.Background(), cursor, 10)
+		assert.ErrorIs(t, err, ErrInvalidCursor, cursor)
+	}
+}
+
+func TestScanCursor(t *testing.T) {
+	t.Parallel()
+
+	cursor := scanCursor{shard: 2, after: "key 1", started: true}
+	parsed, err := parseScanCursor(cursor.String(), 4)
+	require.NoError(t, err)
+	assert.Equal(t, cursor, parsed)
+
+	parsed, err = parseScanCursor(StartCursor, 4)
+	require.NoError(t, err)
+	assert.Equal(t, scanCursor{}, parsed)
+}
+
+func TestScanVisitsBoundedNumberOfKeys(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	for i := 0; i < 100; i++ {
+		table.SetWithExpiration(fmt.Sprintf("expired_%02d", i), "value", time.Now().Add(-time.Second))
+	}
+
+	table.Set("key", "value")
+
+	// a call stops after the bounded number of expired keys
+	keys, last, more := table.Scan("", true, 1)
+	assert.Empty(t, keys)
+	assert.Equal(t, fmt.Sprintf("expired_%02d", scanVisitsPerKey-1), last)
+	assert.True(t, more)
+
+	engine, err := NewEngine(func() hashTable { return table }, zap.NewNop())
+	require.NoError(t, err)
+
+	var found []string
+	calls := 0
+	cursor := StartCursor
+	for {
+		keys, next, err := engine.Scan(context.Background(), cursor, 1)
+		require.NoError(t, err)
+		found = append(found, keys...)
+		calls++
+
+		cursor = next
+		if cursor == StartCursor {
+			break
+		}
+	}
+
+	assert.Equal(t, []string{"key"}, found)
+	assert.Equal(t, 100/scanVisitsPerKey+1, calls)
+}
+
+func TestScanLetsWritersProgress(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	table := NewHashTable()
+	for i := 0; i < 50000; i++ {
+		table.Set(fmt.Sprintf("key_%d", i), "value")
+	}
+
+	engine, err := NewEngine(func() hashTable { return table }, zap.NewNop())
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		cursor := StartCursor
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			_, next, err := engine.Scan(ctx, cursor, 10)
+			require.NoError(t, err)
+			cursor = next
+		}
+	}()
+
+	// every scan call holds the lock for a short time only,
+	// so writes keep going while the table is scanned in a loop
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		table.Set(fmt.Sprintf("new_%d", i), "value")
+	}
+	elapsed := time.Since(start)
+
+	close(done)
+	<-stopped
+	assert.Less(t, elapsed, time.Second)
+}
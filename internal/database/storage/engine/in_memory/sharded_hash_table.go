@@ -0,0 +1,175 @@
+package in_memory
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const DefaultShardsNumber = 16
+
+// ShardedHashTableBuilder returns a table builder for NewEngine
+// which partitions keys across shardsNumber independently locked shards.
+func ShardedHashTableBuilder(shardsNumber int) func() hashTable {
+	return func() hashTable {
+		return NewShardedHashTable(shardsNumber)
+	}
+}
+
+// ShardedHashTable spreads keys across several HashTable shards by the key
+// hash, so queries to different shards don't contend on the same lock.
+type ShardedHashTable struct {
+	shards []*HashTable
+	// shard the next DelExpired call starts with,
+	// so every shard is eventually swept
+	nextSweep atomic.Uint32
+	// shard the next Evict call starts with
+	nextEviction atomic.Uint32
+}
+
+func NewShardedHashTable(shardsNumber int) *ShardedHashTable {
+	if shardsNumber < 1 {
+		shardsNumber = 1
+	}
+
+	shards := make([]*HashTable, shardsNumber)
+	for i := range shards {
+		shards[i] = NewHashTable()
+	}
+
+	return &ShardedHashTable{
+		shards: shards,
+	}
+}
+
+func (s *ShardedHashTable) Set(key, value string) {
+	s.shard(key).Set(key, value)
+}
+
+func (s *ShardedHashTable) SetWithExpiration(key, value string, expiresAt time.Time) {
+	s.shard(key).SetWithExpiration(key, value, expiresAt)
+}
+
+func (s *ShardedHashTable) Get(key string) (string, bool) {
+	return s.shard(key).Get(key)
+}
+
+func (s *ShardedHashTable) Del(key string) bool {
+	return s.shard(key).Del(key)
+}
+
+func (s *ShardedHashTable) Update(key string, fn func(value string, found bool, expiresAt time.Time) (string, time.Time, error)) error {
+	return s.shard(key).Update(key, fn)
+}
+
+func (s *ShardedHashTable) Replace(key string, fn func(exists bool) (string, time.Time, error)) error {
+	return s.shard(key).Replace(key, fn)
+}
+
+func (s *ShardedHashTable) Expire(key string, expiresAt time.Time) bool {
+	return s.shard(key).Expire(key, expiresAt)
+}
+
+func (s *ShardedHashTable) Persist(key string) bool {
+	return s.shard(key).Persist(key)
+}
+
+func (s *ShardedHashTable) Expiration(key string) (time.Time, bool) {
+	return s.shard(key).Expiration(key)
+}
+
+// DelExpired checks at most limit volatile keys going through the shards
+// one by one, only one shard is locked at a time.
+func (s *ShardedHashTable) DelExpired(limit int) (int, int) {
+	start := int(s.nextSweep.Add(1)-1) % len(s.shards)
+
+	checked, removed := 0, 0
+	for i := 0; i < len(s.shards) && checked < limit; i++ {
+		shardChecked, shardRemoved := s.shards[(start+i)%len(s.shards)].DelExpired(limit - checked)
+		checked += shardChecked
+		removed += shardRemoved
+	}
+
+	return checked, removed
+}
+
+// Evict removes a key from the first shard that has one to evict,
+// starting with the next shard on every call.
+func (s *ShardedHashTable) Evict(policy EvictionPolicy, samples int, evict func(key string) error) (bool, error) {
+	start := int(s.nextEviction.Add(1)-1) % len(s.shards)
+	for i := 0; i < len(s.shards); i++ {
+		evicted, err := s.shards[(start+i)%len(s.shards)].Evict(policy, samples, evict)
+		if err != nil || evicted {
+			return evicted, err
+		}
+	}
+
+	return false, nil
+}
+
+func (s *ShardedHashTable) KeySize(key string) int64 {
+	return s.shard(key).KeySize(key)
+}
+
+func (s *ShardedHashTable) MemoryUsage() int64 {
+	var used int64
+	for _, shard := range s.shards {
+		used += shard.MemoryUsage()
+	}
+
+	return used
+}
+
+func (s *ShardedHashTable) Len() int {
+	var length int
+	for _, shard := range s.shards {
+		length += shard.Len()
+	}
+
+	return length
+}
+
+// ShardLens returns the number of keys of every shard.
+func (s *ShardedHashTable) ShardLens() []int {
+	lens := make([]int, len(s.shards))
+	for i, shard := range s.shards {
+		lens[i] = shard.Len()
+	}
+
+	return lens
+}
+
+// Range visits the shards one by one, so it is consistent
+// within a shard only and not across the whole table.
+func (s *ShardedHashTable) Range(fn func(key, value string, expiresAt time.Time) bool) {
+	proceed := true
+	for _, shard := range s.shards {
+		shard.Range(func(key, value string, expiresAt time.Time) bool {
+			proceed = fn(key, value, expiresAt)
+			return proceed
+		})
+
+		if !proceed {
+			return
+		}
+	}
+}
+
+func (s *ShardedHashTable) shard(key string) *HashTable {
+	return s.shards[hashKey(key)%uint32(len(s.shards))]
+}
+
+// hashKey is 32-bit FNV-1a, inlined to avoid allocating a hash.Hash per query.
+func hashKey(key string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	hash := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= prime32
+	}
+
+	return hash
+}
@@ -0,0 +1,161 @@
+package in_memory
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedHashTable(t *testing.T) {
+	t.Parallel()
+
+	table := NewShardedHashTable(4)
+	for i := 0; i < 100; i++ {
+		table.Set(fmt.Sprintf("key_%d", i), fmt.Sprintf("value_%d", i))
+	}
+
+	for _, shard := range table.shards {
+		require.NotEmpty(t, shard.data)
+	}
+
+	value, found := table.Get("key_42")
+	require.True(t, found)
+	require.Equal(t, "value_42", value)
+
+	table.Del("key_42")
+	_, found = table.Get("key_42")
+	require.False(t, found)
+
+	future := time.Now().Add(time.Hour)
+	require.True(t, table.Expire("key_1", future))
+	require.False(t, table.Expire("key_42", future))
+	expiresAt, found := table.Expiration("key_1")
+	require.True(t, found)
+	require.Equal(t, future, expiresAt)
+	require.True(t, table.Persist("key_1"))
+
+	keys := 0
+	table.Range(func(string, string, time.Time) bool {
+		keys++
+		return true
+	})
+	require.Equal(t, 99, keys)
+
+	calls := 0
+	table.Range(func(string, string, time.Time) bool {
+		calls++
+		return false
+	})
+	require.Equal(t, 1, calls)
+}
+
+func TestShardedHashTableDelExpired(t *testing.T) {
+	t.Parallel()
+
+	table := NewShardedHashTable(4)
+	for i := 0; i < 40; i++ {
+		table.SetWithExpiration(fmt.Sprintf("expired_%d", i), "value", time.Now().Add(-time.Second))
+	}
+	table.Set("persistent", "value")
+
+	checked, removed := table.DelExpired(10)
+	require.Equal(t, 10, checked)
+	require.Equal(t, 10, removed)
+
+	checked, removed = table.DelExpired(100)
+	require.Equal(t, 30, checked)
+	require.Equal(t, 30, removed)
+
+	value, found := table.Get("persistent")
+	require.True(t, found)
+	require.Equal(t, "value", value)
+}
+
+// run with -race to catch unsynchronized access
+func TestConcurrentAccess(t *testing.T) {
+	testCases := []struct {
+		name  string
+		table hashTable
+	}{
+		{name: "single map", table: NewHashTable()},
+		{name: "sharded", table: NewShardedHashTable(DefaultShardsNumber)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			wg := sync.WaitGroup{}
+			for worker := 0; worker < 8; worker++ {
+				wg.Add(1)
+				go func(worker int) {
+					defer wg.Done()
+
+					for i := 0; i < 500; i++ {
+						key := fmt.Sprintf("key_%d", i%50)
+						switch i % 5 {
+						case 0:
+							tc.table.Set(key, fmt.Sprintf("value_%d", worker))
+						case 1:
+							tc.table.SetWithExpiration(key, "value", time.Now().Add(time.Millisecond))
+						case 2:
+							tc.table.Del(key)
+						case 3:
+							tc.table.DelExpired(10)
+						default:
+							tc.table.Get(key)
+							tc.table.Range(func(string, string, time.Time) bool { return true })
+						}
+					}
+				}(worker)
+			}
+
+			wg.Wait()
+		})
+	}
+}
+
+// BenchmarkMixedLoad compares single map and sharded tables
+// under 90% reads and 10% writes from parallel goroutines.
+func BenchmarkMixedLoad(b *testing.B) {
+	benchmarks := []struct {
+		name    string
+		builder func() hashTable
+	}{
+		{name: "single map", builder: HashTableBuilder},
+		{name: "sharded", builder: ShardedHashTableBuilder(DefaultShardsNumber)},
+	}
+
+	const keysNumber = 10000
+	keys := make([]string, keysNumber)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key_%d", i)
+	}
+
+	for _, bm := range benchmarks {
+		bm := bm
+		b.Run(bm.name, func(b *testing.B) {
+			table := bm.builder()
+			for _, key := range keys {
+				table.Set(key, "value")
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					key := keys[i%keysNumber]
+					if i%10 == 0 {
+						table.Set(key, "value")
+					} else {
+						table.Get(key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package in_memory
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"maps"
+	"sort"
+	"time"
+)
+
+const snapshotFormatVersion = 1
+
+// types of snapshot entries
+const (
+	snapshotStringEntry = 0
+	snapshotHashEntry   = 1
+)
+
+var errInvalidSnapshot = errors.New("invalid snapshot")
+
+// snapshotEntry is a string key, or a hash key when fields is not nil.
+type snapshotEntry struct {
+	key       string
+	value     string
+	fields    map[string]string
+	expiresAt time.Time
+}
+
+// snapshot is a point-in-time copy of the engine data, it is not
+// affected by writes made after it was taken.
+type snapshot struct {
+	entries []snapshotEntry
+}
+
+// Snapshot copies the current engine state, queries are blocked
+// only while the data is copied, not while it is written out.
+// The copy is consistent across the whole table only if
+// the caller doesn't let writes run concurrently.
+func (e *Engine) Snapshot() io.WriterTo {
+	data := &snapshot{}
+	table := e.table()
+	table.Range(func(key, value string, expiresAt time.Time) bool {
+		data.entries = append(data.entries, snapshotEntry{key: key, value: value, expiresAt: expiresAt})
+		return true
+	})
+
+	table.RangeHashes(func(key string, fields map[string]string, expiresAt time.Time) bool {
+		data.entries = append(data.entries, snapshotEntry{key: key, fields: maps.Clone(fields), expiresAt: expiresAt})
+		return true
+	})
+
+	return data
+}
+
+// WriteTo encodes the snapshot as: version | entries number | (type | key length | key | value | expiration)...
+// where value of strings is: value length | value, value of hashes is: fields number | (field length | field |
+// value length | value)... ordered by field, and expiration is unix time in milliseconds or 0 for persistent keys.
+func (s *snapshot) WriteTo(w io.Writer) (int64, error) {
+	writer := bufio.NewWriter(w)
+
+	var written int64
+	buffer := make([]byte, 0, binary.MaxVarintLen64)
+	write := func(data []byte) error {
+		n, err := writer.Write(data)
+		written += int64(n)
+		return err
+	}
+
+	writeUvarint := func(value uint64) error {
+		return write(binary.AppendUvarint(buffer[:0], value))
+	}
+
+	if err := writeUvarint(snapshotFormatVersion); err != nil {
+		return written, err
+	}
+
+	if err := writeUvarint(uint64(len(s.entries))); err != nil {
+		return written, err
+	}
+
+	writeString := func(value string) error {
+		if err := writeUvarint(uint64(len(value))); err != nil {
+			return err
+		}
+
+		return write([]byte(value))
+	}
+
+	for _, entry := range s.entries {
+		entryType := uint64(snapshotStringEntry)
+		if entry.fields != nil {
+			entryType = snapshotHashEntry
+		}
+
+		if err := writeUvarint(entryType); err != nil {
+			return written, err
+		}
+
+		if err := writeString(entry.key); err != nil {
+			return written, err
+		}
+
+		if entry.fields == nil {
+			if err := writeString(entry.value); err != nil {
+				return written, err
+			}
+		} else {
+			if err := writeUvarint(uint64(len(entry.fields))); err != nil {
+				return written, err
+			}
+
+			// fields are ordered, so the same hash is always encoded the same way
+			fields := make([]string, 0, len(entry.fields))
+			for field := range entry.fields {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+
+			for _, field := range fields {
+				if err := writeString(field); err != nil {
+					return written, err
+				}
+
+				if err := writeString(entry.fields[field]); err != nil {
+					return written, err
+				}
+			}
+		}
+
+		var expiration uint64
+		if !entry.expiresAt.IsZero() {
+			expiration = uint64(entry.expiresAt.UnixMilli())
+		}
+
+		if err := writeUvarint(expiration); err != nil {
+			return written, err
+		}
+	}
+
+	return written, writer.Flush()
+}
+
+// RestoreSnapshot replaces the engine data with the snapshot content,
+// the current data is kept if the snapshot cannot be decoded.
+func (e *Engine) RestoreSnapshot(r io.Reader) error {
+	reader := bufio.NewReader(r)
+
+	version, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errInvalidSnapshot, err)
+	}
+
+	if version != snapshotFormatVersion {
+		return fmt.Errorf("%w: unsupported version %d", errInvalidSnapshot, version)
+	}
+
+	entriesNumber, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errInvalidSnapshot, err)
+	}
+
+	table := e.tableBuilder()
+	for i := uint64(0); i < entriesNumber; i++ {
+		entryType, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errInvalidSnapshot, err)
+		}
+
+		key, err := readSnapshotString(reader)
+		if err != nil {
+			return err
+		}
+
+		var value string
+		var fields map[string]string
+		switch entryType {
+		case snapshotStringEntry:
+			if value, err = readSnapshotString(reader); err != nil {
+				return err
+			}
+		case snapshotHashEntry:
+			if fields, err = readSnapshotHash(reader); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: unknown entry type %d", errInvalidSnapshot, entryType)
+		}
+
+		expiration, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errInvalidSnapshot, err)
+		}
+
+		if fields != nil {
+			err := table.HashUpdate(key, func(map[string]string) (map[string]string, []string, error) {
+				return fields, nil, nil
+			})
+			if err != nil {
+				return fmt.Errorf("%w: %s", errInvalidSnapshot, err)
+			}
+
+			if expiration != 0 {
+				table.Expire(key, time.UnixMilli(int64(expiration)))
+			}
+		} else if expiration == 0 {
+			table.Set(key, value)
+		} else {
+			table.SetWithExpiration(key, value, time.UnixMilli(int64(expiration)))
+		}
+	}
+
+	if _, err := reader.ReadByte(); err != io.EOF {
+		return fmt.Errorf("%w: unexpected data after the last entry", errInvalidSnapshot)
+	}
+
+	e.setTable(table)
+
+	e.logger.Debug("snapshot restored", zap.Uint64("entries", entriesNumber))
+	return nil
+}
+
+func readSnapshotHash(reader *bufio.Reader) (map[string]string, error) {
+	fieldsNumber, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidSnapshot, err)
+	}
+
+	if fieldsNumber == 0 {
+		return nil, fmt.Errorf("%w: hash without fields", errInvalidSnapshot)
+	}
+
+	// the number is not trusted for preallocation, like string lengths
+	fields := make(map[string]string)
+	for i := uint64(0); i < fieldsNumber; i++ {
+		field, err := readSnapshotString(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := readSnapshotString(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		fields[field] = value
+	}
+
+	return fields, nil
+}
+
+func readSnapshotString(reader *bufio.Reader) (string, error) {
+	size, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", errInvalidSnapshot, err)
+	}
+
+	if size > uint64(reader.Size()) {
+		// the length is not trusted before the data is actually read
+		data, err := io.ReadAll(io.LimitReader(reader, int64(size)))
+		if err != nil {
+			return "", err
+		}
+
+		if uint64(len(data)) != size {
+			return "", fmt.Errorf("%w: %s", errInvalidSnapshot, io.ErrUnexpectedEOF)
+		}
+
+		return string(data), nil
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return "", fmt.Errorf("%w: %s", errInvalidSnapshot, err)
+	}
+
+	return string(data), nil
+}
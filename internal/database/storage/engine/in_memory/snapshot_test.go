@@ -0,0 +1,142 @@
+package in_memory
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/txcontext"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	engine, err := NewEngine(HashTableBuilder, zap.NewNop())
+	require.NoError(t, err)
+
+	engine.Set(ctx, "key_1", "value_1")
+	engine.Set(ctx, "key_2", "")
+	engine.Set(ctx, "key_3", "\x00binary\n")
+	engine.SetWithExpiration(ctx, "volatile", "value", time.Now().Add(time.Hour))
+	engine.SetWithExpiration(ctx, "expired", "value", time.Now().Add(-time.Hour))
+	require.NoError(t, engine.HashUpdate(ctx, "hash", setFields(map[string]string{"field_1": "value_1", "field_2": ""})))
+	require.NoError(t, engine.HashUpdate(ctx, "volatile_hash", setFields(map[string]string{"field": "value"})))
+	engine.Expire(ctx, "volatile_hash", time.Now().Add(time.Hour))
+
+	snapshot := engine.Snapshot()
+	engine.Set(ctx, "key_4", "written after snapshot")
+	require.NoError(t, engine.HashUpdate(ctx, "hash", setFields(map[string]string{"field_3": "written after snapshot"})))
+
+	buffer := bytes.Buffer{}
+	written, err := snapshot.WriteTo(&buffer)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buffer.Len()), written)
+
+	restored, err := NewEngine(HashTableBuilder, zap.NewNop())
+	require.NoError(t, err)
+	restored.Set(ctx, "stale", "value")
+	require.NoError(t, restored.RestoreSnapshot(&buffer))
+
+	for key, expected := range map[string]string{"key_1": "value_1", "key_2": "", "key_3": "\x00binary\n"} {
+		value, found := restored.Get(ctx, key)
+		assert.True(t, found)
+		assert.Equal(t, expected, value)
+	}
+
+	expiresAt, found := restored.Expiration(ctx, "volatile")
+	assert.True(t, found)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+
+	fields, err := restored.HashGetAll(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"field_1": "value_1", "field_2": ""}, fields)
+
+	expiresAt, found = restored.Expiration(ctx, "volatile_hash")
+	assert.True(t, found)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+
+	_, found = restored.Get(ctx, "expired")
+	assert.False(t, found)
+	_, found = restored.Get(ctx, "key_4")
+	assert.False(t, found)
+	_, found = restored.Get(ctx, "stale")
+	assert.False(t, found)
+}
+
+func TestSnapshotFarFutureExpiration(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	engine, err := NewEngine(HashTableBuilder, zap.NewNop())
+	require.NoError(t, err)
+
+	// the deadline of SET key value EX 9000000000, past the year 2262
+	deadline := time.Now().Add(9000000000 * time.Second)
+	engine.SetWithExpiration(ctx, "key", "value", deadline)
+
+	buffer := bytes.Buffer{}
+	_, err = engine.Snapshot().WriteTo(&buffer)
+	require.NoError(t, err)
+
+	restored, err := NewEngine(HashTableBuilder, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, restored.RestoreSnapshot(&buffer))
+
+	value, found := restored.Get(ctx, "key")
+	assert.True(t, found)
+	assert.Equal(t, "value", value)
+
+	expiresAt, found := restored.Expiration(ctx, "key")
+	assert.True(t, found)
+	assert.Equal(t, deadline.UnixMilli(), expiresAt.UnixMilli())
+}
+
+func TestRestoreInvalidSnapshot(t *testing.T) {
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	source, err := NewEngine(HashTableBuilder, zap.NewNop())
+	require.NoError(t, err)
+	source.Set(ctx, "key", "value")
+
+	buffer := bytes.Buffer{}
+	_, err = source.Snapshot().WriteTo(&buffer)
+	require.NoError(t, err)
+	valid := buffer.Bytes()
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty data", data: nil},
+		{name: "unknown version", data: []byte{2, 0}},
+		{name: "truncated entry", data: valid[:len(valid)-1]},
+		{name: "unknown entry type", data: []byte{1, 1, 2, 1, 'k', 1, 'v', 0}},
+		{name: "hash without fields", data: []byte{1, 1, 1, 1, 'k', 0, 0}},
+		{name: "trailing data", data: append(append([]byte{}, valid...), 1)},
+		{name: "huge length", data: []byte{1, 1, 0, 0xff, 0xff, 0xff, 0xff, 0x0f}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			engine, err := NewEngine(HashTableBuilder, zap.NewNop())
+			require.NoError(t, err)
+			engine.Set(ctx, "current", "value")
+
+			err = engine.RestoreSnapshot(bytes.NewReader(tc.data))
+			require.ErrorIs(t, err, errInvalidSnapshot)
+
+			value, found := engine.Get(ctx, "current")
+			assert.True(t, found)
+			assert.Equal(t, "value", value)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/txcontext"
+)
+
+// HSet sets the hash fields and returns the number of fields that didn't exist,
+// a missing key is created. Like in Update, the fields are logged while the key
+// is locked, so concurrent writes of the key are logged in the order they are applied.
+func (s *Storage) HSet(ctx context.Context, key string, fields map[string]string) (int, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return 0, ctx.Err()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	// room is made for the key only like in Update
+	if err := s.makeRoom(ctx, key, ""); err != nil {
+		return 0, err
+	}
+
+	added := 0
+	err := s.engine.HashUpdate(ctx, key, func(current map[string]string) (map[string]string, []string, error) {
+		added = 0
+		for field := range fields {
+			if _, found := current[field]; !found {
+				added++
+			}
+		}
+
+		if s.wal != nil {
+			if err := s.wal.HSet(ctx, key, fields); err != nil {
+				txID := txcontext.TxID(ctx)
+				s.logger.Error("failed to write to wal", zap.Int64("tx", txID), zap.Error(err))
+				return nil, nil, err
+			}
+		}
+
+		return fields, nil, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return added, nil
+}
+
+// HDel removes the hash fields and returns the number of removed ones,
+// the key is removed together with its last field. Only existing
+// fields are logged, nothing is logged if none of them exists.
+func (s *Storage) HDel(ctx context.Context, key string, fields []string) (int, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return 0, ctx.Err()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var removed []string
+	err := s.engine.HashUpdate(ctx, key, func(current map[string]string) (map[string]string, []string, error) {
+		removed = nil
+		seen := make(map[string]struct{}, len(fields))
+		for _, field := range fields {
+			if _, found := seen[field]; found {
+				continue
+			}
+
+			seen[field] = struct{}{}
+			if _, found := current[field]; found {
+				removed = append(removed, field)
+			}
+		}
+
+		if s.wal != nil && len(removed) != 0 {
+			if err := s.wal.HDel(ctx, key, removed); err != nil {
+				txID := txcontext.TxID(ctx)
+				s.logger.Error("failed to write to wal", zap.Int64("tx", txID), zap.Error(err))
+				return nil, nil, err
+			}
+		}
+
+		return nil, removed, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(removed), nil
+}
+
+// HGet returns ErrNotFound if the field or the key doesn't exist.
+func (s *Storage) HGet(ctx context.Context, key, field string) (string, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return "", ctx.Err()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	value, found, err := s.engine.HashGet(ctx, key, field)
+	if err != nil {
+		return "", err
+	} else if !found {
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+// HGetAll returns a copy of the hash fields, a missing key has no fields.
+func (s *Storage) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return nil, ctx.Err()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.engine.HashGetAll(ctx, key)
+}
+
+func (s *Storage) HLen(ctx context.Context, key string) (int, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return 0, ctx.Err()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.engine.HashLen(ctx, key)
+}
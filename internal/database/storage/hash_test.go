@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/storage/wal"
+	"inmem-db-go/internal/database/txcontext"
+	"testing"
+)
+
+// applyHashUpdate runs the update function of HashUpdate against the current fields.
+func applyHashUpdate(current map[string]string) func(context.Context, string, func(map[string]string) (map[string]string, []string, error)) error {
+	return func(_ context.Context, _ string, fn func(map[string]string) (map[string]string, []string, error)) error {
+		_, _, err := fn(current)
+		return err
+	}
+}
+
+func TestHSetWithWAL(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	errFailed := errors.New("failed")
+	fields := map[string]string{"field_1": "value_1", "field_2": "value_2"}
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	engine.EXPECT().MakeRoom(ctx, "key", "", gomock.Any()).Return(nil).Times(2)
+	engine.EXPECT().HashUpdate(ctx, "key", gomock.Any()).
+		DoAndReturn(applyHashUpdate(map[string]string{"field_1": "old"})).Times(2)
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	gomock.InOrder(
+		log.EXPECT().HSet(ctx, "key", fields).Return(nil),
+		log.EXPECT().HSet(ctx, "key", fields).Return(errFailed),
+	)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	added, err := storage.HSet(ctx, "key", fields)
+	require.NoError(t, err)
+	require.Equal(t, 1, added)
+
+	_, err = storage.HSet(ctx, "key", fields)
+	require.ErrorIs(t, err, errFailed)
+}
+
+func TestHDelWithWAL(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	engine.EXPECT().HashUpdate(ctx, "key", gomock.Any()).
+		DoAndReturn(applyHashUpdate(map[string]string{"field_1": "value_1", "field_2": "value_2"}))
+	engine.EXPECT().HashUpdate(ctx, "missing", gomock.Any()).
+		DoAndReturn(applyHashUpdate(nil))
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	log.EXPECT().HDel(ctx, "key", []string{"field_2", "field_1"}).Return(nil)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	removed, err := storage.HDel(ctx, "key", []string{"field_2", "field_3", "field_1", "field_2"})
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+
+	// nothing is logged when no field is removed
+	removed, err = storage.HDel(ctx, "missing", []string{"field_1"})
+	require.NoError(t, err)
+	require.Equal(t, 0, removed)
+}
+
+func TestHGet(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	errWrongType := errors.New("wrong type")
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	engine.EXPECT().HashGet(ctx, "key", "field").Return("value", true, nil)
+	engine.EXPECT().HashGet(ctx, "key", "missing").Return("", false, nil)
+	engine.EXPECT().HashGet(ctx, "string", "field").Return("", false, errWrongType)
+
+	storage, err := NewStorage(engine, zap.NewNop())
+	require.NoError(t, err)
+
+	value, err := storage.HGet(ctx, "key", "field")
+	require.NoError(t, err)
+	require.Equal(t, "value", value)
+
+	_, err = storage.HGet(ctx, "key", "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = storage.HGet(ctx, "string", "field")
+	require.ErrorIs(t, err, errWrongType)
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = storage.HGetAll(canceledCtx, "key")
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNewStorageRecoversHashesFromWAL(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	gomock.InOrder(
+		engine.EXPECT().HashUpdate(gomock.Any(), "key", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, fn func(map[string]string) (map[string]string, []string, error)) error {
+				set, deleted, err := fn(nil)
+				require.Equal(t, map[string]string{"field_1": "value_1", "field_2": ""}, set)
+				require.Empty(t, deleted)
+				return err
+			}),
+		engine.EXPECT().HashUpdate(gomock.Any(), "key", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, fn func(map[string]string) (map[string]string, []string, error)) error {
+				set, deleted, err := fn(map[string]string{"field_1": "value_1", "field_2": ""})
+				require.Empty(t, set)
+				require.Equal(t, []string{"field_1"}, deleted)
+				return err
+			}),
+	)
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().
+		Replay(int64(0), gomock.Any()).
+		DoAndReturn(func(_ int64, apply func(wal.Record) error) error {
+			if err := apply(wal.NewRecord(1, compute.HSetCommandID, []string{"key", "field_1", "value_1", "field_2", ""})); err != nil {
+				return err
+			}
+			if err := apply(wal.NewRecord(2, compute.HDelCommandID, []string{"key", "field_1"})); err != nil {
+				return err
+			}
+			return apply(wal.NewRecord(3, compute.HSetCommandID, []string{"key", "field_1"}))
+		})
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.Error(t, err)
+	require.Nil(t, storage)
+}
@@ -0,0 +1,235 @@
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	snapshotFilePrefix    = "snapshot_"
+	snapshotFileExtension = ".snap"
+	temporaryFileSuffix   = ".tmp"
+)
+
+// file layout: magic | lsn (uint64) | engine payload | crc32 of everything before (uint32)
+var magic = []byte("IMDBSNAP")
+
+var (
+	ErrNoSnapshot    = errors.New("no snapshot")
+	errInvalidHeader = errors.New("invalid snapshot header")
+	errChecksum      = errors.New("snapshot checksum mismatch")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type Manager struct {
+	directory string
+	logger    *zap.Logger
+}
+
+func NewManager(directory string, logger *zap.Logger) (*Manager, error) {
+	if directory == "" {
+		return nil, errors.New("directory is invalid")
+	}
+
+	if logger == nil {
+		return nil, errors.New("logger is invalid")
+	}
+
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	return &Manager{
+		directory: directory,
+		logger:    logger,
+	}, nil
+}
+
+// Save atomically writes a snapshot taken at the lsn log position
+// and removes the older ones which are not needed anymore.
+func (m *Manager) Save(lsn int64, snapshot io.WriterTo) error {
+	path := filepath.Join(m.directory, snapshotFileName(lsn))
+	temporaryPath := path + temporaryFileSuffix
+
+	if err := m.write(temporaryPath, lsn, snapshot); err != nil {
+		_ = os.Remove(temporaryPath)
+		return err
+	}
+
+	if err := os.Rename(temporaryPath, path); err != nil {
+		_ = os.Remove(temporaryPath)
+		return fmt.Errorf("failed to rename snapshot: %w", err)
+	}
+
+	if err := syncDirectory(m.directory); err != nil {
+		return err
+	}
+
+	m.logger.Info("snapshot saved", zap.String("path", path), zap.Int64("lsn", lsn))
+	m.removeOlderThan(lsn)
+	return nil
+}
+
+// LoadLatest passes the payload of the newest snapshot to restore and returns
+// its log position, ErrNoSnapshot is returned when there is nothing to load.
+// A broken newest snapshot is an error: older ones are not a fallback, since
+// the log they would need has been truncated up to the newest one.
+func (m *Manager) LoadLatest(restore func(io.Reader) error) (int64, error) {
+	snapshots, err := m.snapshots()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(snapshots) == 0 {
+		return 0, ErrNoSnapshot
+	}
+
+	path := filepath.Join(m.directory, snapshotFileName(snapshots[len(snapshots)-1]))
+	lsn, payload, err := readSnapshot(path)
+	if err != nil {
+		return 0, fmt.Errorf("snapshot %s: %w", path, err)
+	}
+
+	if err := restore(bytes.NewReader(payload)); err != nil {
+		return 0, fmt.Errorf("snapshot %s: %w", path, err)
+	}
+
+	m.logger.Info("snapshot loaded", zap.String("path", path), zap.Int64("lsn", lsn))
+	return lsn, nil
+}
+
+func (m *Manager) write(path string, lsn int64, snapshot io.WriterTo) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	defer file.Close()
+
+	checksum := crc32.New(crcTable)
+	writer := bufio.NewWriter(io.MultiWriter(file, checksum))
+
+	header := make([]byte, 0, len(magic)+8)
+	header = append(header, magic...)
+	header = binary.LittleEndian.AppendUint64(header, uint64(lsn))
+	if _, err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if _, err := snapshot.WriteTo(writer); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if _, err := file.Write(binary.LittleEndian.AppendUint32(nil, checksum.Sum32())); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+
+	return file.Close()
+}
+
+func readSnapshot(path string) (int64, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	headerSize := len(magic) + 8
+	if len(data) < headerSize+4 || !bytes.Equal(data[:len(magic)], magic) {
+		return 0, nil, errInvalidHeader
+	}
+
+	content, footer := data[:len(data)-4], data[len(data)-4:]
+	if crc32.Checksum(content, crcTable) != binary.LittleEndian.Uint32(footer) {
+		return 0, nil, errChecksum
+	}
+
+	lsn := int64(binary.LittleEndian.Uint64(data[len(magic):headerSize]))
+	return lsn, content[headerSize:], nil
+}
+
+func (m *Manager) removeOlderThan(lsn int64) {
+	snapshots, err := m.snapshots()
+	if err != nil {
+		m.logger.Warn("failed to list snapshots", zap.Error(err))
+		return
+	}
+
+	for _, snapshotLSN := range snapshots {
+		if snapshotLSN >= lsn {
+			continue
+		}
+
+		path := filepath.Join(m.directory, snapshotFileName(snapshotLSN))
+		if err := os.Remove(path); err != nil {
+			m.logger.Warn("failed to remove old snapshot", zap.String("path", path), zap.Error(err))
+		}
+	}
+}
+
+// snapshots returns log positions of the stored snapshots in ascending order.
+func (m *Manager) snapshots() ([]int64, error) {
+	entries, err := os.ReadDir(m.directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	var snapshots []int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, snapshotFilePrefix) || !strings.HasSuffix(name, snapshotFileExtension) {
+			continue
+		}
+
+		lsn, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, snapshotFilePrefix), snapshotFileExtension), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		snapshots = append(snapshots, lsn)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i] < snapshots[j]
+	})
+
+	return snapshots, nil
+}
+
+func snapshotFileName(lsn int64) string {
+	return fmt.Sprintf("%s%020d%s", snapshotFilePrefix, lsn, snapshotFileExtension)
+}
+
+func syncDirectory(directory string) error {
+	dir, err := os.Open(directory)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot directory: %w", err)
+	}
+
+	defer dir.Close()
+
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("failed to sync snapshot directory: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type payload []byte
+
+func (p payload) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(p)
+	return int64(n), err
+}
+
+func loadLatest(t *testing.T, manager *Manager) (int64, []byte, error) {
+	t.Helper()
+
+	var data []byte
+	lsn, err := manager.LoadLatest(func(r io.Reader) error {
+		var err error
+		data, err = io.ReadAll(r)
+		return err
+	})
+	return lsn, data, err
+}
+
+func TestNewManager(t *testing.T) {
+	t.Parallel()
+
+	manager, err := NewManager("", zap.NewNop())
+	require.Error(t, err, "directory is invalid")
+	require.Nil(t, manager)
+
+	manager, err = NewManager(t.TempDir(), nil)
+	require.Error(t, err, "logger is invalid")
+	require.Nil(t, manager)
+
+	manager, err = NewManager(t.TempDir(), zap.NewNop())
+	require.NoError(t, err)
+	require.NotNil(t, manager)
+}
+
+func TestLoadWithoutSnapshots(t *testing.T) {
+	t.Parallel()
+
+	manager, err := NewManager(t.TempDir(), zap.NewNop())
+	require.NoError(t, err)
+
+	_, _, err = loadLatest(t, manager)
+	require.ErrorIs(t, err, ErrNoSnapshot)
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	t.Parallel()
+
+	directory := t.TempDir()
+	manager, err := NewManager(directory, zap.NewNop())
+	require.NoError(t, err)
+
+	require.NoError(t, manager.Save(10, payload("first")))
+	require.NoError(t, manager.Save(25, payload("second")))
+
+	lsn, data, err := loadLatest(t, manager)
+	require.NoError(t, err)
+	assert.Equal(t, int64(25), lsn)
+	assert.Equal(t, []byte("second"), data)
+
+	files, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, snapshotFileName(25), files[0].Name())
+}
+
+func TestLoadFailsOnBrokenSnapshot(t *testing.T) {
+	t.Parallel()
+
+	directory := t.TempDir()
+	manager, err := NewManager(directory, zap.NewNop())
+	require.NoError(t, err)
+
+	require.NoError(t, manager.Save(10, payload("valid")))
+
+	data, err := os.ReadFile(filepath.Join(directory, snapshotFileName(10)))
+	require.NoError(t, err)
+
+	// the older valid snapshot is not loaded instead of the newest one
+	broken := bytes.Replace(data, []byte("valid"), []byte("vAlid"), 1)
+	require.NoError(t, os.WriteFile(filepath.Join(directory, snapshotFileName(20)), broken, 0o644))
+
+	_, _, err = loadLatest(t, manager)
+	require.ErrorIs(t, err, errChecksum)
+
+	require.NoError(t, os.WriteFile(filepath.Join(directory, snapshotFileName(30)), []byte("garbage"), 0o644))
+
+	_, _, err = loadLatest(t, manager)
+	require.ErrorIs(t, err, errInvalidHeader)
+
+	require.NoError(t, os.Remove(filepath.Join(directory, snapshotFileName(20))))
+	require.NoError(t, os.Remove(filepath.Join(directory, snapshotFileName(30))))
+
+	errRestore := errors.New("restore failed")
+	_, err = manager.LoadLatest(func(io.Reader) error {
+		return errRestore
+	})
+	require.ErrorIs(t, err, errRestore)
+}
@@ -3,21 +3,133 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
 	"go.uber.org/zap"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/storage/snapshot"
+	"inmem-db-go/internal/database/storage/wal"
+	"inmem-db-go/internal/database/txcontext"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrConflict          = result.NewError(result.CodeConflict, "transaction conflict")
+	errSnapshotsDisabled = result.NewError(result.CodeNotSupported, "snapshots are disabled")
+	// ErrSkipUpdate is returned by update functions to leave the key as is
+	ErrSkipUpdate = errors.New("skip update")
+)
+
+// KeyState is a key value seen by a transaction or written by it,
+// Found is false for missing or deleted keys. OtherType marks a read
+// of a key holding another type than a string, its Value is empty.
+type KeyState struct {
+	Key       string
+	Value     string
+	Found     bool
+	OtherType bool
+}
+
 type Engine interface {
 	Set(context.Context, string, string)
+	SetWithExpiration(context.Context, string, string, time.Time)
 	Get(context.Context, string) (string, bool)
-	Del(context.Context, string)
+	GetString(context.Context, string) (string, bool, error)
+	Exists(context.Context, string) bool
+	Del(context.Context, string) bool
+	Update(context.Context, string, func(string, bool, time.Time) (string, time.Time, error)) error
+	Replace(context.Context, string, func(bool) (string, time.Time, error)) error
+	Expire(context.Context, string, time.Time) bool
+	Persist(context.Context, string) bool
+	Expiration(context.Context, string) (time.Time, bool)
+	Keys(context.Context) []string
+	Scan(context.Context, string, int) ([]string, string, error)
+	Size(context.Context) int
+	HashGet(context.Context, string, string) (string, bool, error)
+	HashGetAll(context.Context, string) (map[string]string, error)
+	HashLen(context.Context, string) (int, error)
+	HashUpdate(context.Context, string, func(map[string]string) (map[string]string, []string, error)) error
+	// MakeRoom evicts keys so that the value fits into the memory limit,
+	// the function is called with every key before it is evicted
+	MakeRoom(context.Context, string, string, func(string) error) error
+	Snapshot() io.WriterTo
+	RestoreSnapshot(io.Reader) error
+}
+
+type WAL interface {
+	Set(context.Context, string, string) error
+	SetWithExpiration(context.Context, string, string, time.Time) error
+	Del(context.Context, string) error
+	Expire(context.Context, string, time.Time) error
+	Persist(context.Context, string) error
+	Commit(context.Context, map[string]string, []string) error
+	HSet(context.Context, string, map[string]string) error
+	HDel(context.Context, string, []string) error
+	AppendRecord(context.Context, wal.Record) error
+	Reset(int64) error
+	Replay(int64, func(wal.Record) error) error
+	LastLSN() int64
+	Truncate(int64) error
+	Sync() error
+}
+
+type SnapshotManager interface {
+	Save(int64, io.WriterTo) error
+	LoadLatest(func(io.Reader) error) (int64, error)
+}
+
+type StorageOption func(*Storage)
+
+// WithWAL makes every write go through the log before it is applied
+// to the engine, the engine state is restored from the log on start.
+func WithWAL(wal WAL) StorageOption {
+	return func(storage *Storage) {
+		storage.wal = wal
+	}
+}
+
+// WithSnapshots enables point-in-time snapshots, the newest one is loaded
+// on start and only the log records written after it are replayed.
+func WithSnapshots(snapshots SnapshotManager) StorageOption {
+	return func(storage *Storage) {
+		storage.snapshots = snapshots
+	}
 }
 
 type Storage struct {
-	engine Engine
+	engine    Engine
+	wal       WAL
+	snapshots SnapshotManager
+
+	// writes hold at least the read lock so that a snapshot taken
+	// under the write lock matches exactly the last logged record
+	mutex         sync.RWMutex
+	snapshotMutex sync.Mutex
+
+	// position of the last replicated record, used instead
+	// of the log position when a replica runs without the log
+	appliedLSN int64
+
+	// unix time in nanoseconds and position of the last snapshot taken
+	lastSnapshotAt  atomic.Int64
+	lastSnapshotLSN atomic.Int64
+
 	logger *zap.Logger
 }
 
-func NewStorage(engine Engine, logger *zap.Logger) (*Storage, error) {
+// StorageStats describes the persistence state, LastSnapshotAt
+// is zero until the first snapshot since start.
+type StorageStats struct {
+	LastLSN         int64
+	LastSnapshotAt  time.Time
+	LastSnapshotLSN int64
+}
+
+func NewStorage(engine Engine, logger *zap.Logger, options ...StorageOption) (*Storage, error) {
 	if engine == nil {
 		return nil, errors.New("engine is invalid")
 	}
@@ -26,41 +138,733 @@ func NewStorage(engine Engine, logger *zap.Logger) (*Storage, error) {
 		return nil, errors.New("logger is invalid")
 	}
 
-	return &Storage{
+	storage := &Storage{
 		engine: engine,
 		logger: logger,
-	}, nil
+	}
+
+	for _, option := range options {
+		option(storage)
+	}
+
+	if err := storage.recover(); err != nil {
+		return nil, err
+	}
+
+	return storage, nil
 }
 
 func (s *Storage) Set(ctx context.Context, key, value string) error {
 	if ctx.Err() != nil {
-		txID := ctx.Value("tx").(int64)
+		txID := txcontext.TxID(ctx)
 		s.logger.Debug("query canceled", zap.Int64("tx", txID))
 		return ctx.Err()
 	}
 
+	unlock := s.lockWrite()
+	defer unlock()
+
+	if err := s.makeRoom(ctx, key, value); err != nil {
+		return err
+	}
+
+	if s.wal != nil {
+		if err := s.wal.Set(ctx, key, value); err != nil {
+			txID := txcontext.TxID(ctx)
+			s.logger.Error("failed to write to wal", zap.Int64("tx", txID), zap.Error(err))
+			return err
+		}
+	}
+
 	s.engine.Set(ctx, key, value)
 	return nil
 }
 
+func (s *Storage) SetWithExpiration(ctx context.Context, key, value string, expiresAt time.Time) error {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return ctx.Err()
+	}
+
+	unlock := s.lockWrite()
+	defer unlock()
+
+	if err := s.makeRoom(ctx, key, value); err != nil {
+		return err
+	}
+
+	if s.wal != nil {
+		if err := s.wal.SetWithExpiration(ctx, key, value, expiresAt); err != nil {
+			txID := txcontext.TxID(ctx)
+			s.logger.Error("failed to write to wal", zap.Int64("tx", txID), zap.Error(err))
+			return err
+		}
+	}
+
+	s.engine.SetWithExpiration(ctx, key, value, expiresAt)
+	return nil
+}
+
+// Get returns ErrNotFound for missing keys, so they are
+// not confused with keys set to an empty value. Keys of
+// other types fail with the wrong type error of the engine.
 func (s *Storage) Get(ctx context.Context, key string) (string, error) {
 	if ctx.Err() != nil {
-		txID := ctx.Value("tx").(int64)
+		txID := txcontext.TxID(ctx)
 		s.logger.Debug("query canceled", zap.Int64("tx", txID))
 		return "", ctx.Err()
 	}
 
-	value, _ := s.engine.Get(ctx, key)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	value, found, err := s.engine.GetString(ctx, key)
+	if err != nil {
+		return "", err
+	} else if !found {
+		return "", ErrNotFound
+	}
+
 	return value, nil
 }
 
+// Del returns ErrNotFound if the key didn't exist.
 func (s *Storage) Del(ctx context.Context, key string) error {
 	if ctx.Err() != nil {
-		txID := ctx.Value("tx").(int64)
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return ctx.Err()
+	}
+
+	unlock := s.lockWrite()
+	defer unlock()
+
+	if s.wal != nil {
+		if err := s.wal.Del(ctx, key); err != nil {
+			txID := txcontext.TxID(ctx)
+			s.logger.Error("failed to write to wal", zap.Int64("tx", txID), zap.Error(err))
+			return err
+		}
+	}
+
+	if !s.engine.Del(ctx, key) {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Update replaces the value and the expiration of the key with the ones returned
+// by fn atomically, fn gets the current ones and whether the key exists. Zero
+// expiration time makes the key persistent. Update reports whether the key was
+// written, fn returns ErrSkipUpdate to leave the key as is without failing.
+// The new value is logged while the key is locked, so concurrent updates
+// of the key are logged in the order they are applied.
+func (s *Storage) Update(ctx context.Context, key string, fn func(value string, found bool, expiresAt time.Time) (string, time.Time, error)) (bool, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return false, ctx.Err()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	// the new value is not known yet, so room is made for the key only,
+	// the value may exceed the limit slightly like concurrent writes do
+	if err := s.makeRoom(ctx, key, ""); err != nil {
+		return false, err
+	}
+
+	err := s.engine.Update(ctx, key, func(value string, found bool, expiresAt time.Time) (string, time.Time, error) {
+		value, expiresAt, err := fn(value, found, expiresAt)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+
+		if err := s.logSet(ctx, key, value, expiresAt); err != nil {
+			return "", time.Time{}, err
+		}
+
+		return value, expiresAt, nil
+	})
+	if errors.Is(err, ErrSkipUpdate) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Replace is Update which replaces a key of any type with the string value,
+// fn only gets whether the key exists. Like in Update, fn returns ErrSkipUpdate
+// to leave the key as is and the value is logged while the key is locked.
+func (s *Storage) Replace(ctx context.Context, key string, fn func(exists bool) (string, time.Time, error)) (bool, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return false, ctx.Err()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if err := s.makeRoom(ctx, key, ""); err != nil {
+		return false, err
+	}
+
+	err := s.engine.Replace(ctx, key, func(exists bool) (string, time.Time, error) {
+		value, expiresAt, err := fn(exists)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+
+		if err := s.logSet(ctx, key, value, expiresAt); err != nil {
+			return "", time.Time{}, err
+		}
+
+		return value, expiresAt, nil
+	})
+	if errors.Is(err, ErrSkipUpdate) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Expire sets the key deadline and reports whether the key exists.
+func (s *Storage) Expire(ctx context.Context, key string, expiresAt time.Time) (bool, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return false, ctx.Err()
+	}
+
+	unlock := s.lockWrite()
+	defer unlock()
+
+	if s.wal != nil {
+		if err := s.wal.Expire(ctx, key, expiresAt); err != nil {
+			txID := txcontext.TxID(ctx)
+			s.logger.Error("failed to write to wal", zap.Int64("tx", txID), zap.Error(err))
+			return false, err
+		}
+	}
+
+	return s.engine.Expire(ctx, key, expiresAt), nil
+}
+
+// Persist removes the key deadline and reports whether the key had one.
+func (s *Storage) Persist(ctx context.Context, key string) (bool, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return false, ctx.Err()
+	}
+
+	unlock := s.lockWrite()
+	defer unlock()
+
+	if s.wal != nil {
+		if err := s.wal.Persist(ctx, key); err != nil {
+			txID := txcontext.TxID(ctx)
+			s.logger.Error("failed to write to wal", zap.Int64("tx", txID), zap.Error(err))
+			return false, err
+		}
+	}
+
+	return s.engine.Persist(ctx, key), nil
+}
+
+// Expiration returns the key deadline, zero time means that the key never expires.
+func (s *Storage) Expiration(ctx context.Context, key string) (time.Time, bool, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return time.Time{}, false, ctx.Err()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	expiresAt, found := s.engine.Expiration(ctx, key)
+	return expiresAt, found, nil
+}
+
+func (s *Storage) Keys(ctx context.Context) ([]string, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return nil, ctx.Err()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.engine.Keys(ctx), nil
+}
+
+// Scan returns the next keys of an incremental iteration started with the
+// "0" cursor, the storage is locked for a single call only.
+func (s *Storage) Scan(ctx context.Context, cursor string, count int) ([]string, string, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return nil, "", ctx.Err()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.engine.Scan(ctx, cursor, count)
+}
+
+func (s *Storage) Size(ctx context.Context) (int, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return 0, ctx.Err()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.engine.Size(ctx), nil
+}
+
+// Commit applies transaction writes if none of the keys read by the transaction
+// has changed since, otherwise ErrConflict is returned and nothing is applied.
+// Queries don't see a partially applied transaction.
+func (s *Storage) Commit(ctx context.Context, reads, writes []KeyState) error {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
 		s.logger.Debug("query canceled", zap.Int64("tx", txID))
 		return ctx.Err()
 	}
 
-	s.engine.Del(ctx, key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, read := range reads {
+		value, found, err := s.engine.GetString(ctx, read.Key)
+		otherType := result.CodeOf(err) == result.CodeWrongType
+		if err != nil && !otherType {
+			return err
+		}
+
+		if otherType != read.OtherType || (!otherType && (found != read.Found || value != read.Value)) {
+			txID := txcontext.TxID(ctx)
+			s.logger.Debug("transaction conflict", zap.Int64("tx", txID), zap.String("key", read.Key))
+			return ErrConflict
+		}
+	}
+
+	return s.apply(ctx, writes)
+}
+
+// MSet writes the keys atomically as a single log record, queries see either
+// none or all of them. The last value of a repeated key wins.
+func (s *Storage) MSet(ctx context.Context, writes []KeyState) error {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return ctx.Err()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.apply(ctx, writes)
+}
+
+// MGet returns the state of every key, all of them are read at once,
+// so the keys of a single MSet are seen all together.
+func (s *Storage) MGet(ctx context.Context, keys []string) ([]KeyState, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return nil, ctx.Err()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	states := make([]KeyState, 0, len(keys))
+	for _, key := range keys {
+		value, found := s.engine.Get(ctx, key)
+		states = append(states, KeyState{Key: key, Value: value, Found: found})
+	}
+
+	return states, nil
+}
+
+// MDel removes the keys of any type atomically as a single log record and
+// reports whether each of them existed, a repeated key is removed only once.
+func (s *Storage) MDel(ctx context.Context, keys []string) ([]bool, error) {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return nil, ctx.Err()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	deleted := make([]bool, len(keys))
+	removed := make(map[string]struct{}, len(keys))
+	var writes []KeyState
+	for i, key := range keys {
+		if _, found := removed[key]; found {
+			continue
+		}
+
+		if s.engine.Exists(ctx, key) {
+			deleted[i] = true
+			removed[key] = struct{}{}
+			writes = append(writes, KeyState{Key: key})
+		}
+	}
+
+	if err := s.apply(ctx, writes); err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// Snapshot saves the engine state and drops the log segments covered by it.
+func (s *Storage) Snapshot(ctx context.Context) error {
+	if ctx.Err() != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Debug("query canceled", zap.Int64("tx", txID))
+		return ctx.Err()
+	}
+
+	if s.snapshots == nil {
+		return errSnapshotsDisabled
+	}
+
+	s.snapshotMutex.Lock()
+	defer s.snapshotMutex.Unlock()
+
+	s.mutex.Lock()
+	// the snapshot position must be durable, otherwise a crash leaves
+	// the log behind the snapshot and it is refused on every start
+	if s.wal != nil {
+		if err := s.wal.Sync(); err != nil {
+			s.mutex.Unlock()
+			s.logger.Error("failed to sync wal", zap.Error(err))
+			return err
+		}
+	}
+
+	lsn := s.lastLSN()
+	data := s.engine.Snapshot()
+	s.mutex.Unlock()
+
+	if err := s.snapshots.Save(lsn, data); err != nil {
+		s.logger.Error("failed to save snapshot", zap.Error(err))
+		return err
+	}
+
+	s.lastSnapshotAt.Store(time.Now().UnixNano())
+	s.lastSnapshotLSN.Store(lsn)
+
+	if s.wal != nil {
+		if err := s.wal.Truncate(lsn); err != nil {
+			s.logger.Error("failed to truncate wal", zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LastLSN returns the position of the last applied log record.
+func (s *Storage) LastLSN() int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.lastLSN()
+}
+
+func (s *Storage) Stats() StorageStats {
+	stats := StorageStats{
+		LastLSN:         s.LastLSN(),
+		LastSnapshotLSN: s.lastSnapshotLSN.Load(),
+	}
+
+	if lastSnapshotAt := s.lastSnapshotAt.Load(); lastSnapshotAt != 0 {
+		stats.LastSnapshotAt = time.Unix(0, lastSnapshotAt)
+	}
+
+	return stats
+}
+
+// Dump returns a copy of the engine state together with the
+// position of the last log record included into it.
+func (s *Storage) Dump() (int64, io.WriterTo) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.lastLSN(), s.engine.Snapshot()
+}
+
+// ApplyReplicated logs and applies records received from a replication master,
+// they must directly follow the last applied record.
+func (s *Storage) ApplyReplicated(ctx context.Context, records []wal.Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, record := range records {
+		if expectedLSN := s.lastLSN() + 1; record.LSN != expectedLSN {
+			return fmt.Errorf("%w: expected lsn %d, got %d", wal.ErrRecordsMissing, expectedLSN, record.LSN)
+		}
+
+		if s.wal != nil {
+			if err := s.wal.AppendRecord(ctx, record); err != nil {
+				txID := txcontext.TxID(ctx)
+				s.logger.Error("failed to write to wal", zap.Int64("tx", txID), zap.Error(err))
+				return err
+			}
+		}
+
+		if err := s.applyRecord(record); err != nil {
+			return err
+		}
+
+		s.appliedLSN = record.LSN
+	}
+
+	return nil
+}
+
+// RestoreReplicated replaces the state with a master snapshot taken at lsn,
+// the local log is restarted right after it.
+func (s *Storage) RestoreReplicated(ctx context.Context, lsn int64, data io.Reader) error {
+	s.snapshotMutex.Lock()
+	defer s.snapshotMutex.Unlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.engine.RestoreSnapshot(data); err != nil {
+		return err
+	}
+
+	// the snapshot is saved first, so the log position
+	// is never ahead of what can be recovered
+	if s.snapshots != nil {
+		if err := s.snapshots.Save(lsn, s.engine.Snapshot()); err != nil {
+			s.logger.Error("failed to save snapshot", zap.Error(err))
+			return err
+		}
+	}
+
+	if s.wal != nil {
+		if err := s.wal.Reset(lsn); err != nil {
+			s.logger.Error("failed to reset wal", zap.Error(err))
+			return err
+		}
+	}
+
+	s.appliedLSN = lsn
+	txID := txcontext.TxID(ctx)
+	s.logger.Info("state restored from master", zap.Int64("tx", txID), zap.Int64("lsn", lsn))
+	return nil
+}
+
+// logSet logs the value of the key, zero expiration time means a persistent key.
+func (s *Storage) logSet(ctx context.Context, key, value string, expiresAt time.Time) error {
+	if s.wal == nil {
+		return nil
+	}
+
+	var err error
+	if expiresAt.IsZero() {
+		err = s.wal.Set(ctx, key, value)
+	} else {
+		err = s.wal.SetWithExpiration(ctx, key, value, expiresAt)
+	}
+
+	if err != nil {
+		txID := txcontext.TxID(ctx)
+		s.logger.Error("failed to write to wal", zap.Int64("tx", txID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// makeRoom makes room for the key with the value in the engine, evicted keys
+// are logged as deleted, so that the log replay and the replicas evict them too.
+func (s *Storage) makeRoom(ctx context.Context, key, value string) error {
+	if s.wal == nil {
+		return s.engine.MakeRoom(ctx, key, value, nil)
+	}
+
+	return s.engine.MakeRoom(ctx, key, value, func(evicted string) error {
+		if err := s.wal.Del(ctx, evicted); err != nil {
+			txID := txcontext.TxID(ctx)
+			s.logger.Error("failed to write to wal", zap.Int64("tx", txID), zap.Error(err))
+			return err
+		}
+
+		return nil
+	})
+}
+
+// lockWrite locks the storage for a write which is logged and applied
+// to the engine as two separate steps. Concurrent writes of a key must
+// reach the log and the engine in the same order, so with the log enabled
+// the storage is locked exclusively, the log serializes writes anyway.
+// Writes logged under the engine key lock, like Update, only read lock it.
+func (s *Storage) lockWrite() (unlock func()) {
+	if s.wal == nil {
+		s.mutex.RLock()
+		return s.mutex.RUnlock
+	}
+
+	s.mutex.Lock()
+	return s.mutex.Unlock
+}
+
+func (s *Storage) lastLSN() int64 {
+	if s.wal != nil {
+		return s.wal.LastLSN()
+	}
+
+	return s.appliedLSN
+}
+
+func (s *Storage) recover() error {
+	var lsn int64
+	if s.snapshots != nil {
+		var err error
+		lsn, err = s.snapshots.LoadLatest(s.engine.RestoreSnapshot)
+		if err != nil && !errors.Is(err, snapshot.ErrNoSnapshot) {
+			return fmt.Errorf("failed to load snapshot: %w", err)
+		}
+	}
+
+	if s.wal == nil {
+		s.appliedLSN = lsn
+		return nil
+	}
+
+	if lsn != 0 && s.wal.LastLSN() < lsn {
+		return fmt.Errorf("wal ends before snapshot position %d", lsn)
+	}
+
+	if err := s.wal.Replay(lsn, s.applyRecord); err != nil {
+		return fmt.Errorf("failed to recover from wal: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) applyRecord(record wal.Record) error {
+	ctx := txcontext.WithTxID(context.Background(), record.LSN)
+	arguments := record.Arguments
+
+	switch {
+	case record.CommandID == compute.SetCommandID && len(arguments) == 2:
+		s.engine.Set(ctx, arguments[0], arguments[1])
+	case record.CommandID == compute.SetCommandID && len(arguments) == 3:
+		expiresAt, err := wal.ParseDeadline(arguments[2])
+		if err != nil {
+			return err
+		}
+		s.engine.SetWithExpiration(ctx, arguments[0], arguments[1], expiresAt)
+	case record.CommandID == compute.DelCommandID && len(arguments) == 1:
+		s.engine.Del(ctx, arguments[0])
+	case record.CommandID == compute.ExpireCommandID && len(arguments) == 2:
+		expiresAt, err := wal.ParseDeadline(arguments[1])
+		if err != nil {
+			return err
+		}
+		s.engine.Expire(ctx, arguments[0], expiresAt)
+	case record.CommandID == compute.PersistCommandID && len(arguments) == 1:
+		s.engine.Persist(ctx, arguments[0])
+	case record.CommandID == compute.HSetCommandID && len(arguments) >= 3 && len(arguments)%2 == 1:
+		fields := make(map[string]string, len(arguments)/2)
+		for i := 1; i < len(arguments); i += 2 {
+			fields[arguments[i]] = arguments[i+1]
+		}
+
+		return s.engine.HashUpdate(ctx, arguments[0], func(map[string]string) (map[string]string, []string, error) {
+			return fields, nil, nil
+		})
+	case record.CommandID == compute.HDelCommandID && len(arguments) >= 2:
+		return s.engine.HashUpdate(ctx, arguments[0], func(map[string]string) (map[string]string, []string, error) {
+			return nil, arguments[1:], nil
+		})
+	case record.CommandID == compute.CommitCommandID:
+		values, deleted, err := wal.DecodeCommit(arguments)
+		if err != nil {
+			return err
+		}
+
+		for key, value := range values {
+			s.engine.Set(ctx, key, value)
+		}
+
+		for _, key := range deleted {
+			s.engine.Del(ctx, key)
+		}
+	default:
+		return fmt.Errorf("invalid log record %d", record.LSN)
+	}
+
+	return nil
+}
+
+// apply logs the writes as a single record and applies them to the engine,
+// the storage must be locked for writing.
+func (s *Storage) apply(ctx context.Context, writes []KeyState) error {
+	if len(writes) == 0 {
+		return nil
+	}
+
+	for _, write := range writes {
+		if !write.Found {
+			continue
+		}
+
+		if err := s.makeRoom(ctx, write.Key, write.Value); err != nil {
+			return err
+		}
+	}
+
+	if s.wal != nil {
+		values := make(map[string]string)
+		var deleted []string
+		for _, write := range writes {
+			if write.Found {
+				values[write.Key] = write.Value
+			} else {
+				deleted = append(deleted, write.Key)
+			}
+		}
+
+		if err := s.wal.Commit(ctx, values, deleted); err != nil {
+			txID := txcontext.TxID(ctx)
+			s.logger.Error("failed to write to wal", zap.Int64("tx", txID), zap.Error(err))
+			return err
+		}
+	}
+
+	for _, write := range writes {
+		if write.Found {
+			s.engine.Set(ctx, write.Key, write.Value)
+		} else {
+			s.engine.Del(ctx, write.Key)
+		}
+	}
+
 	return nil
 }
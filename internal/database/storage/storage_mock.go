@@ -6,7 +6,10 @@ package storage
 
 import (
 	context "context"
+	wal "inmem-db-go/internal/database/storage/wal"
+	io "io"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,9 +38,11 @@ func (m *MockEngine) EXPECT() *MockEngineMockRecorder {
 }
 
 // Del mocks base method.
-func (m *MockEngine) Del(arg0 context.Context, arg1 string) {
+func (m *MockEngine) Del(arg0 context.Context, arg1 string) bool {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Del", arg0, arg1)
+	ret := m.ctrl.Call(m, "Del", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	return ret0
 }
 
 // Del indicates an expected call of Del.
@@ -46,6 +51,49 @@ func (mr *MockEngineMockRecorder) Del(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockEngine)(nil).Del), arg0, arg1)
 }
 
+// Exists mocks base method.
+func (m *MockEngine) Exists(arg0 context.Context, arg1 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exists", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Exists indicates an expected call of Exists.
+func (mr *MockEngineMockRecorder) Exists(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockEngine)(nil).Exists), arg0, arg1)
+}
+
+// Expiration mocks base method.
+func (m *MockEngine) Expiration(arg0 context.Context, arg1 string) (time.Time, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expiration", arg0, arg1)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Expiration indicates an expected call of Expiration.
+func (mr *MockEngineMockRecorder) Expiration(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expiration", reflect.TypeOf((*MockEngine)(nil).Expiration), arg0, arg1)
+}
+
+// Expire mocks base method.
+func (m *MockEngine) Expire(arg0 context.Context, arg1 string, arg2 time.Time) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expire", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Expire indicates an expected call of Expire.
+func (mr *MockEngineMockRecorder) Expire(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expire", reflect.TypeOf((*MockEngine)(nil).Expire), arg0, arg1, arg2)
+}
+
 // Get mocks base method.
 func (m *MockEngine) Get(arg0 context.Context, arg1 string) (string, bool) {
 	m.ctrl.T.Helper()
@@ -61,6 +109,168 @@ func (mr *MockEngineMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockEngine)(nil).Get), arg0, arg1)
 }
 
+// GetString mocks base method.
+func (m *MockEngine) GetString(arg0 context.Context, arg1 string) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetString", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetString indicates an expected call of GetString.
+func (mr *MockEngineMockRecorder) GetString(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetString", reflect.TypeOf((*MockEngine)(nil).GetString), arg0, arg1)
+}
+
+// HashGet mocks base method.
+func (m *MockEngine) HashGet(arg0 context.Context, arg1, arg2 string) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HashGet", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// HashGet indicates an expected call of HashGet.
+func (mr *MockEngineMockRecorder) HashGet(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashGet", reflect.TypeOf((*MockEngine)(nil).HashGet), arg0, arg1, arg2)
+}
+
+// HashGetAll mocks base method.
+func (m *MockEngine) HashGetAll(arg0 context.Context, arg1 string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HashGetAll", arg0, arg1)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HashGetAll indicates an expected call of HashGetAll.
+func (mr *MockEngineMockRecorder) HashGetAll(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashGetAll", reflect.TypeOf((*MockEngine)(nil).HashGetAll), arg0, arg1)
+}
+
+// HashLen mocks base method.
+func (m *MockEngine) HashLen(arg0 context.Context, arg1 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HashLen", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HashLen indicates an expected call of HashLen.
+func (mr *MockEngineMockRecorder) HashLen(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashLen", reflect.TypeOf((*MockEngine)(nil).HashLen), arg0, arg1)
+}
+
+// HashUpdate mocks base method.
+func (m *MockEngine) HashUpdate(arg0 context.Context, arg1 string, arg2 func(map[string]string) (map[string]string, []string, error)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HashUpdate", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HashUpdate indicates an expected call of HashUpdate.
+func (mr *MockEngineMockRecorder) HashUpdate(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashUpdate", reflect.TypeOf((*MockEngine)(nil).HashUpdate), arg0, arg1, arg2)
+}
+
+// Keys mocks base method.
+func (m *MockEngine) Keys(arg0 context.Context) []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Keys", arg0)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Keys indicates an expected call of Keys.
+func (mr *MockEngineMockRecorder) Keys(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Keys", reflect.TypeOf((*MockEngine)(nil).Keys), arg0)
+}
+
+// MakeRoom mocks base method.
+func (m *MockEngine) MakeRoom(arg0 context.Context, arg1, arg2 string, arg3 func(string) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MakeRoom", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MakeRoom indicates an expected call of MakeRoom.
+func (mr *MockEngineMockRecorder) MakeRoom(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeRoom", reflect.TypeOf((*MockEngine)(nil).MakeRoom), arg0, arg1, arg2, arg3)
+}
+
+// Persist mocks base method.
+func (m *MockEngine) Persist(arg0 context.Context, arg1 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Persist", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Persist indicates an expected call of Persist.
+func (mr *MockEngineMockRecorder) Persist(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Persist", reflect.TypeOf((*MockEngine)(nil).Persist), arg0, arg1)
+}
+
+// Replace mocks base method.
+func (m *MockEngine) Replace(arg0 context.Context, arg1 string, arg2 func(bool) (string, time.Time, error)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replace indicates an expected call of Replace.
+func (mr *MockEngineMockRecorder) Replace(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockEngine)(nil).Replace), arg0, arg1, arg2)
+}
+
+// RestoreSnapshot mocks base method.
+func (m *MockEngine) RestoreSnapshot(arg0 io.Reader) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreSnapshot", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreSnapshot indicates an expected call of RestoreSnapshot.
+func (mr *MockEngineMockRecorder) RestoreSnapshot(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreSnapshot", reflect.TypeOf((*MockEngine)(nil).RestoreSnapshot), arg0)
+}
+
+// Scan mocks base method.
+func (m *MockEngine) Scan(arg0 context.Context, arg1 string, arg2 int) ([]string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Scan", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockEngineMockRecorder) Scan(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockEngine)(nil).Scan), arg0, arg1, arg2)
+}
+
 // Set mocks base method.
 func (m *MockEngine) Set(arg0 context.Context, arg1, arg2 string) {
 	m.ctrl.T.Helper()
@@ -72,3 +282,328 @@ func (mr *MockEngineMockRecorder) Set(arg0, arg1, arg2 interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockEngine)(nil).Set), arg0, arg1, arg2)
 }
+
+// SetWithExpiration mocks base method.
+func (m *MockEngine) SetWithExpiration(arg0 context.Context, arg1, arg2 string, arg3 time.Time) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetWithExpiration", arg0, arg1, arg2, arg3)
+}
+
+// SetWithExpiration indicates an expected call of SetWithExpiration.
+func (mr *MockEngineMockRecorder) SetWithExpiration(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithExpiration", reflect.TypeOf((*MockEngine)(nil).SetWithExpiration), arg0, arg1, arg2, arg3)
+}
+
+// Size mocks base method.
+func (m *MockEngine) Size(arg0 context.Context) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Size", arg0)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Size indicates an expected call of Size.
+func (mr *MockEngineMockRecorder) Size(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Size", reflect.TypeOf((*MockEngine)(nil).Size), arg0)
+}
+
+// Snapshot mocks base method.
+func (m *MockEngine) Snapshot() io.WriterTo {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Snapshot")
+	ret0, _ := ret[0].(io.WriterTo)
+	return ret0
+}
+
+// Snapshot indicates an expected call of Snapshot.
+func (mr *MockEngineMockRecorder) Snapshot() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Snapshot", reflect.TypeOf((*MockEngine)(nil).Snapshot))
+}
+
+// Update mocks base method.
+func (m *MockEngine) Update(arg0 context.Context, arg1 string, arg2 func(string, bool, time.Time) (string, time.Time, error)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockEngineMockRecorder) Update(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockEngine)(nil).Update), arg0, arg1, arg2)
+}
+
+// MockWAL is a mock of WAL interface.
+type MockWAL struct {
+	ctrl     *gomock.Controller
+	recorder *MockWALMockRecorder
+}
+
+// MockWALMockRecorder is the mock recorder for MockWAL.
+type MockWALMockRecorder struct {
+	mock *MockWAL
+}
+
+// NewMockWAL creates a new mock instance.
+func NewMockWAL(ctrl *gomock.Controller) *MockWAL {
+	mock := &MockWAL{ctrl: ctrl}
+	mock.recorder = &MockWALMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWAL) EXPECT() *MockWALMockRecorder {
+	return m.recorder
+}
+
+// AppendRecord mocks base method.
+func (m *MockWAL) AppendRecord(arg0 context.Context, arg1 wal.Record) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendRecord", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AppendRecord indicates an expected call of AppendRecord.
+func (mr *MockWALMockRecorder) AppendRecord(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendRecord", reflect.TypeOf((*MockWAL)(nil).AppendRecord), arg0, arg1)
+}
+
+// Commit mocks base method.
+func (m *MockWAL) Commit(arg0 context.Context, arg1 map[string]string, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Commit", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Commit indicates an expected call of Commit.
+func (mr *MockWALMockRecorder) Commit(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockWAL)(nil).Commit), arg0, arg1, arg2)
+}
+
+// Del mocks base method.
+func (m *MockWAL) Del(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Del", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Del indicates an expected call of Del.
+func (mr *MockWALMockRecorder) Del(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockWAL)(nil).Del), arg0, arg1)
+}
+
+// Expire mocks base method.
+func (m *MockWAL) Expire(arg0 context.Context, arg1 string, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expire", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Expire indicates an expected call of Expire.
+func (mr *MockWALMockRecorder) Expire(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expire", reflect.TypeOf((*MockWAL)(nil).Expire), arg0, arg1, arg2)
+}
+
+// HDel mocks base method.
+func (m *MockWAL) HDel(arg0 context.Context, arg1 string, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HDel", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HDel indicates an expected call of HDel.
+func (mr *MockWALMockRecorder) HDel(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HDel", reflect.TypeOf((*MockWAL)(nil).HDel), arg0, arg1, arg2)
+}
+
+// HSet mocks base method.
+func (m *MockWAL) HSet(arg0 context.Context, arg1 string, arg2 map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HSet", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HSet indicates an expected call of HSet.
+func (mr *MockWALMockRecorder) HSet(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HSet", reflect.TypeOf((*MockWAL)(nil).HSet), arg0, arg1, arg2)
+}
+
+// LastLSN mocks base method.
+func (m *MockWAL) LastLSN() int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastLSN")
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// LastLSN indicates an expected call of LastLSN.
+func (mr *MockWALMockRecorder) LastLSN() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastLSN", reflect.TypeOf((*MockWAL)(nil).LastLSN))
+}
+
+// Persist mocks base method.
+func (m *MockWAL) Persist(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Persist", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Persist indicates an expected call of Persist.
+func (mr *MockWALMockRecorder) Persist(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Persist", reflect.TypeOf((*MockWAL)(nil).Persist), arg0, arg1)
+}
+
+// Replay mocks base method.
+func (m *MockWAL) Replay(arg0 int64, arg1 func(wal.Record) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replay", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replay indicates an expected call of Replay.
+func (mr *MockWALMockRecorder) Replay(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replay", reflect.TypeOf((*MockWAL)(nil).Replay), arg0, arg1)
+}
+
+// Reset mocks base method.
+func (m *MockWAL) Reset(arg0 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockWALMockRecorder) Reset(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockWAL)(nil).Reset), arg0)
+}
+
+// Set mocks base method.
+func (m *MockWAL) Set(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockWALMockRecorder) Set(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockWAL)(nil).Set), arg0, arg1, arg2)
+}
+
+// SetWithExpiration mocks base method.
+func (m *MockWAL) SetWithExpiration(arg0 context.Context, arg1, arg2 string, arg3 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetWithExpiration", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetWithExpiration indicates an expected call of SetWithExpiration.
+func (mr *MockWALMockRecorder) SetWithExpiration(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithExpiration", reflect.TypeOf((*MockWAL)(nil).SetWithExpiration), arg0, arg1, arg2, arg3)
+}
+
+// Sync mocks base method.
+func (m *MockWAL) Sync() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sync")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Sync indicates an expected call of Sync.
+func (mr *MockWALMockRecorder) Sync() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sync", reflect.TypeOf((*MockWAL)(nil).Sync))
+}
+
+// Truncate mocks base method.
+func (m *MockWAL) Truncate(arg0 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Truncate", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Truncate indicates an expected call of Truncate.
+func (mr *MockWALMockRecorder) Truncate(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Truncate", reflect.TypeOf((*MockWAL)(nil).Truncate), arg0)
+}
+
+// MockSnapshotManager is a mock of SnapshotManager interface.
+type MockSnapshotManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockSnapshotManagerMockRecorder
+}
+
+// MockSnapshotManagerMockRecorder is the mock recorder for MockSnapshotManager.
+type MockSnapshotManagerMockRecorder struct {
+	mock *MockSnapshotManager
+}
+
+// NewMockSnapshotManager creates a new mock instance.
+func NewMockSnapshotManager(ctrl *gomock.Controller) *MockSnapshotManager {
+	mock := &MockSnapshotManager{ctrl: ctrl}
+	mock.recorder = &MockSnapshotManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSnapshotManager) EXPECT() *MockSnapshotManagerMockRecorder {
+	return m.recorder
+}
+
+// LoadLatest mocks base method.
+func (m *MockSnapshotManager) LoadLatest(arg0 func(io.Reader) error) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadLatest", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadLatest indicates an expected call of LoadLatest.
+func (mr *MockSnapshotManagerMockRecorder) LoadLatest(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadLatest", reflect.TypeOf((*MockSnapshotManager)(nil).LoadLatest), arg0)
+}
+
+// Save mocks base method.
+func (m *MockSnapshotManager) Save(arg0 int64, arg1 io.WriterTo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockSnapshotManagerMockRecorder) Save(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockSnapshotManager)(nil).Save), arg0, arg1)
+}
@@ -1,11 +1,21 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/storage/engine/in_memory"
+	"inmem-db-go/internal/database/storage/snapshot"
+	"inmem-db-go/internal/database/storage/wal"
+	"inmem-db-go/internal/database/txcontext"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 )
 
 // mockgen -source=storage.go -destination=storage_mock.go -package=storage
@@ -32,7 +42,7 @@ func TestNewStorage(t *testing.T) {
 func TestSetWithCanceledContext(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 	ctxWithCancel, cancel := context.WithCancel(ctx)
 	cancel()
 
@@ -49,10 +59,13 @@ func TestSetWithCanceledContext(t *testing.T) {
 func TestSuccessfulSet(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	ctrl := gomock.NewController(t)
 	engine := NewMockEngine(ctrl)
+	engine.EXPECT().
+		MakeRoom(ctx, "key", "value", gomock.Any()).
+		Return(nil)
 	engine.EXPECT().
 		Set(ctx, "key", "value")
 
@@ -66,7 +79,7 @@ func TestSuccessfulSet(t *testing.T) {
 func TestGetWithCanceledContext(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 	ctxWithCancel, cancel := context.WithCancel(ctx)
 	cancel()
 
@@ -84,12 +97,12 @@ func TestGetWithCanceledContext(t *testing.T) {
 func TestSuccessfulGet(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	ctrl := gomock.NewController(t)
 	engine := NewMockEngine(ctrl)
 	engine.EXPECT().
-		Get(ctx, "key").Return("value", true)
+		GetString(ctx, "key").Return("value", true, nil)
 
 	storage, err := NewStorage(engine, zap.NewNop())
 	require.NoError(t, err)
@@ -99,10 +112,33 @@ func TestSuccessfulGet(t *testing.T) {
 	require.Equal(t, "value", value)
 }
 
+func TestGetNotFound(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	engine.EXPECT().
+		GetString(ctx, "key").Return("", false, nil)
+	engine.EXPECT().
+		GetString(ctx, "empty").Return("", true, nil)
+
+	storage, err := NewStorage(engine, zap.NewNop())
+	require.NoError(t, err)
+
+	_, err = storage.Get(ctx, "key")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	value, err := storage.Get(ctx, "empty")
+	require.NoError(t, err)
+	require.Equal(t, "", value)
+}
+
 func TestDelWithCanceledContext(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
 	ctxWithCancel, cancel := context.WithCancel(ctx)
 	cancel()
 
@@ -119,16 +155,826 @@ func TestDelWithCanceledContext(t *testing.T) {
 func TestSuccessfulDel(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.WithValue(context.Background(), "tx", int64(555))
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	engine.EXPECT().
+		Del(ctx, "key").Return(true)
+
+	storage, err := NewStorage(engine, zap.NewNop())
+	require.NoError(t, err)
+
+	err = storage.Del(ctx, "key")
+	require.NoError(t, err)
+}
+
+func TestDelNotFound(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
 
 	ctrl := gomock.NewController(t)
 	engine := NewMockEngine(ctrl)
 	engine.EXPECT().
-		Del(ctx, "key")
+		Del(ctx, "key").Return(false)
 
 	storage, err := NewStorage(engine, zap.NewNop())
 	require.NoError(t, err)
 
+	err = storage.Del(ctx, "key")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestNewStorageRecoversFromWAL(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	gomock.InOrder(
+		engine.EXPECT().Set(gomock.Any(), "key_1", "value_1"),
+		engine.EXPECT().Del(gomock.Any(), "key_1"),
+	)
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().
+		Replay(int64(0), gomock.Any()).
+		DoAndReturn(func(_ int64, apply func(wal.Record) error) error {
+			if err := apply(wal.NewRecord(1, compute.SetCommandID, []string{"key_1", "value_1"})); err != nil {
+				return err
+			}
+			return apply(wal.NewRecord(2, compute.DelCommandID, []string{"key_1"}))
+		})
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+	require.NotNil(t, storage)
+}
+
+func TestNewStorageWithInvalidWALRecord(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().
+		Replay(int64(0), gomock.Any()).
+		DoAndReturn(func(_ int64, apply func(wal.Record) error) error {
+			return apply(wal.NewRecord(1, compute.SetCommandID, []string{"key_1"}))
+		})
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.Error(t, err)
+	require.Nil(t, storage)
+}
+
+func TestSetWithWAL(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	gomock.InOrder(
+		engine.EXPECT().MakeRoom(ctx, "key", "value", gomock.Any()).Return(nil),
+		log.EXPECT().Set(ctx, "key", "value").Return(nil),
+		engine.EXPECT().Set(ctx, "key", "value"),
+	)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	err = storage.Set(ctx, "key", "value")
+	require.NoError(t, err)
+}
+
+func TestSetWithWALError(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	walErr := errors.New("disk is full")
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	engine.EXPECT().MakeRoom(ctx, "key", "value", gomock.Any()).Return(nil)
+	log.EXPECT().Set(ctx, "key", "value").Return(walErr)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	err = storage.Set(ctx, "key", "value")
+	require.ErrorIs(t, err, walErr)
+}
+
+func TestUpdateWithWAL(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	expiresAt := time.Now().Add(time.Hour)
+	errFailed := errors.New("failed")
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	engine.EXPECT().MakeRoom(ctx, "key", "", gomock.Any()).Return(nil).Times(4)
+	engine.EXPECT().Update(ctx, "key", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, fn func(string, bool, time.Time) (string, time.Time, error)) error {
+			_, _, err := fn("1", true, expiresAt)
+			return err
+		})
+	engine.EXPECT().Update(ctx, "key", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, fn func(string, bool, time.Time) (string, time.Time, error)) error {
+			_, _, err := fn("", false, time.Time{})
+			return err
+		}).Times(3)
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	log.EXPECT().SetWithExpiration(ctx, "key", "11", expiresAt).Return(nil)
+	log.EXPECT().Set(ctx, "key", "1").Return(errFailed)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	appendOne := func(value string, _ bool, expiresAt time.Time) (string, time.Time, error) {
+		return value + "1", expiresAt, nil
+	}
+
+	updated, err := storage.Update(ctx, "key", appendOne)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	_, err = storage.Update(ctx, "key", appendOne)
+	require.ErrorIs(t, err, errFailed)
+
+	_, err = storage.Update(ctx, "key", func(string, bool, time.Time) (string, time.Time, error) {
+		return "", time.Time{}, ErrNotFound
+	})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	updated, err = storage.Update(ctx, "key", func(string, bool, time.Time) (string, time.Time, error) {
+		return "", time.Time{}, ErrSkipUpdate
+	})
+	require.NoError(t, err)
+	require.False(t, updated)
+}
+
+func TestReplaceWithWAL(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	expiresAt := time.Now().Add(time.Hour)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	engine.EXPECT().MakeRoom(ctx, "key", "", gomock.Any()).Return(nil).Times(2)
+	engine.EXPECT().Replace(ctx, "key", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, fn func(bool) (string, time.Time, error)) error {
+			_, _, err := fn(true)
+			return err
+		}).Times(2)
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	log.EXPECT().SetWithExpiration(ctx, "key", "value", expiresAt).Return(nil)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	replaced, err := storage.Replace(ctx, "key", func(exists bool) (string, time.Time, error) {
+		require.True(t, exists)
+		return "value", expiresAt, nil
+	})
+	require.NoError(t, err)
+	require.True(t, replaced)
+
+	// nothing is logged for a skipped write
+	replaced, err = storage.Replace(ctx, "key", func(bool) (string, time.Time, error) {
+		return "", time.Time{}, ErrSkipUpdate
+	})
+	require.NoError(t, err)
+	require.False(t, replaced)
+}
+
+func TestSetWithoutRoom(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	memoryErr := errors.New("out of memory")
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	engine.EXPECT().MakeRoom(ctx, "key", "value", gomock.Any()).Return(memoryErr)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	// nothing is logged for a rejected write
+	err = storage.Set(ctx, "key", "value")
+	require.ErrorIs(t, err, memoryErr)
+}
+
+func TestSetLogsEvictions(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	errFailed := errors.New("failed")
+	evict := func(_ context.Context, _, _ string, evict func(string) error) error {
+		return evict("evicted")
+	}
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	gomock.InOrder(
+		engine.EXPECT().MakeRoom(ctx, "key", "value", gomock.Any()).DoAndReturn(evict),
+		log.EXPECT().Del(ctx, "evicted").Return(nil),
+		log.EXPECT().Set(ctx, "key", "value").Return(nil),
+		engine.EXPECT().Set(ctx, "key", "value"),
+		engine.EXPECT().MakeRoom(ctx, "key", "value", gomock.Any()).DoAndReturn(evict),
+		log.EXPECT().Del(ctx, "evicted").Return(errFailed),
+	)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	require.NoError(t, storage.Set(ctx, "key", "value"))
+
+	// the write is rejected when the eviction is not logged
+	err = storage.Set(ctx, "key", "value")
+	require.ErrorIs(t, err, errFailed)
+}
+
+func TestDelWithWAL(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	gomock.InOrder(
+		log.EXPECT().Del(ctx, "key").Return(nil),
+		engine.EXPECT().Del(ctx, "key").Return(true),
+	)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
 	err = storage.Del(ctx, "key")
 	require.NoError(t, err)
 }
+
+func TestConcurrentWritesAreLoggedInApplyOrder(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	var mutex sync.Mutex
+	var events []string
+	record := func(event string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, event)
+	}
+
+	logged := make(chan struct{})
+	release := make(chan struct{})
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	engine.EXPECT().MakeRoom(ctx, "key", gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	engine.EXPECT().Set(ctx, "key", gomock.Any()).
+		Do(func(_ context.Context, _, value string) { record("apply " + value) }).Times(2)
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	log.EXPECT().Set(ctx, "key", "first").DoAndReturn(func(context.Context, string, string) error {
+		record("log first")
+		close(logged)
+		<-release
+		return nil
+	})
+	log.EXPECT().Set(ctx, "key", "second").DoAndReturn(func(context.Context, string, string) error {
+		record("log second")
+		return nil
+	})
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, storage.Set(ctx, "key", "first"))
+	}()
+	<-logged
+
+	second := make(chan struct{})
+	go func() {
+		defer close(second)
+		require.NoError(t, storage.Set(ctx, "key", "second"))
+	}()
+
+	// the second write waits until the first one is applied
+	require.Never(t, func() bool {
+		select {
+		case <-second:
+			return true
+		default:
+			return false
+		}
+	}, 50*time.Millisecond, 5*time.Millisecond)
+
+	close(release)
+	wg.Wait()
+	<-second
+
+	require.Equal(t, []string{"log first", "apply first", "log second", "apply second"}, events)
+}
+
+func TestNewStorageLoadsSnapshot(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	snapshots := NewMockSnapshotManager(ctrl)
+	snapshots.EXPECT().LoadLatest(gomock.Any()).Return(int64(10), nil)
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().LastLSN().Return(int64(12))
+	log.EXPECT().Replay(int64(10), gomock.Any()).Return(nil)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log), WithSnapshots(snapshots))
+	require.NoError(t, err)
+	require.NotNil(t, storage)
+}
+
+func TestNewStorageWithoutSnapshot(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	snapshots := NewMockSnapshotManager(ctrl)
+	snapshots.EXPECT().LoadLatest(gomock.Any()).Return(int64(0), snapshot.ErrNoSnapshot)
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log), WithSnapshots(snapshots))
+	require.NoError(t, err)
+	require.NotNil(t, storage)
+}
+
+func TestNewStorageWithWALBehindSnapshot(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	snapshots := NewMockSnapshotManager(ctrl)
+	snapshots.EXPECT().LoadLatest(gomock.Any()).Return(int64(10), nil)
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().LastLSN().Return(int64(3))
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log), WithSnapshots(snapshots))
+	require.Error(t, err)
+	require.Nil(t, storage)
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	data := bytes.NewBufferString("snapshot")
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	snapshots := NewMockSnapshotManager(ctrl)
+	snapshots.EXPECT().LoadLatest(gomock.Any()).Return(int64(0), snapshot.ErrNoSnapshot)
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	gomock.InOrder(
+		log.EXPECT().Sync().Return(nil),
+		log.EXPECT().LastLSN().Return(int64(42)),
+		engine.EXPECT().Snapshot().Return(data),
+		snapshots.EXPECT().Save(int64(42), data).Return(nil),
+		log.EXPECT().Truncate(int64(42)).Return(nil),
+	)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log), WithSnapshots(snapshots))
+	require.NoError(t, err)
+
+	err = storage.Snapshot(ctx)
+	require.NoError(t, err)
+}
+
+func TestSnapshotSaveError(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	data := bytes.NewBufferString("snapshot")
+	saveErr := errors.New("disk is full")
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	engine.EXPECT().Snapshot().Return(data)
+	snapshots := NewMockSnapshotManager(ctrl)
+	snapshots.EXPECT().LoadLatest(gomock.Any()).Return(int64(0), snapshot.ErrNoSnapshot)
+	snapshots.EXPECT().Save(int64(0), data).Return(saveErr)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithSnapshots(snapshots))
+	require.NoError(t, err)
+
+	err = storage.Snapshot(ctx)
+	require.ErrorIs(t, err, saveErr)
+}
+
+func TestSnapshotWithBatchedWAL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	directory := t.TempDir()
+
+	newStorage := func() (*Storage, error) {
+		engine, err := in_memory.NewEngine(in_memory.HashTableBuilder, zap.NewNop())
+		require.NoError(t, err)
+		log, err := wal.NewWAL(filepath.Join(directory, "wal"), zap.NewNop(), wal.WithFlushStrategy(wal.FlushBySize))
+		require.NoError(t, err)
+		snapshots, err := snapshot.NewManager(filepath.Join(directory, "snapshots"), zap.NewNop())
+		require.NoError(t, err)
+		return NewStorage(engine, zap.NewNop(), WithWAL(log), WithSnapshots(snapshots))
+	}
+
+	storage, err := newStorage()
+	require.NoError(t, err)
+	require.NoError(t, storage.Set(ctx, "key_1", "value_1"))
+	require.NoError(t, storage.Set(ctx, "key_2", "value_2"))
+	require.NoError(t, storage.Snapshot(ctx))
+
+	// the log is not closed like on a crash, the records
+	// covered by the snapshot are on disk all the same
+	storage, err = newStorage()
+	require.NoError(t, err)
+
+	value, err := storage.Get(ctx, "key_2")
+	require.NoError(t, err)
+	require.Equal(t, "value_2", value)
+}
+
+func TestSnapshotDisabled(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+
+	storage, err := NewStorage(engine, zap.NewNop())
+	require.NoError(t, err)
+
+	err = storage.Snapshot(ctx)
+	require.ErrorIs(t, err, errSnapshotsDisabled)
+}
+
+func TestNewStorageRecoversExpirationsFromWAL(t *testing.T) {
+	t.Parallel()
+
+	expiresAt := time.UnixMilli(1700000000123)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	gomock.InOrder(
+		engine.EXPECT().SetWithExpiration(gomock.Any(), "key_1", "value_1", expiresAt),
+		engine.EXPECT().Expire(gomock.Any(), "key_1", expiresAt).Return(true),
+		engine.EXPECT().Persist(gomock.Any(), "key_1").Return(true),
+	)
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().
+		Replay(int64(0), gomock.Any()).
+		DoAndReturn(func(_ int64, apply func(wal.Record) error) error {
+			records := []wal.Record{
+				wal.NewRecord(1, compute.SetCommandID, []string{"key_1", "value_1", "1700000000123"}),
+				wal.NewRecord(2, compute.ExpireCommandID, []string{"key_1", "1700000000123"}),
+				wal.NewRecord(3, compute.PersistCommandID, []string{"key_1"}),
+			}
+			for _, record := range records {
+				if err := apply(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+	require.NotNil(t, storage)
+}
+
+func TestSetWithExpiration(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	expiresAt := time.Now().Add(time.Minute)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	gomock.InOrder(
+		engine.EXPECT().MakeRoom(ctx, "key", "value", gomock.Any()).Return(nil),
+		log.EXPECT().SetWithExpiration(ctx, "key", "value", expiresAt).Return(nil),
+		engine.EXPECT().SetWithExpiration(ctx, "key", "value", expiresAt),
+	)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	err = storage.SetWithExpiration(ctx, "key", "value", expiresAt)
+	require.NoError(t, err)
+}
+
+func TestExpireAndPersist(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	expiresAt := time.Now().Add(time.Minute)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	gomock.InOrder(
+		log.EXPECT().Expire(ctx, "key", expiresAt).Return(nil),
+		engine.EXPECT().Expire(ctx, "key", expiresAt).Return(true),
+		engine.EXPECT().Expiration(ctx, "key").Return(expiresAt, true),
+		log.EXPECT().Persist(ctx, "key").Return(nil),
+		engine.EXPECT().Persist(ctx, "key").Return(false),
+	)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	updated, err := storage.Expire(ctx, "key", expiresAt)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	deadline, found, err := storage.Expiration(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, expiresAt, deadline)
+
+	updated, err = storage.Persist(ctx, "key")
+	require.NoError(t, err)
+	require.False(t, updated)
+}
+
+func TestCommit(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	gomock.InOrder(
+		engine.EXPECT().GetString(ctx, "read").Return("value", true, nil),
+		engine.EXPECT().GetString(ctx, "missing").Return("", false, nil),
+		engine.EXPECT().GetString(ctx, "key_2").Return("", false, in_memory.ErrWrongType),
+		engine.EXPECT().MakeRoom(ctx, "key_1", "value_1", gomock.Any()).Return(nil),
+		log.EXPECT().Commit(ctx, map[string]string{"key_1": "value_1"}, []string{"key_2"}).Return(nil),
+		engine.EXPECT().Set(ctx, "key_1", "value_1"),
+		engine.EXPECT().Del(ctx, "key_2").Return(true),
+	)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	err = storage.Commit(
+		ctx,
+		[]KeyState{
+			{Key: "read", Value: "value", Found: true},
+			{Key: "missing"},
+			{Key: "key_2", Found: true, OtherType: true},
+		},
+		[]KeyState{{Key: "key_1", Value: "value_1", Found: true}, {Key: "key_2"}},
+	)
+	require.NoError(t, err)
+}
+
+func TestMSet(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	gomock.InOrder(
+		engine.EXPECT().MakeRoom(ctx, "key_1", "value_1", gomock.Any()).Return(nil),
+		engine.EXPECT().MakeRoom(ctx, "key_1", "value_2", gomock.Any()).Return(nil),
+		log.EXPECT().Commit(ctx, map[string]string{"key_1": "value_2"}, nil).Return(nil),
+		engine.EXPECT().Set(ctx, "key_1", "value_1"),
+		engine.EXPECT().Set(ctx, "key_1", "value_2"),
+	)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	err = storage.MSet(ctx, []KeyState{
+		{Key: "key_1", Value: "value_1", Found: true},
+		{Key: "key_1", Value: "value_2", Found: true},
+	})
+	require.NoError(t, err)
+}
+
+func TestMGet(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	engine.EXPECT().Get(ctx, "key_1").Return("value_1", true)
+	engine.EXPECT().Get(ctx, "key_2").Return("", false)
+
+	storage, err := NewStorage(engine, zap.NewNop())
+	require.NoError(t, err)
+
+	states, err := storage.MGet(ctx, []string{"key_1", "key_2"})
+	require.NoError(t, err)
+	require.Equal(t, []KeyState{{Key: "key_1", Value: "value_1", Found: true}, {Key: "key_2"}}, states)
+}
+
+func TestMDel(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	gomock.InOrder(
+		engine.EXPECT().Exists(ctx, "key_1").Return(true),
+		engine.EXPECT().Exists(ctx, "missing").Return(false),
+		log.EXPECT().Commit(ctx, map[string]string{}, []string{"key_1"}).Return(nil),
+		engine.EXPECT().Del(ctx, "key_1").Return(true),
+	)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	deleted, err := storage.MDel(ctx, []string{"key_1", "missing", "key_1"})
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, false, false}, deleted)
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = storage.MDel(canceledCtx, []string{"missing"})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCommitConflict(t *testing.T) {
+	testCases := []struct {
+		name  string
+		read  KeyState
+		value string
+		found bool
+		err   error
+	}{
+		{name: "value changed", read: KeyState{Key: "key", Value: "old", Found: true}, value: "new", found: true},
+		{name: "key deleted", read: KeyState{Key: "key", Value: "old", Found: true}, found: false},
+		{name: "key created", read: KeyState{Key: "key"}, value: "new", found: true},
+		{name: "type changed", read: KeyState{Key: "key", Value: "old", Found: true}, err: in_memory.ErrWrongType},
+		{name: "other type replaced", read: KeyState{Key: "key", Found: true, OtherType: true}, value: "new", found: true},
+		{name: "other type deleted", read: KeyState{Key: "key", Found: true, OtherType: true}, found: false},
+	}
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			engine := NewMockEngine(ctrl)
+			engine.EXPECT().GetString(ctx, "key").Return(tc.value, tc.found, tc.err)
+			log := NewMockWAL(ctrl)
+			log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+
+			storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+			require.NoError(t, err)
+
+			err = storage.Commit(ctx, []KeyState{tc.read}, []KeyState{{Key: "key", Value: "mine", Found: true}})
+			require.ErrorIs(t, err, ErrConflict)
+		})
+	}
+}
+
+func TestNewStorageRecoversCommitFromWAL(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	engine.EXPECT().Set(gomock.Any(), "key_1", "value_1")
+	engine.EXPECT().Del(gomock.Any(), "key_2")
+
+	log := NewMockWAL(ctrl)
+	log.EXPECT().
+		Replay(int64(0), gomock.Any()).
+		DoAndReturn(func(_ int64, apply func(wal.Record) error) error {
+			return apply(wal.NewRecord(1, compute.CommitCommandID, []string{"SET", "key_1", "value_1", "DEL", "key_2"}))
+		})
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+	require.NotNil(t, storage)
+}
+
+func TestApplyReplicated(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	records := []wal.Record{
+		wal.NewRecord(4, compute.SetCommandID, []string{"key", "value"}),
+		wal.NewRecord(5, compute.DelCommandID, []string{"key"}),
+	}
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	log := NewMockWAL(ctrl)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	gomock.InOrder(
+		log.EXPECT().LastLSN().Return(int64(3)),
+		log.EXPECT().AppendRecord(ctx, records[0]).Return(nil),
+		engine.EXPECT().Set(gomock.Any(), "key", "value"),
+		log.EXPECT().LastLSN().Return(int64(4)),
+		log.EXPECT().AppendRecord(ctx, records[1]).Return(nil),
+		engine.EXPECT().Del(gomock.Any(), "key").Return(true),
+		log.EXPECT().LastLSN().Return(int64(5)),
+	)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log))
+	require.NoError(t, err)
+
+	require.NoError(t, storage.ApplyReplicated(ctx, records))
+
+	err = storage.ApplyReplicated(ctx, []wal.Record{wal.NewRecord(7, compute.DelCommandID, []string{"key"})})
+	require.ErrorIs(t, err, wal.ErrRecordsMissing)
+}
+
+func TestApplyReplicatedWithoutWAL(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	engine.EXPECT().Set(gomock.Any(), "key", "value")
+
+	storage, err := NewStorage(engine, zap.NewNop())
+	require.NoError(t, err)
+
+	require.NoError(t, storage.ApplyReplicated(ctx, []wal.Record{wal.NewRecord(1, compute.SetCommandID, []string{"key", "value"})}))
+	require.Equal(t, int64(1), storage.LastLSN())
+}
+
+func TestRestoreReplicated(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	data := &bytes.Buffer{}
+
+	ctrl := gomock.NewController(t)
+	engine := NewMockEngine(ctrl)
+	log := NewMockWAL(ctrl)
+	snapshots := NewMockSnapshotManager(ctrl)
+	snapshots.EXPECT().LoadLatest(gomock.Any()).Return(int64(0), snapshot.ErrNoSnapshot)
+	log.EXPECT().Replay(int64(0), gomock.Any()).Return(nil)
+	gomock.InOrder(
+		engine.EXPECT().RestoreSnapshot(data).Return(nil),
+		engine.EXPECT().Snapshot().Return(data),
+		snapshots.EXPECT().Save(int64(42), data).Return(nil),
+		log.EXPECT().Reset(int64(42)).Return(nil),
+	)
+
+	storage, err := NewStorage(engine, zap.NewNop(), WithWAL(log), WithSnapshots(snapshots))
+	require.NoError(t, err)
+
+	require.NoError(t, storage.RestoreReplicated(ctx, 42, data))
+}
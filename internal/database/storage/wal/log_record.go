@@ -0,0 +1,134 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// frame layout: payload length (uint32) | crc32 of payload (uint32) | payload
+const frameHeaderSize = 8
+
+var (
+	errCorruptedRecord = errors.New("corrupted log record")
+	errTruncatedRecord = errors.New("truncated log record")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type Record struct {
+	LSN       int64
+	CommandID int
+	Arguments []string
+}
+
+func NewRecord(lsn int64, commandID int, arguments []string) Record {
+	return Record{
+		LSN:       lsn,
+		CommandID: commandID,
+		Arguments: arguments,
+	}
+}
+
+func (r *Record) Encode() []byte {
+	payload := make([]byte, 0, 32)
+	payload = binary.AppendUvarint(payload, uint64(r.LSN))
+	payload = binary.AppendUvarint(payload, uint64(r.CommandID))
+	payload = binary.AppendUvarint(payload, uint64(len(r.Arguments)))
+	for _, argument := range r.Arguments {
+		payload = binary.AppendUvarint(payload, uint64(len(argument)))
+		payload = append(payload, argument...)
+	}
+
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	return append(frame, payload...)
+}
+
+// decodeRecord reads one frame. A frame cut in the middle is reported as
+// errTruncatedRecord and a frame with a wrong checksum as errCorruptedRecord,
+// clean end of the stream is io.EOF.
+func decodeRecord(reader io.Reader) (Record, int, error) {
+	var header [frameHeaderSize]byte
+	if n, err := io.ReadFull(reader, header[:]); err != nil {
+		if err == io.EOF {
+			return Record{}, 0, io.EOF
+		}
+
+		if err == io.ErrUnexpectedEOF {
+			return Record{}, n, errTruncatedRecord
+		}
+
+		return Record{}, n, err
+	}
+
+	size := binary.LittleEndian.Uint32(header[0:4])
+	checksum := binary.LittleEndian.Uint32(header[4:8])
+	if size > maxRecordSize {
+		return Record{}, frameHeaderSize, errCorruptedRecord
+	}
+
+	payload := make([]byte, size)
+	if n, err := io.ReadFull(reader, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return Record{}, frameHeaderSize + n, errTruncatedRecord
+		}
+
+		return Record{}, frameHeaderSize + n, err
+	}
+
+	frameSize := frameHeaderSize + int(size)
+	if crc32.Checksum(payload, crcTable) != checksum {
+		return Record{}, frameSize, errCorruptedRecord
+	}
+
+	record, err := decodePayload(payload)
+	if err != nil {
+		return Record{}, frameSize, err
+	}
+
+	return record, frameSize, nil
+}
+
+func decodePayload(payload []byte) (Record, error) {
+	var values [3]uint64
+	for i := range values {
+		value, n := binary.Uvarint(payload)
+		if n <= 0 {
+			return Record{}, errCorruptedRecord
+		}
+
+		values[i] = value
+		payload = payload[n:]
+	}
+
+	record := Record{
+		LSN:       int64(values[0]),
+		CommandID: int(values[1]),
+	}
+
+	argumentsNumber := values[2]
+	if argumentsNumber > uint64(len(payload)) {
+		return Record{}, errCorruptedRecord
+	}
+
+	record.Arguments = make([]string, 0, argumentsNumber)
+	for i := uint64(0); i < argumentsNumber; i++ {
+		size, n := binary.Uvarint(payload)
+		if n <= 0 || size > uint64(len(payload)-n) {
+			return Record{}, errCorruptedRecord
+		}
+
+		payload = payload[n:]
+		record.Arguments = append(record.Arguments, string(payload[:size]))
+		payload = payload[size:]
+	}
+
+	if len(payload) != 0 {
+		return Record{}, errCorruptedRecord
+	}
+
+	return record, nil
+}
@@ -0,0 +1,63 @@
+package wal
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestRecordEncodeDecode(t *testing.T) {
+	testCases := []struct {
+		name   string
+		record Record
+	}{
+		{name: "set record", record: NewRecord(1, 1, []string{"key", "value"})},
+		{name: "del record", record: NewRecord(2, 3, []string{"key"})},
+		{name: "empty value", record: NewRecord(3, 1, []string{"key", ""})},
+		{name: "binary value", record: NewRecord(1<<40, 1, []string{"key", "\x00\xff\n"})},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			frame := tc.record.Encode()
+			record, size, err := decodeRecord(bytes.NewReader(frame))
+			require.NoError(t, err)
+			assert.Equal(t, len(frame), size)
+			assert.Equal(t, tc.record, record)
+		})
+	}
+}
+
+func TestDecodeBrokenRecord(t *testing.T) {
+	record := NewRecord(1, 1, []string{"key", "value"})
+	frame := record.Encode()
+
+	corrupted := append([]byte{}, frame...)
+	corrupted[len(corrupted)-1] ^= 0xff
+
+	testCases := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "empty stream", data: nil, err: io.EOF},
+		{name: "truncated header", data: frame[:frameHeaderSize-1], err: errTruncatedRecord},
+		{name: "truncated payload", data: frame[:len(frame)-1], err: errTruncatedRecord},
+		{name: "checksum mismatch", data: corrupted, err: errCorruptedRecord},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, _, err := decodeRecord(bytes.NewReader(tc.data))
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
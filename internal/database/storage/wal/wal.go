@@ -0,0 +1,736 @@
+package wal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/txcontext"
+	"inmem-db-go/internal/metrics"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type FlushStrategy int
+
+const (
+	// FlushEachWrite writes and fsyncs every record before returning to the caller.
+	FlushEachWrite FlushStrategy = iota
+	// FlushBySize buffers records and fsyncs them once the batch size is reached,
+	// records of an incomplete batch are lost on crash.
+	FlushBySize
+	// FlushByTimeout buffers records and fsyncs them periodically,
+	// records written since the last tick are lost on crash.
+	FlushByTimeout
+)
+
+const (
+	defaultFlushBatchSize  = 64 << 10
+	defaultFlushTimeout    = 10 * time.Millisecond
+	defaultMaxSegmentSize  = 10 << 20
+	maxRecordSize          = 64 << 20
+	segmentFilePrefix      = "wal_"
+	segmentFileExtension   = ".log"
+	segmentFilePermissions = 0o644
+)
+
+var (
+	ErrRecordsMissing = errors.New("wal records are missing")
+	errClosed         = errors.New("wal is closed")
+)
+
+// ParseFlushStrategy returns the strategy by its name: sync, size or timeout.
+func ParseFlushStrategy(name string) (FlushStrategy, error) {
+	switch name {
+	case "sync":
+		return FlushEachWrite, nil
+	case "size":
+		return FlushBySize, nil
+	case "timeout":
+		return FlushByTimeout, nil
+	}
+
+	return 0, errors.New("wal flush strategy is invalid")
+}
+
+type WALOption func(*WAL)
+
+func WithFlushStrategy(strategy FlushStrategy) WALOption {
+	return func(wal *WAL) {
+		wal.strategy = strategy
+	}
+}
+
+func WithFlushBatchSize(size int) WALOption {
+	return func(wal *WAL) {
+		wal.batchSize = size
+	}
+}
+
+func WithFlushTimeout(timeout time.Duration) WALOption {
+	return func(wal *WAL) {
+		wal.timeout = timeout
+	}
+}
+
+func WithMaxSegmentSize(size int) WALOption {
+	return func(wal *WAL) {
+		wal.maxSegmentSize = size
+	}
+}
+
+// WithMetrics counts written bytes and measures the fsync latency.
+func WithMetrics(registry *metrics.Registry) WALOption {
+	return func(wal *WAL) {
+		wal.writtenBytes = registry.Counter("inmemdb_wal_written_bytes_total", "Bytes of log records written to the wal.")
+		wal.syncLatency = registry.Histogram(
+			"inmemdb_wal_fsync_duration_seconds",
+			"Latency of flushing and syncing the wal to disk.",
+			metrics.DefaultLatencyBuckets,
+		)
+	}
+}
+
+type WAL struct {
+	directory      string
+	strategy       FlushStrategy
+	batchSize      int
+	timeout        time.Duration
+	maxSegmentSize int
+
+	mutex       sync.Mutex
+	segment     *os.File
+	segmentSize int
+	writer      *bufio.Writer
+	unsynced    int
+	pending     int
+	lastLSN     int64
+	closed      bool
+	// after a failed sync it is unknown which records reached the disk,
+	// so no more records are accepted until the log is reset
+	syncErr error
+
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	writtenBytes *metrics.Counter
+	syncLatency  *metrics.Histogram
+
+	logger *zap.Logger
+}
+
+// NewWAL opens the log stored in directory. Segments are validated on open:
+// a truncated or corrupted tail of the last segment is cut off so that
+// new records are appended right after the last valid one.
+func NewWAL(directory string, logger *zap.Logger, options ...WALOption) (*WAL, error) {
+	if directory == "" {
+		return nil, errors.New("directory is invalid")
+	}
+
+	if logger == nil {
+		return nil, errors.New("logger is invalid")
+	}
+
+	wal := &WAL{
+		directory:      directory,
+		strategy:       FlushEachWrite,
+		batchSize:      defaultFlushBatchSize,
+		timeout:        defaultFlushTimeout,
+		maxSegmentSize: defaultMaxSegmentSize,
+		done:           make(chan struct{}),
+		logger:         logger,
+	}
+
+	for _, option := range options {
+		option(wal)
+	}
+
+	if wal.strategy < FlushEachWrite || wal.strategy > FlushByTimeout {
+		return nil, errors.New("flush strategy is invalid")
+	}
+
+	if wal.batchSize <= 0 {
+		return nil, errors.New("flush batch size is invalid")
+	}
+
+	if wal.timeout <= 0 {
+		return nil, errors.New("flush timeout is invalid")
+	}
+
+	if wal.maxSegmentSize <= 0 {
+		return nil, errors.New("max segment size is invalid")
+	}
+
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create wal directory: %w", err)
+	}
+
+	if err := wal.open(); err != nil {
+		return nil, err
+	}
+
+	if wal.strategy == FlushByTimeout {
+		wal.wg.Add(1)
+		go wal.flushPeriodically()
+	}
+
+	return wal, nil
+}
+
+func (w *WAL) Set(ctx context.Context, key, value string) error {
+	return w.append(ctx, compute.SetCommandID, []string{key, value})
+}
+
+// SetWithExpiration logs the deadline as absolute unix time in milliseconds,
+// so replaying the record later doesn't extend the key lifetime.
+func (w *WAL) SetWithExpiration(ctx context.Context, key, value string, expiresAt time.Time) error {
+	return w.append(ctx, compute.SetCommandID, []string{key, value, formatDeadline(expiresAt)})
+}
+
+func (w *WAL) Del(ctx context.Context, key string) error {
+	return w.append(ctx, compute.DelCommandID, []string{key})
+}
+
+func (w *WAL) Expire(ctx context.Context, key string, expiresAt time.Time) error {
+	return w.append(ctx, compute.ExpireCommandID, []string{key, formatDeadline(expiresAt)})
+}
+
+func (w *WAL) Persist(ctx context.Context, key string) error {
+	return w.append(ctx, compute.PersistCommandID, []string{key})
+}
+
+// HSet logs the hash fields ordered by name: key field value [field value ...].
+func (w *WAL) HSet(ctx context.Context, key string, fields map[string]string) error {
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	arguments := make([]string, 0, 1+2*len(fields))
+	arguments = append(arguments, key)
+	for _, field := range names {
+		arguments = append(arguments, field, fields[field])
+	}
+
+	return w.append(ctx, compute.HSetCommandID, arguments)
+}
+
+func (w *WAL) HDel(ctx context.Context, key string, fields []string) error {
+	return w.append(ctx, compute.HDelCommandID, append([]string{key}, fields...))
+}
+
+// Commit logs all writes of a transaction as a single record,
+// so they are replayed either together or not at all.
+func (w *WAL) Commit(ctx context.Context, values map[string]string, deleted []string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	arguments := make([]string, 0, 3*len(values)+2*len(deleted))
+	for _, key := range keys {
+		arguments = append(arguments, compute.SetCommand, key, values[key])
+	}
+
+	for _, key := range deleted {
+		arguments = append(arguments, compute.DelCommand, key)
+	}
+
+	return w.append(ctx, compute.CommitCommandID, arguments)
+}
+
+// AppendRecord writes a record received from a replication master keeping its lsn,
+// the record must directly follow the last one.
+func (w *WAL) AppendRecord(ctx context.Context, record Record) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return errClosed
+	}
+
+	if record.LSN != w.lastLSN+1 {
+		return fmt.Errorf("%w: expected lsn %d, got %d", ErrRecordsMissing, w.lastLSN+1, record.LSN)
+	}
+
+	return w.write(ctx, record)
+}
+
+// Reset drops all records and continues the log after lsn,
+// it is used when the state is replaced by a snapshot taken at lsn.
+func (w *WAL) Reset(lsn int64) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return errClosed
+	}
+
+	if err := w.segment.Close(); err != nil {
+		return fmt.Errorf("failed to close segment: %w", err)
+	}
+
+	segments, err := w.segments()
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		if err := os.Remove(segment.path); err != nil {
+			return fmt.Errorf("failed to remove segment: %w", err)
+		}
+	}
+
+	w.lastLSN = lsn
+	w.unsynced = 0
+	w.pending = 0
+	w.syncErr = nil
+	w.logger.Debug("wal reset", zap.Int64("lsn", lsn))
+	return w.createSegment(lsn + 1)
+}
+
+// LastLSN returns the sequence number of the last appended record.
+func (w *WAL) LastLSN() int64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.lastLSN
+}
+
+// Replay calls apply for every persisted record with sequence number greater
+// than afterLSN in the order they were written.
+func (w *WAL) Replay(afterLSN int64, apply func(Record) error) error {
+	w.mutex.Lock()
+	if !w.closed {
+		// records buffered by the batched strategies must be visible to the reader
+		if err := w.writer.Flush(); err != nil {
+			w.mutex.Unlock()
+			return err
+		}
+	}
+
+	segments, err := w.segments()
+	w.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// records removed by Truncate or Reset cannot be replayed
+	if len(segments) != 0 && segments[0].firstLSN > afterLSN+1 {
+		return fmt.Errorf("%w: expected lsn %d, first available %d", ErrRecordsMissing, afterLSN+1, segments[0].firstLSN)
+	}
+
+	expectedLSN := afterLSN + 1
+	for i, segment := range segments {
+		if i+1 < len(segments) && segments[i+1].firstLSN <= afterLSN+1 {
+			continue
+		}
+
+		err := w.readSegment(segment.path, func(record Record, _ int) error {
+			if record.LSN <= afterLSN {
+				return nil
+			}
+
+			if record.LSN != expectedLSN {
+				return fmt.Errorf("%w: expected lsn %d, found %d", ErrRecordsMissing, expectedLSN, record.LSN)
+			}
+
+			expectedLSN++
+			return apply(record)
+		})
+		// the active segment may end with a record that is being written right now
+		if err != nil && !(i == len(segments)-1 && isBrokenTail(err)) {
+			return fmt.Errorf("failed to replay segment %s: %w", segment.path, err)
+		}
+	}
+
+	return nil
+}
+
+// Truncate removes segments containing only records with sequence
+// numbers up to lsn, the active segment is never removed.
+func (w *WAL) Truncate(lsn int64) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	segments, err := w.segments()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i+1 < len(segments) && segments[i+1].firstLSN <= lsn+1; i++ {
+		if err := os.Remove(segments[i].path); err != nil {
+			return fmt.Errorf("failed to remove segment: %w", err)
+		}
+
+		w.logger.Debug("wal segment removed", zap.String("path", segments[i].path))
+	}
+
+	return nil
+}
+
+// Sync writes the buffered records and fsyncs them, so every record
+// up to LastLSN survives a crash whatever the flush strategy is.
+func (w *WAL) Sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return errClosed
+	}
+
+	if w.unsynced == 0 {
+		return nil
+	}
+
+	return w.sync()
+}
+
+// WALStats describes records buffered before an fsync, they are lost on crash.
+type WALStats struct {
+	LastLSN        int64
+	PendingRecords int
+	PendingBytes   int
+}
+
+func (w *WAL) Stats() WALStats {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return WALStats{
+		LastLSN:        w.lastLSN,
+		PendingRecords: w.pending,
+		PendingBytes:   w.unsynced,
+	}
+}
+
+func (w *WAL) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+
+	w.closed = true
+	close(w.done)
+	w.mutex.Unlock()
+
+	w.wg.Wait()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.sync(); err != nil {
+		_ = w.segment.Close()
+		return err
+	}
+
+	return w.segment.Close()
+}
+
+func (w *WAL) append(ctx context.Context, commandID int, arguments []string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return errClosed
+	}
+
+	return w.write(ctx, NewRecord(w.lastLSN+1, commandID, arguments))
+}
+
+func (w *WAL) write(ctx context.Context, record Record) error {
+	if w.syncErr != nil {
+		return w.syncErr
+	}
+
+	frame := record.Encode()
+	if len(frame) > maxRecordSize {
+		return errors.New("log record is too large")
+	}
+
+	if w.segmentSize != 0 && w.segmentSize+len(frame) > w.maxSegmentSize {
+		if err := w.rotate(record.LSN); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.writer.Write(frame); err != nil {
+		return fmt.Errorf("failed to write log record: %w", err)
+	}
+
+	w.writtenBytes.Add(float64(len(frame)))
+	w.segmentSize += len(frame)
+	w.unsynced += len(frame)
+	w.pending++
+
+	switch w.strategy {
+	case FlushEachWrite:
+		if err := w.sync(); err != nil {
+			return err
+		}
+	case FlushBySize:
+		if w.unsynced >= w.batchSize {
+			if err := w.sync(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the position moves only once the record is accepted
+	w.lastLSN = record.LSN
+
+	txID := txcontext.TxID(ctx)
+	w.logger.Debug(
+		"log record appended",
+		zap.Int64("tx", txID),
+		zap.Int64("lsn", record.LSN),
+	)
+
+	return nil
+}
+
+func (w *WAL) flushPeriodically() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.timeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mutex.Lock()
+			if w.unsynced != 0 {
+				if err := w.sync(); err != nil {
+					w.logger.Error("failed to flush wal", zap.Error(err))
+				}
+			}
+			w.mutex.Unlock()
+		}
+	}
+}
+
+func (w *WAL) sync() error {
+	if w.syncErr != nil {
+		return w.syncErr
+	}
+
+	startedAt := time.Now()
+	defer func() { w.syncLatency.ObserveDuration(time.Since(startedAt)) }()
+
+	if err := w.writer.Flush(); err != nil {
+		w.syncErr = fmt.Errorf("failed to flush wal: %w", err)
+		return w.syncErr
+	}
+
+	if err := w.segment.Sync(); err != nil {
+		w.syncErr = fmt.Errorf("failed to sync wal: %w", err)
+		return w.syncErr
+	}
+
+	w.unsynced = 0
+	w.pending = 0
+	return nil
+}
+
+func (w *WAL) rotate(firstLSN int64) error {
+	if err := w.sync(); err != nil {
+		return err
+	}
+
+	if err := w.segment.Close(); err != nil {
+		return fmt.Errorf("failed to close segment: %w", err)
+	}
+
+	return w.createSegment(firstLSN)
+}
+
+func (w *WAL) createSegment(firstLSN int64) error {
+	path := filepath.Join(w.directory, segmentFileName(firstLSN))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, segmentFilePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+
+	w.segment = file
+	w.segmentSize = 0
+	w.writer = bufio.NewWriter(file)
+	w.logger.Debug("wal segment created", zap.String("path", path))
+	return nil
+}
+
+// open validates existing segments and prepares the last one for appending.
+func (w *WAL) open() error {
+	segments, err := w.segments()
+	if err != nil {
+		return err
+	}
+
+	// segments are named after their first lsn, so the position
+	// is known even if the remaining segments are empty
+	if len(segments) != 0 {
+		w.lastLSN = segments[0].firstLSN - 1
+	}
+
+	validSize := 0
+	for i, segment := range segments {
+		validSize = 0
+		err := w.readSegment(segment.path, func(record Record, size int) error {
+			if record.LSN != w.lastLSN+1 {
+				return fmt.Errorf("%w: unexpected lsn %d after %d", errCorruptedRecord, record.LSN, w.lastLSN)
+			}
+
+			w.lastLSN = record.LSN
+			validSize += size
+			return nil
+		})
+		if err == nil {
+			continue
+		}
+
+		if i != len(segments)-1 || !isBrokenTail(err) {
+			return fmt.Errorf("failed to read segment %s: %w", segment.path, err)
+		}
+
+		w.logger.Warn(
+			"broken wal tail is truncated",
+			zap.String("path", segment.path),
+			zap.Int("valid_size", validSize),
+			zap.Error(err),
+		)
+
+		if err := os.Truncate(segment.path, int64(validSize)); err != nil {
+			return fmt.Errorf("failed to truncate segment: %w", err)
+		}
+	}
+
+	if len(segments) == 0 {
+		return w.createSegment(w.lastLSN + 1)
+	}
+
+	path := segments[len(segments)-1].path
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, segmentFilePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+
+	w.segment = file
+	w.segmentSize = validSize
+	w.writer = bufio.NewWriter(file)
+	return nil
+}
+
+type segmentFile struct {
+	path     string
+	firstLSN int64
+}
+
+func (w *WAL) segments() ([]segmentFile, error) {
+	entries, err := os.ReadDir(w.directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wal directory: %w", err)
+	}
+
+	var segments []segmentFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, segmentFilePrefix) || !strings.HasSuffix(name, segmentFileExtension) {
+			continue
+		}
+
+		lsn, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, segmentFilePrefix), segmentFileExtension), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		segments = append(segments, segmentFile{
+			path:     filepath.Join(w.directory, name),
+			firstLSN: lsn,
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].firstLSN < segments[j].firstLSN
+	})
+
+	return segments, nil
+}
+
+func (w *WAL) readSegment(path string, apply func(Record, int) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		record, size, err := decodeRecord(reader)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := apply(record, size); err != nil {
+			return err
+		}
+	}
+}
+
+func formatDeadline(deadline time.Time) string {
+	return strconv.FormatInt(deadline.UnixMilli(), 10)
+}
+
+// ParseDeadline decodes a deadline stored in a log record argument.
+func ParseDeadline(argument string) (time.Time, error) {
+	milliseconds, err := strconv.ParseInt(argument, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid deadline %q", errCorruptedRecord, argument)
+	}
+
+	return time.UnixMilli(milliseconds), nil
+}
+
+// DecodeCommit splits arguments of a commit record into set values and deleted keys.
+func DecodeCommit(arguments []string) (map[string]string, []string, error) {
+	values := make(map[string]string)
+	var deleted []string
+	for i := 0; i < len(arguments); {
+		switch {
+		case arguments[i] == compute.SetCommand && i+2 < len(arguments):
+			values[arguments[i+1]] = arguments[i+2]
+			i += 3
+		case arguments[i] == compute.DelCommand && i+1 < len(arguments):
+			deleted = append(deleted, arguments[i+1])
+			i += 2
+		default:
+			return nil, nil, fmt.Errorf("%w: invalid commit operation %q", errCorruptedRecord, arguments[i])
+		}
+	}
+
+	return values, deleted, nil
+}
+
+func segmentFileName(firstLSN int64) string {
+	return fmt.Sprintf("%s%020d%s", segmentFilePrefix, firstLSN, segmentFileExtension)
+}
+
+func isBrokenTail(err error) bool {
+	return errors.Is(err, errTruncatedRecord) || errors.Is(err, errCorruptedRecord)
+}
@@ -0,0 +1,416 @@
+package wal
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/txcontext"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func replayAll(t *testing.T, wal *WAL, afterLSN int64) []Record {
+	t.Helper()
+
+	var records []Record
+	err := wal.Replay(afterLSN, func(record Record) error {
+		records = append(records, record)
+		return nil
+	})
+	require.NoError(t, err)
+	return records
+}
+
+func TestNewWAL(t *testing.T) {
+	t.Parallel()
+
+	directory := t.TempDir()
+
+	wal, err := NewWAL("", zap.NewNop())
+	require.Error(t, err, "directory is invalid")
+	require.Nil(t, wal)
+
+	wal, err = NewWAL(directory, nil)
+	require.Error(t, err, "logger is invalid")
+	require.Nil(t, wal)
+
+	wal, err = NewWAL(directory, zap.NewNop(), WithFlushStrategy(FlushStrategy(10)))
+	require.Error(t, err, "flush strategy is invalid")
+	require.Nil(t, wal)
+
+	wal, err = NewWAL(directory, zap.NewNop(), WithMaxSegmentSize(0))
+	require.Error(t, err, "max segment size is invalid")
+	require.Nil(t, wal)
+
+	wal, err = NewWAL(directory, zap.NewNop())
+	require.NoError(t, err)
+	require.NotNil(t, wal)
+	require.NoError(t, wal.Close())
+}
+
+func TestWriteAndReplay(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options []WALOption
+	}{
+		{name: "flush each write", options: []WALOption{WithFlushStrategy(FlushEachWrite)}},
+		{name: "flush by size", options: []WALOption{WithFlushStrategy(FlushBySize), WithFlushBatchSize(64)}},
+		{name: "flush by timeout", options: []WALOption{WithFlushStrategy(FlushByTimeout), WithFlushTimeout(time.Millisecond)}},
+		{name: "small segments", options: []WALOption{WithMaxSegmentSize(32)}},
+	}
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			directory := t.TempDir()
+			wal, err := NewWAL(directory, zap.NewNop(), tc.options...)
+			require.NoError(t, err)
+
+			require.NoError(t, wal.Set(ctx, "key_1", "value_1"))
+			require.NoError(t, wal.Set(ctx, "key_2", "value_2"))
+			require.NoError(t, wal.Del(ctx, "key_1"))
+			require.NoError(t, wal.Close())
+
+			wal, err = NewWAL(directory, zap.NewNop(), tc.options...)
+			require.NoError(t, err)
+			defer wal.Close()
+
+			expected := []Record{
+				NewRecord(1, compute.SetCommandID, []string{"key_1", "value_1"}),
+				NewRecord(2, compute.SetCommandID, []string{"key_2", "value_2"}),
+				NewRecord(3, compute.DelCommandID, []string{"key_1"}),
+			}
+			assert.Equal(t, expected, replayAll(t, wal, 0))
+			assert.Equal(t, expected[2:], replayAll(t, wal, 2))
+			assert.Equal(t, int64(3), wal.LastLSN())
+
+			require.NoError(t, wal.Set(ctx, "key_3", "value_3"))
+			records := replayAll(t, wal, 3)
+			assert.Equal(t, []Record{NewRecord(4, compute.SetCommandID, []string{"key_3", "value_3"})}, records)
+		})
+	}
+}
+
+func TestSegmentRotation(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	directory := t.TempDir()
+
+	// every record takes 21 bytes, so two of them fit into a segment
+	wal, err := NewWAL(directory, zap.NewNop(), WithMaxSegmentSize(45))
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, wal.Set(ctx, "key", "value"))
+	}
+	require.NoError(t, wal.Close())
+
+	segments, err := filepath.Glob(filepath.Join(directory, "wal_*.log"))
+	require.NoError(t, err)
+	assert.Len(t, segments, 3)
+	assert.Equal(t, filepath.Join(directory, segmentFileName(1)), segments[0])
+}
+
+func TestRecoverBrokenTail(t *testing.T) {
+	testCases := []struct {
+		name      string
+		damage    func(t *testing.T, path string)
+		recovered int
+	}{
+		{
+			name: "truncated record",
+			damage: func(t *testing.T, path string) {
+				info, err := os.Stat(path)
+				require.NoError(t, err)
+				require.NoError(t, os.Truncate(path, info.Size()-3))
+			},
+			recovered: 1,
+		},
+		{
+			name: "corrupted record",
+			damage: func(t *testing.T, path string) {
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				data[len(data)-2] ^= 0xff
+				require.NoError(t, os.WriteFile(path, data, 0o644))
+			},
+			recovered: 1,
+		},
+		{
+			name: "garbage after last record",
+			damage: func(t *testing.T, path string) {
+				file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+				require.NoError(t, err)
+				_, err = file.Write([]byte{1, 2, 3})
+				require.NoError(t, err)
+				require.NoError(t, file.Close())
+			},
+			recovered: 2,
+		},
+	}
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			directory := t.TempDir()
+			wal, err := NewWAL(directory, zap.NewNop())
+			require.NoError(t, err)
+
+			require.NoError(t, wal.Set(ctx, "key_1", "value_1"))
+			require.NoError(t, wal.Set(ctx, "key_2", "value_2"))
+			require.NoError(t, wal.Close())
+
+			tc.damage(t, filepath.Join(directory, segmentFileName(1)))
+
+			wal, err = NewWAL(directory, zap.NewNop())
+			require.NoError(t, err)
+			defer wal.Close()
+
+			expected := []Record{
+				NewRecord(1, compute.SetCommandID, []string{"key_1", "value_1"}),
+				NewRecord(2, compute.SetCommandID, []string{"key_2", "value_2"}),
+			}
+			require.Equal(t, expected[:tc.recovered], replayAll(t, wal, 0))
+
+			require.NoError(t, wal.Set(ctx, "key_3", "value_3"))
+			expected = append(expected[:tc.recovered], NewRecord(int64(tc.recovered+1), compute.SetCommandID, []string{"key_3", "value_3"}))
+			assert.Equal(t, expected, replayAll(t, wal, 0))
+		})
+	}
+}
+
+func TestCorruptedMiddleSegment(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	directory := t.TempDir()
+
+	wal, err := NewWAL(directory, zap.NewNop(), WithMaxSegmentSize(40))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, wal.Set(ctx, "key", "value"))
+	}
+	require.NoError(t, wal.Close())
+
+	path := filepath.Join(directory, segmentFileName(1))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	wal, err = NewWAL(directory, zap.NewNop(), WithMaxSegmentSize(40))
+	require.ErrorIs(t, err, errCorruptedRecord)
+	require.Nil(t, wal)
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	wal, err := NewWAL(t.TempDir(), zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, wal.Close())
+
+	require.ErrorIs(t, wal.Set(ctx, "key", "value"), errClosed)
+}
+
+func TestSync(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	directory := t.TempDir()
+	wal, err := NewWAL(directory, zap.NewNop(), WithFlushStrategy(FlushBySize))
+	require.NoError(t, err)
+
+	require.NoError(t, wal.Set(ctx, "key", "value"))
+	assert.Equal(t, 1, wal.Stats().PendingRecords)
+
+	require.NoError(t, wal.Sync())
+	assert.Equal(t, 0, wal.Stats().PendingRecords)
+
+	// the record is on disk without closing the log
+	reopened, err := NewWAL(directory, zap.NewNop())
+	require.NoError(t, err)
+	defer reopened.Close()
+	assert.Equal(t, int64(1), reopened.LastLSN())
+
+	require.NoError(t, wal.Close())
+	require.ErrorIs(t, wal.Sync(), errClosed)
+}
+
+func TestWriteAfterFailedSync(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	wal, err := NewWAL(t.TempDir(), zap.NewNop())
+	require.NoError(t, err)
+
+	require.NoError(t, wal.Set(ctx, "key_1", "value_1"))
+
+	// the segment is closed behind the log, so the next sync fails
+	require.NoError(t, wal.segment.Close())
+	require.Error(t, wal.Set(ctx, "key_2", "value_2"))
+	assert.Equal(t, int64(1), wal.LastLSN())
+
+	// the failed record may be on disk, so its position is not reused
+	require.Error(t, wal.Set(ctx, "key_3", "value_3"))
+	require.Error(t, wal.Sync())
+	assert.Equal(t, int64(1), wal.LastLSN())
+	require.Error(t, wal.Close())
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	directory := t.TempDir()
+
+	// one record per segment
+	wal, err := NewWAL(directory, zap.NewNop(), WithMaxSegmentSize(30))
+	require.NoError(t, err)
+	defer wal.Close()
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, wal.Set(ctx, "key", "value"))
+	}
+
+	require.NoError(t, wal.Truncate(2))
+
+	segments, err := filepath.Glob(filepath.Join(directory, "wal_*.log"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(directory, segmentFileName(3)),
+		filepath.Join(directory, segmentFileName(4)),
+	}, segments)
+
+	records := replayAll(t, wal, 2)
+	require.Len(t, records, 2)
+	assert.Equal(t, int64(3), records[0].LSN)
+
+	err = wal.Replay(0, func(Record) error { return nil })
+	require.ErrorIs(t, err, ErrRecordsMissing)
+
+	// the active segment stays even if it is fully covered
+	require.NoError(t, wal.Truncate(4))
+	segments, err = filepath.Glob(filepath.Join(directory, "wal_*.log"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(directory, segmentFileName(4))}, segments)
+}
+
+func TestExpirationRecords(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	expiresAt := time.UnixMilli(1700000000123)
+
+	wal, err := NewWAL(t.TempDir(), zap.NewNop())
+	require.NoError(t, err)
+	defer wal.Close()
+
+	require.NoError(t, wal.SetWithExpiration(ctx, "key", "value", expiresAt))
+	require.NoError(t, wal.Expire(ctx, "key", expiresAt))
+	require.NoError(t, wal.Persist(ctx, "key"))
+
+	records := replayAll(t, wal, 0)
+	require.Equal(t, []Record{
+		NewRecord(1, compute.SetCommandID, []string{"key", "value", "1700000000123"}),
+		NewRecord(2, compute.ExpireCommandID, []string{"key", "1700000000123"}),
+		NewRecord(3, compute.PersistCommandID, []string{"key"}),
+	}, records)
+
+	deadline, err := ParseDeadline(records[1].Arguments[1])
+	require.NoError(t, err)
+	require.True(t, expiresAt.Equal(deadline))
+
+	_, err = ParseDeadline("soon")
+	require.ErrorIs(t, err, errCorruptedRecord)
+}
+
+func TestCommitRecords(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	wal, err := NewWAL(t.TempDir(), zap.NewNop())
+	require.NoError(t, err)
+	defer wal.Close()
+
+	require.NoError(t, wal.Commit(ctx, map[string]string{"key_2": "value_2", "key_1": ""}, []string{"key_3"}))
+
+	records := replayAll(t, wal, 0)
+	require.Equal(t, []Record{
+		NewRecord(1, compute.CommitCommandID, []string{"SET", "key_1", "", "SET", "key_2", "value_2", "DEL", "key_3"}),
+	}, records)
+
+	values, deleted, err := DecodeCommit(records[0].Arguments)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"key_1": "", "key_2": "value_2"}, values)
+	require.Equal(t, []string{"key_3"}, deleted)
+
+	_, _, err = DecodeCommit([]string{"SET", "key"})
+	require.ErrorIs(t, err, errCorruptedRecord)
+	_, _, err = DecodeCommit([]string{"GET", "key"})
+	require.ErrorIs(t, err, errCorruptedRecord)
+}
+
+func TestHashRecords(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	wal, err := NewWAL(t.TempDir(), zap.NewNop())
+	require.NoError(t, err)
+	defer wal.Close()
+
+	require.NoError(t, wal.HSet(ctx, "key", map[string]string{"field_2": "value_2", "field_1": ""}))
+	require.NoError(t, wal.HDel(ctx, "key", []string{"field_1", "field_3"}))
+
+	records := replayAll(t, wal, 0)
+	require.Equal(t, []Record{
+		NewRecord(1, compute.HSetCommandID, []string{"key", "field_1", "", "field_2", "value_2"}),
+		NewRecord(2, compute.HDelCommandID, []string{"key", "field_1", "field_3"}),
+	}, records)
+}
+
+func TestAppendRecordAndReset(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	directory := t.TempDir()
+
+	wal, err := NewWAL(directory, zap.NewNop())
+	require.NoError(t, err)
+
+	require.NoError(t, wal.AppendRecord(ctx, NewRecord(1, compute.SetCommandID, []string{"key", "value"})))
+	err = wal.AppendRecord(ctx, NewRecord(3, compute.DelCommandID, []string{"key"}))
+	require.ErrorIs(t, err, ErrRecordsMissing)
+
+	require.NoError(t, wal.Reset(10))
+	assert.Equal(t, int64(10), wal.LastLSN())
+	err = wal.Replay(0, func(Record) error { return nil })
+	require.ErrorIs(t, err, ErrRecordsMissing)
+	assert.Empty(t, replayAll(t, wal, 10))
+	require.NoError(t, wal.Close())
+
+	// the position survives a restart even though the log is empty
+	wal, err = NewWAL(directory, zap.NewNop())
+	require.NoError(t, err)
+	defer wal.Close()
+
+	assert.Equal(t, int64(10), wal.LastLSN())
+	require.NoError(t, wal.Set(ctx, "key", "value"))
+	records := replayAll(t, wal, 10)
+	require.Len(t, records, 1)
+	assert.Equal(t, int64(11), records[0].LSN)
+}
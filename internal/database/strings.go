@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxValueLength limits values grown by APPEND and SETRANGE like in Redis
+const maxValueLength = 512 << 20
+
+var errValueTooLong = result.NewError(result.CodeInvalidArgument, "string exceeds maximum allowed size")
+
+// executeAppendQuery appends to the value atomically, a missing key is created,
+// and replies with the new length. The key keeps its expiration.
+func (d *Database) executeAppendQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	suffix := arguments[1]
+
+	var length int
+	_, err := d.storageLayer.Update(ctx, arguments[0], func(value string, _ bool, expiresAt time.Time) (string, time.Time, error) {
+		if len(value)+len(suffix) > maxValueLength {
+			return "", time.Time{}, errValueTooLong
+		}
+
+		value += suffix
+		length = len(value)
+		return value, expiresAt, nil
+	})
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.Integer(int64(length))
+}
+
+// executeStrLenQuery replies with the length of the value in bytes, 0 for missing keys.
+func (d *Database) executeStrLenQuery(ctx context.Context, query compute.Query) result.Result {
+	value, err := d.storageLayer.Get(ctx, query.Arguments()[0])
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return result.FromError(err)
+	}
+
+	return result.Integer(int64(len(value)))
+}
+
+// executeGetRangeQuery replies with the bytes from start to end inclusive,
+// negative indices count from the end and the range is clamped to the value.
+func (d *Database) executeGetRangeQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	value, err := d.storageLayer.Get(ctx, arguments[0])
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return result.FromError(err)
+	}
+
+	start, _ := strconv.ParseInt(arguments[1], 10, 64)
+	end, _ := strconv.ParseInt(arguments[2], 10, 64)
+	return result.String(valueRange(value, start, end))
+}
+
+// executeSetRangeQuery overwrites the value starting at the offset atomically and
+// replies with the new length, a shorter value is padded with zero bytes first.
+// The key keeps its expiration, an empty value leaves the key as is.
+func (d *Database) executeSetRangeQuery(ctx context.Context, query compute.Query) result.Result {
+	arguments := query.Arguments()
+	offset, _ := strconv.ParseInt(arguments[1], 10, 64)
+	patch := arguments[2]
+	if offset > maxValueLength-int64(len(patch)) {
+		return result.FromError(errValueTooLong)
+	}
+
+	var length int
+	_, err := d.storageLayer.Update(ctx, arguments[0], func(value string, _ bool, expiresAt time.Time) (string, time.Time, error) {
+		if patch == "" {
+			length = len(value)
+			return "", time.Time{}, storage.ErrSkipUpdate
+		}
+
+		value = overwrite(value, int(offset), patch)
+		length = len(value)
+		return value, expiresAt, nil
+	})
+	if err != nil {
+		return result.FromError(err)
+	}
+
+	return result.Integer(int64(length))
+}
+
+func valueRange(value string, start, end int64) string {
+	length := int64(len(value))
+	if start < 0 {
+		start += length
+	}
+
+	if end < 0 {
+		end += length
+	}
+
+	start = max(start, 0)
+	end = min(end, length-1)
+	if start > end {
+		return ""
+	}
+
+	return value[start : end+1]
+}
+
+func overwrite(value string, offset int, patch string) string {
+	var builder strings.Builder
+	builder.Grow(max(len(value), offset+len(patch)))
+	builder.WriteString(value[:min(offset, len(value))])
+	for i := len(value); i < offset; i++ {
+		builder.WriteByte(0)
+	}
+
+	builder.WriteString(patch)
+	if offset+len(patch) < len(value) {
+		builder.WriteString(value[offset+len(patch):])
+	}
+
+	return builder.String()
+}
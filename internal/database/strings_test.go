@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStringQueries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	database := newTestDatabase(t)
+
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "APPEND greeting Hello", expected: "[ok] 5"},
+		{query: `APPEND greeting " World"`, expected: "[ok] 11"},
+		{query: "GET greeting", expected: `[ok] "Hello World"`},
+		{query: "STRLEN greeting", expected: "[ok] 11"},
+		{query: "STRLEN missing", expected: "[ok] 0"},
+		{query: "GETRANGE greeting 0 4", expected: "[ok] Hello"},
+		{query: "GETRANGE greeting -5 -1", expected: "[ok] World"},
+		{query: "GETRANGE greeting 0 -1", expected: `[ok] "Hello World"`},
+		{query: "GETRANGE greeting -100 2", expected: "[ok] Hel"},
+		{query: "GETRANGE greeting 6 100", expected: "[ok] World"},
+		{query: "GETRANGE greeting 5 3", expected: `[ok] ""`},
+		{query: "GETRANGE greeting 11 20", expected: `[ok] ""`},
+		{query: "GETRANGE greeting -1 -5", expected: `[ok] ""`},
+		{query: "GETRANGE missing 0 -1", expected: `[ok] ""`},
+		{query: "SETRANGE greeting 6 Redis", expected: "[ok] 11"},
+		{query: "GET greeting", expected: `[ok] "Hello Redis"`},
+		{query: "SETRANGE greeting 0 J", expected: "[ok] 11"},
+		{query: `SETRANGE greeting 11 "!"`, expected: "[ok] 12"},
+		{query: "GET greeting", expected: `[ok] "Jello Redis!"`},
+		{query: "SETRANGE padded 3 abc", expected: "[ok] 6"},
+		{query: "GET padded", expected: `[ok] "\x00\x00\x00abc"`},
+		{query: `SETRANGE empty 5 ""`, expected: "[ok] 0"},
+		{query: "EXISTS empty", expected: "[ok] 0"},
+		{query: `SETRANGE greeting 1 ""`, expected: "[ok] 12"},
+		{query: "SETRANGE greeting 536870911 ab", expected: "[error] string exceeds maximum allowed size"},
+		{query: "SETRANGE greeting -1 a", expected: "[error] invalid argument value"},
+		{query: "GETRANGE greeting a 1", expected: "[error] invalid argument value"},
+		{query: "SET volatile a EX 100", expected: "[ok]"},
+		{query: "APPEND volatile b", expected: "[ok] 2"},
+		{query: "SETRANGE volatile 0 c", expected: "[ok] 2"},
+		{query: "TTL volatile", expected: "[ok] 100"},
+	}
+
+	// queries depend on each other, so they run one by one
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, database.HandleQuery(ctx, tc.query), tc.query)
+	}
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/storage"
+)
+
+// transaction buffers writes until commit and remembers
+// the keys it has read, so the commit can detect conflicts.
+type transaction struct {
+	id     int64
+	reads  map[string]storage.KeyState
+	writes map[string]storage.KeyState
+	// errors of reading keys of other types, returned again on every read
+	readErrors map[string]error
+}
+
+func newTransaction(id int64) *transaction {
+	return &transaction{
+		id:         id,
+		reads:      make(map[string]storage.KeyState),
+		writes:     make(map[string]storage.KeyState),
+		readErrors: make(map[string]error),
+	}
+}
+
+// get returns own writes first, keys read once are not read
+// from the storage again, so reads are repeatable.
+func (t *transaction) get(ctx context.Context, storageLayer storageLayer, key string) (string, error) {
+	state, err := t.lookup(ctx, storageLayer, key)
+	if err != nil {
+		return "", err
+	}
+
+	if state.OtherType {
+		return "", t.readErrors[key]
+	} else if !state.Found {
+		return "", storage.ErrNotFound
+	}
+
+	return state.Value, nil
+}
+
+// exists reports whether the key exists for the transaction, keys
+// of other types than a string exist too, like for plain queries.
+func (t *transaction) exists(ctx context.Context, storageLayer storageLayer, key string) (bool, error) {
+	state, err := t.lookup(ctx, storageLayer, key)
+	if err != nil {
+		return false, err
+	}
+
+	return state.Found, nil
+}
+
+func (t *transaction) lookup(ctx context.Context, storageLayer storageLayer, key string) (storage.KeyState, error) {
+	if state, found := t.writes[key]; found {
+		return state, nil
+	}
+
+	if state, found := t.reads[key]; found {
+		return state, nil
+	}
+
+	value, err := storageLayer.Get(ctx, key)
+	if result.CodeOf(err) == result.CodeWrongType {
+		// the commit checks that the key still holds another type
+		t.readErrors[key] = err
+		state := storage.KeyState{Key: key, Found: true, OtherType: true}
+		t.reads[key] = state
+		return state, nil
+	} else if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return storage.KeyState{}, err
+	}
+
+	state := storage.KeyState{Key: key, Value: value, Found: err == nil}
+	t.reads[key] = state
+	return state, nil
+}
+
+func (t *transaction) set(key, value string) {
+	t.writes[key] = storage.KeyState{Key: key, Value: value, Found: true}
+}
+
+func (t *transaction) del(key string) {
+	t.writes[key] = storage.KeyState{Key: key}
+}
+
+func (t *transaction) readSet() []storage.KeyState {
+	reads := make([]storage.KeyState, 0, len(t.reads))
+	for _, state := range t.reads {
+		reads = append(reads, state)
+	}
+
+	return reads
+}
+
+func (t *transaction) writeSet() []storage.KeyState {
+	writes := make([]storage.KeyState, 0, len(t.writes))
+	for _, state := range t.writes {
+		writes = append(writes, state)
+	}
+
+	return writes
+}
@@ -0,0 +1,31 @@
+package txcontext
+
+import "context"
+
+// key is unexported, so the transaction id
+// can be set and read only through this package
+type key struct{}
+
+// WithTxID returns a copy of ctx carrying the transaction id.
+func WithTxID(ctx context.Context, txID int64) context.Context {
+	return context.WithValue(ctx, key{}, txID)
+}
+
+// TxID returns the transaction id carried by ctx or 0 if there is none.
+func TxID(ctx context.Context) int64 {
+	txID, _ := ctx.Value(key{}).(int64)
+	return txID
+}
+
+type clientAddressKey struct{}
+
+// WithClientAddress returns a copy of ctx carrying the address of the client sending the query.
+func WithClientAddress(ctx context.Context, address string) context.Context {
+	return context.WithValue(ctx, clientAddressKey{}, address)
+}
+
+// ClientAddress returns the client address carried by ctx or "" if there is none.
+func ClientAddress(ctx context.Context) string {
+	address, _ := ctx.Value(clientAddressKey{}).(string)
+	return address
+}
@@ -0,0 +1,30 @@
+package txcontext
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTxID(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	require.Equal(t, int64(0), TxID(ctx))
+
+	ctx = WithTxID(ctx, 555)
+	require.Equal(t, int64(555), TxID(ctx))
+	require.Equal(t, int64(555), TxID(context.WithValue(ctx, "tx", "other")))
+	require.Equal(t, int64(777), TxID(WithTxID(ctx, 777)))
+}
+
+func TestClientAddress(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	require.Equal(t, "", ClientAddress(ctx))
+
+	ctx = WithClientAddress(WithTxID(ctx, 555), "127.0.0.1:5555")
+	require.Equal(t, "127.0.0.1:5555", ClientAddress(ctx))
+	require.Equal(t, int64(555), TxID(ctx))
+}
@@ -0,0 +1,116 @@
+package initialization
+
+import (
+	"inmem-db-go/internal/database"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// develVersion is reported by builds outside of a tagged module version.
+const develVersion = "devel"
+
+// infoSections returns the INFO sections describing the components
+// built by the initializer.
+func (i *Initializer) infoSections() []database.DatabaseOption {
+	return []database.DatabaseOption{
+		database.WithInfoSection("server", i.serverInfo),
+		database.WithInfoSection("clients", i.clientsInfo),
+		database.WithInfoSection("memory", i.memoryInfo),
+		database.WithInfoSection("persistence", i.persistenceInfo),
+	}
+}
+
+func (i *Initializer) serverInfo() []database.InfoField {
+	return []database.InfoField{
+		{Name: "version", Value: version()},
+		{Name: "go_version", Value: runtime.Version()},
+		{Name: "process_id", Value: strconv.Itoa(os.Getpid())},
+		{Name: "uptime_in_seconds", Value: strconv.FormatInt(int64(time.Since(i.startedAt).Seconds()), 10)},
+		{Name: "config_file", Value: i.cfg.Path},
+		{Name: "tcp_address", Value: i.cfg.Network.Address},
+		{Name: "resp_address", Value: i.cfg.Network.RESPAddress},
+	}
+}
+
+func (i *Initializer) clientsInfo() []database.InfoField {
+	i.serversMutex.Lock()
+	defer i.serversMutex.Unlock()
+
+	var connected int
+	for _, server := range i.servers {
+		connected += server.ConnectedClients()
+	}
+
+	return []database.InfoField{
+		{Name: "connected_clients", Value: strconv.Itoa(connected)},
+		{Name: "max_clients", Value: strconv.Itoa(i.cfg.Network.MaxConnections)},
+	}
+}
+
+func (i *Initializer) memoryInfo() []database.InfoField {
+	stats := i.engine.Stats()
+	fields := []database.InfoField{
+		{Name: "used_memory", Value: strconv.FormatInt(stats.UsedMemory, 10)},
+		{Name: "max_memory", Value: strconv.FormatInt(stats.MaxMemory, 10)},
+		{Name: "eviction_policy", Value: i.cfg.Engine.EvictionPolicy},
+		{Name: "evicted_keys", Value: strconv.FormatInt(stats.EvictedKeys, 10)},
+		{Name: "keys", Value: strconv.Itoa(stats.Keys)},
+	}
+
+	for shard, keys := range stats.KeysPerShard {
+		fields = append(fields, database.InfoField{
+			Name:  "shard_" + strconv.Itoa(shard),
+			Value: "keys=" + strconv.Itoa(keys),
+		})
+	}
+
+	return fields
+}
+
+func (i *Initializer) persistenceInfo() []database.InfoField {
+	stats := i.storage.Stats()
+
+	var lastSnapshotTime int64
+	if !stats.LastSnapshotAt.IsZero() {
+		lastSnapshotTime = stats.LastSnapshotAt.Unix()
+	}
+
+	fields := []database.InfoField{
+		{Name: "last_lsn", Value: strconv.FormatInt(stats.LastLSN, 10)},
+		{Name: "snapshots_enabled", Value: boolInfo(i.cfg.Snapshots.Directory != "")},
+		{Name: "last_snapshot_time", Value: strconv.FormatInt(lastSnapshotTime, 10)},
+		{Name: "last_snapshot_lsn", Value: strconv.FormatInt(stats.LastSnapshotLSN, 10)},
+		{Name: "wal_enabled", Value: boolInfo(i.wal != nil)},
+	}
+
+	if i.wal != nil {
+		walStats := i.wal.Stats()
+		fields = append(fields,
+			database.InfoField{Name: "wal_flush_strategy", Value: i.cfg.WAL.FlushStrategy},
+			database.InfoField{Name: "wal_pending_records", Value: strconv.Itoa(walStats.PendingRecords)},
+			database.InfoField{Name: "wal_pending_bytes", Value: strconv.Itoa(walStats.PendingBytes)},
+		)
+	}
+
+	return fields
+}
+
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" || info.Main.Version == "(devel)" {
+		return develVersion
+	}
+
+	return info.Main.Version
+}
+
+func boolInfo(value bool) string {
+	if value {
+		return "1"
+	}
+
+	return "0"
+}
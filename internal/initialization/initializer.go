@@ -0,0 +1,395 @@
+// Package initialization builds the database components described by the config.
+package initialization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/configuration"
+	"inmem-db-go/internal/database"
+	"inmem-db-go/internal/database/compute"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/storage"
+	"inmem-db-go/internal/database/storage/engine/in_memory"
+	"inmem-db-go/internal/database/storage/snapshot"
+	"inmem-db-go/internal/database/storage/wal"
+	"inmem-db-go/internal/metrics"
+	"inmem-db-go/internal/network"
+	"inmem-db-go/internal/network/resp"
+	"inmem-db-go/internal/replication"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	metricsPath    = "/metrics"
+	metricsTimeout = 5 * time.Second
+)
+
+type Initializer struct {
+	cfg *configuration.Config
+
+	logger   *zap.Logger
+	closeLog func() error
+	engine   *in_memory.Engine
+	wal      *wal.WAL
+	storage  *storage.Storage
+	master   *replication.Master
+	replica  *replication.Replica
+	database *database.Database
+	encoder  result.Encoder
+	// registry is nil when metrics are disabled
+	registry  *metrics.Registry
+	startedAt time.Time
+
+	serversMutex sync.Mutex
+	servers      []*network.TCPServer
+}
+
+// NewInitializer builds the components, nothing runs in the background until Start.
+func NewInitializer(cfg *configuration.Config) (*Initializer, error) {
+	if cfg == nil {
+		return nil, errors.New("config is invalid")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	logger, closeLog, err := newLogger(cfg.Logging)
+	if err != nil {
+		return nil, err
+	}
+
+	initializer := &Initializer{
+		cfg:       cfg,
+		logger:    logger,
+		closeLog:  closeLog,
+		startedAt: time.Now(),
+	}
+
+	if err := initializer.build(); err != nil {
+		_ = initializer.Close()
+		return nil, err
+	}
+
+	return initializer, nil
+}
+
+func (i *Initializer) Logger() *zap.Logger {
+	return i.logger
+}
+
+func (i *Initializer) Database() *database.Database {
+	return i.database
+}
+
+func (i *Initializer) build() error {
+	cfg := i.cfg
+	if cfg.Metrics.Address != "" {
+		i.registry = metrics.NewRegistry()
+	}
+
+	var err error
+	i.encoder, err = result.ParseEncoder(cfg.Network.ReplyFormat)
+	if err != nil {
+		return err
+	}
+
+	parser, err := compute.NewParser(i.logger, compute.WithWordSymbols(cfg.Query.WordSymbols))
+	if err != nil {
+		return err
+	}
+
+	analyzer, err := compute.NewAnalyzer(i.logger)
+	if err != nil {
+		return err
+	}
+
+	comp, err := compute.NewCompute(parser, analyzer, i.logger)
+	if err != nil {
+		return err
+	}
+
+	tableBuilder := in_memory.HashTableBuilder
+	if cfg.Engine.Shards > 1 {
+		tableBuilder = in_memory.ShardedHashTableBuilder(cfg.Engine.Shards)
+	}
+
+	policy, err := in_memory.ParseEvictionPolicy(cfg.Engine.EvictionPolicy)
+	if err != nil {
+		return err
+	}
+
+	i.engine, err = in_memory.NewEngine(
+		tableBuilder,
+		i.logger,
+		in_memory.WithMaxMemory(int64(cfg.Engine.MaxMemory)),
+		in_memory.WithEvictionPolicy(policy),
+	)
+	if err != nil {
+		return err
+	}
+
+	i.registerEngineMetrics()
+
+	var storageOptions []storage.StorageOption
+	if cfg.WAL.Directory != "" {
+		strategy, err := wal.ParseFlushStrategy(cfg.WAL.FlushStrategy)
+		if err != nil {
+			return err
+		}
+
+		i.wal, err = wal.NewWAL(
+			cfg.WAL.Directory,
+			i.logger,
+			wal.WithFlushStrategy(strategy),
+			wal.WithFlushBatchSize(int(cfg.WAL.FlushBatchSize)),
+			wal.WithFlushTimeout(time.Duration(cfg.WAL.FlushBatchTimeout)),
+			wal.WithMaxSegmentSize(int(cfg.WAL.MaxSegmentSize)),
+			wal.WithMetrics(i.registry),
+		)
+		if err != nil {
+			return err
+		}
+
+		storageOptions = append(storageOptions, storage.WithWAL(i.wal))
+	}
+
+	if cfg.Snapshots.Directory != "" {
+		snapshots, err := snapshot.NewManager(cfg.Snapshots.Directory, i.logger)
+		if err != nil {
+			return err
+		}
+
+		storageOptions = append(storageOptions, storage.WithSnapshots(snapshots))
+	}
+
+	i.storage, err = storage.NewStorage(i.engine, i.logger, storageOptions...)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Replication.Address != "" {
+		i.master, err = replication.NewMaster(cfg.Replication.Address, i.wal, i.storage, i.logger)
+		if err != nil {
+			return err
+		}
+	}
+
+	databaseOptions := []database.DatabaseOption{
+		database.WithSlowLogThreshold(time.Duration(cfg.Logging.SlowQueryThreshold)),
+		database.WithMetrics(i.registry),
+	}
+	databaseOptions = append(databaseOptions, i.infoSections()...)
+	if cfg.Replication.ReplicaOf != "" {
+		i.replica, err = replication.NewReplica(
+			cfg.Replication.ReplicaOf,
+			i.storage,
+			i.logger,
+			replication.WithReplicaSyncInterval(time.Duration(cfg.Replication.SyncInterval)),
+		)
+		if err != nil {
+			return err
+		}
+
+		databaseOptions = append(databaseOptions, database.WithReplication(i.replica))
+	}
+
+	i.database, err = database.NewDatabase(comp, i.storage, i.logger, databaseOptions...)
+	return err
+}
+
+func (i *Initializer) registerEngineMetrics() {
+	i.registry.GaugeFunc("inmemdb_keys", "Stored keys, expired keys are counted until they are reclaimed.", func() float64 {
+		return float64(i.engine.Stats().Keys)
+	})
+	i.registry.GaugeFunc("inmemdb_used_memory_bytes", "Approximate memory taken by the keys.", func() float64 {
+		return float64(i.engine.Stats().UsedMemory)
+	})
+	i.registry.GaugeFunc("inmemdb_max_memory_bytes", "Memory limit of the keys, 0 means no limit.", func() float64 {
+		return float64(i.engine.Stats().MaxMemory)
+	})
+	i.registry.CounterFunc("inmemdb_evicted_keys_total", "Keys evicted at the memory limit.", func() float64 {
+		return float64(i.engine.Stats().EvictedKeys)
+	})
+}
+
+// Start runs the expiration sweeper, automatic snapshots and replication until ctx is done.
+func (i *Initializer) Start(ctx context.Context) {
+	go i.engine.RunExpirationSweeper(ctx, time.Duration(i.cfg.Engine.ExpirationInterval))
+
+	if i.cfg.Snapshots.Directory != "" && i.cfg.Snapshots.Interval > 0 {
+		go i.takeSnapshots(ctx, time.Duration(i.cfg.Snapshots.Interval))
+	}
+
+	if i.master != nil {
+		go func() {
+			if err := i.master.Serve(ctx); err != nil {
+				i.logger.Error(err.Error())
+			}
+		}()
+	}
+
+	if i.replica != nil {
+		go i.replica.Run(ctx)
+	}
+}
+
+// Serve handles clients of the text protocol and, when configured, of RESP
+// and metrics scrapes until ctx is done. It returns after in-flight queries are answered.
+func (i *Initializer) Serve(ctx context.Context) error {
+	serverOptions := []network.TCPServerOption{
+		network.WithServerMaxConnections(i.cfg.Network.MaxConnections),
+		network.WithServerIdleTimeout(time.Duration(i.cfg.Network.IdleTimeout)),
+		network.WithServerMaxMessageSize(int(i.cfg.Network.MaxMessageSize)),
+	}
+
+	// every listener is created before anything is served, so
+	// a failed one leaves nothing running behind the error
+	server, err := network.NewTCPServer(
+		i.cfg.Network.Address,
+		i.logger,
+		append(serverOptions, network.WithServerMetrics(i.registry, "text"))...,
+	)
+	if err != nil {
+		return err
+	}
+
+	var metricsListener net.Listener
+	if i.registry != nil {
+		metricsListener, err = net.Listen("tcp", i.cfg.Metrics.Address)
+		if err != nil {
+			_ = server.Close()
+			return fmt.Errorf("failed to listen for metrics: %w", err)
+		}
+	}
+
+	var respServer *network.TCPServer
+	if i.cfg.Network.RESPAddress != "" {
+		respServer, err = network.NewTCPServer(
+			i.cfg.Network.RESPAddress,
+			i.logger,
+			append(serverOptions, network.WithServerFramer(resp.NewFramer()), network.WithServerMetrics(i.registry, "resp"))...,
+		)
+		if err != nil {
+			_ = server.Close()
+			if metricsListener != nil {
+				_ = metricsListener.Close()
+			}
+
+			return err
+		}
+	}
+
+	// the other servers stop together with the text one even if it fails
+	var servers sync.WaitGroup
+	defer servers.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	i.addServer(server)
+	if metricsListener != nil {
+		servers.Add(1)
+		go func() {
+			defer servers.Done()
+			i.serveMetrics(ctx, metricsListener)
+		}()
+	}
+
+	if respServer != nil {
+		i.addServer(respServer)
+
+		servers.Add(1)
+		go func() {
+			defer servers.Done()
+
+			err := respServer.HandleSessions(ctx, func() network.TCPHandler {
+				session := i.database.NewSession()
+				return func(ctx context.Context, request []byte) []byte {
+					return resp.HandleRequest(ctx, session, request)
+				}
+			})
+			if err != nil {
+				i.logger.Error(err.Error())
+			}
+		}()
+	}
+
+	return server.HandleSessions(ctx, func() network.TCPHandler {
+		session := i.database.NewSession()
+		return func(ctx context.Context, request []byte) []byte {
+			return i.encoder.Encode(session.Execute(ctx, string(request)))
+		}
+	})
+}
+
+// Close flushes the log, it is called after Serve returned.
+func (i *Initializer) Close() error {
+	var err error
+	if i.wal != nil {
+		err = i.wal.Close()
+	}
+
+	_ = i.logger.Sync()
+	if closeErr := i.closeLog(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// addServer makes the server clients visible in INFO clients.
+func (i *Initializer) addServer(server *network.TCPServer) {
+	i.serversMutex.Lock()
+	defer i.serversMutex.Unlock()
+
+	i.servers = append(i.servers, server)
+}
+
+// serveMetrics serves the registry at /metrics until ctx is done.
+func (i *Initializer) serveMetrics(ctx context.Context, listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, i.registry)
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: metricsTimeout,
+		WriteTimeout:      metricsTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), metricsTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			i.logger.Warn("failed to shut down metrics server", zap.Error(err))
+		}
+	}()
+
+	i.logger.Info("serving metrics", zap.Stringer("address", listener.Addr()))
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		i.logger.Error("metrics server failed", zap.Error(err))
+	}
+}
+
+func (i *Initializer) takeSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := i.storage.Snapshot(ctx); err != nil {
+				i.logger.Error(err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package initialization
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"inmem-db-go/internal/configuration"
+	"net"
+	"testing"
+	"time"
+)
+
+// freeAddress returns an address nothing listens on right now.
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	return address
+}
+
+func TestServeFailsWithoutLeakingListeners(t *testing.T) {
+	t.Parallel()
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer busy.Close()
+
+	cfg := configuration.Default()
+	cfg.Network.Address = freeAddress(t)
+	cfg.Network.RESPAddress = busy.Addr().String()
+	cfg.Metrics.Address = freeAddress(t)
+
+	initializer, err := NewInitializer(cfg)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, initializer.Close()) }()
+
+	// ctx is never done, so Serve must not wait for it on the error path
+	served := make(chan error, 1)
+	go func() {
+		served <- initializer.Serve(context.Background())
+	}()
+
+	select {
+	case err := <-served:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("serve blocked after failing to listen")
+	}
+
+	for _, address := range []string{cfg.Network.Address, cfg.Metrics.Address} {
+		listener, err := net.Listen("tcp", address)
+		require.NoError(t, err, address)
+		require.NoError(t, listener.Close())
+	}
+}
@@ -0,0 +1,80 @@
+package initialization
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"inmem-db-go/internal/configuration"
+	"os"
+	"time"
+)
+
+const (
+	megabyte = 1 << 20
+	day      = 24 * time.Hour
+)
+
+// newLogger builds the logger described by the config, the returned
+// function closes its file once the logger is not used anymore.
+func newLogger(cfg configuration.LoggingConfig) (*zap.Logger, func() error, error) {
+	level, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var encoder zapcore.Encoder
+	switch cfg.Encoding {
+	case "json":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case "console":
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, nil, errors.New("logging encoding is invalid")
+	}
+
+	writer, closeWriter, err := newLogWriter(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger := zap.New(
+		zapcore.NewCore(encoder, writer, level),
+		zap.AddCaller(),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	)
+
+	return logger, closeWriter, nil
+}
+
+func newLogWriter(cfg configuration.LoggingConfig) (zapcore.WriteSyncer, func() error, error) {
+	noClose := func() error { return nil }
+	switch cfg.Output {
+	case configuration.StderrOutput:
+		return zapcore.Lock(os.Stderr), noClose, nil
+	case configuration.StdoutOutput:
+		return zapcore.Lock(os.Stdout), noClose, nil
+	}
+
+	if cfg.MaxSize == 0 {
+		writer, closeWriter, err := zap.Open(cfg.Output)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return writer, func() error { closeWriter(); return nil }, nil
+	}
+
+	// lumberjack counts the size in megabytes and the age in days
+	rotator := &lumberjack.Logger{
+		Filename:   cfg.Output,
+		MaxSize:    int((int64(cfg.MaxSize) + megabyte - 1) / megabyte),
+		MaxAge:     int((time.Duration(cfg.MaxAge) + day - 1) / day),
+		MaxBackups: cfg.MaxBackups,
+	}
+
+	return zapcore.AddSync(rotator), rotator.Close, nil
+}
@@ -0,0 +1,63 @@
+package initialization
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"inmem-db-go/internal/configuration"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]configuration.LoggingConfig{
+		"json file": {
+			Level:    "info",
+			Encoding: "json",
+		},
+		"rotated console file": {
+			Level:      "debug",
+			Encoding:   "console",
+			MaxSize:    1 << 20,
+			MaxAge:     configuration.Duration(7 * 24 * time.Hour),
+			MaxBackups: 3,
+		},
+	}
+
+	for name, cfg := range tests {
+		cfg := cfg
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg.Output = filepath.Join(t.TempDir(), "inmemdb.log")
+			logger, closeLog, err := newLogger(cfg)
+			require.NoError(t, err)
+
+			logger.Debug("debug message")
+			logger.Info("info message")
+			require.NoError(t, closeLog())
+
+			data, err := os.ReadFile(cfg.Output)
+			require.NoError(t, err)
+			assert.Contains(t, string(data), "info message")
+			if cfg.Level == "debug" {
+				assert.Contains(t, string(data), "debug message")
+			} else {
+				assert.NotContains(t, string(data), "debug message")
+			}
+		})
+	}
+}
+
+func TestNewLoggerInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := newLogger(configuration.LoggingConfig{Level: "verbose", Encoding: "json", Output: "stderr"})
+	require.Error(t, err)
+
+	_, _, err = newLogger(configuration.LoggingConfig{Level: "info", Encoding: "text", Output: "stderr"})
+	require.Error(t, err)
+}
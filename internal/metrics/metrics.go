@@ -0,0 +1,552 @@
+// Package metrics collects counters, gauges and histograms and exposes them
+// in the Prometheus text format.
+//
+// A nil registry returns nil metrics and nil metrics ignore updates,
+// so components are instrumented unconditionally and cost nothing
+// when metrics are disabled.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultLatencyBuckets are upper bounds in seconds from 100µs to 1s.
+var DefaultLatencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+type metric interface {
+	kind() string
+	write(buffer *bytes.Buffer, name string)
+}
+
+type family struct {
+	name   string
+	help   string
+	metric metric
+}
+
+// Registry is safe for concurrent use. Metrics are created on the first
+// request by name, later requests of the same name return the same metric.
+type Registry struct {
+	mutex    sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+func (r *Registry) Counter(name, help string) *Counter {
+	if r == nil {
+		return nil
+	}
+
+	return register(r, name, help, func() *Counter { return &Counter{} })
+}
+
+func (r *Registry) CounterVec(name, help, label string) *CounterVec {
+	if r == nil {
+		return nil
+	}
+
+	return register(r, name, help, func() *CounterVec {
+		return &CounterVec{vec: newVec(label, func() *Counter { return &Counter{} })}
+	})
+}
+
+// CounterFunc exposes a monotonic value maintained elsewhere, fn is called on every scrape.
+func (r *Registry) CounterFunc(name, help string, fn func() float64) {
+	if r == nil {
+		return
+	}
+
+	register(r, name, help, func() *valueFunc { return &valueFunc{metricKind: "counter", fn: fn} })
+}
+
+func (r *Registry) Gauge(name, help string) *Gauge {
+	if r == nil {
+		return nil
+	}
+
+	return register(r, name, help, func() *Gauge { return &Gauge{} })
+}
+
+func (r *Registry) GaugeVec(name, help, label string) *GaugeVec {
+	if r == nil {
+		return nil
+	}
+
+	return register(r, name, help, func() *GaugeVec {
+		return &GaugeVec{vec: newVec(label, func() *Gauge { return &Gauge{} })}
+	})
+}
+
+// GaugeFunc exposes a value maintained elsewhere, fn is called on every scrape.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	if r == nil {
+		return
+	}
+
+	register(r, name, help, func() *valueFunc { return &valueFunc{metricKind: "gauge", fn: fn} })
+}
+
+// Histogram counts observations in buckets with the given upper bounds.
+func (r *Registry) Histogram(name, help string, buckets []float64) *Histogram {
+	if r == nil {
+		return nil
+	}
+
+	return register(r, name, help, func() *Histogram { return newHistogram(buckets) })
+}
+
+func (r *Registry) HistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	if r == nil {
+		return nil
+	}
+
+	return register(r, name, help, func() *HistogramVec {
+		return &HistogramVec{vec: newVec(label, func() *Histogram { return newHistogram(buckets) })}
+	})
+}
+
+// register returns the metric registered under the name or the new one.
+// Registering different kinds of metrics under one name is a programming
+// error, so it panics like a failed regexp.MustCompile.
+func register[T metric](r *Registry, name, help string, create func() T) T {
+	if !isValidName(name) {
+		panic(fmt.Sprintf("metrics: name %q is invalid", name))
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if existing, found := r.families[name]; found {
+		registered, ok := existing.metric.(T)
+		if !ok {
+			panic(fmt.Sprintf("metrics: %q is already registered as a %s", name, existing.metric.kind()))
+		}
+
+		return registered
+	}
+
+	created := create()
+	r.families[name] = &family{name: name, help: help, metric: created}
+	return created
+}
+
+// WriteText writes the metrics sorted by name in the Prometheus text format.
+func (r *Registry) WriteText(buffer *bytes.Buffer) {
+	r.mutex.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, family := range r.families {
+		families = append(families, family)
+	}
+	r.mutex.Unlock()
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	for _, family := range families {
+		fmt.Fprintf(buffer, "# HELP %s %s\n", family.name, escapeHelp(family.help))
+		fmt.Fprintf(buffer, "# TYPE %s %s\n", family.name, family.metric.kind())
+		family.metric.write(buffer, family.name)
+	}
+}
+
+// ServeHTTP makes the registry the handler of the scrape endpoint.
+func (r *Registry) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		writer.Header().Set("Allow", "GET, HEAD")
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var buffer bytes.Buffer
+	r.WriteText(&buffer)
+
+	writer.Header().Set("Content-Type", contentType)
+	_, _ = writer.Write(buffer.Bytes())
+}
+
+// Counter only goes up.
+type Counter struct {
+	value atomicFloat
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add ignores negative values, counters never decrease.
+func (c *Counter) Add(delta float64) {
+	if c == nil || delta < 0 {
+		return
+	}
+
+	c.value.add(delta)
+}
+
+func (c *Counter) Value() float64 {
+	if c == nil {
+		return 0
+	}
+
+	return c.value.load()
+}
+
+func (c *Counter) kind() string {
+	return "counter"
+}
+
+func (c *Counter) write(buffer *bytes.Buffer, name string) {
+	writeSample(buffer, name, "", c.Value())
+}
+
+type Gauge struct {
+	value atomicFloat
+}
+
+func (g *Gauge) Set(value float64) {
+	if g == nil {
+		return
+	}
+
+	g.value.store(value)
+}
+
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *Gauge) Add(delta float64) {
+	if g == nil {
+		return
+	}
+
+	g.value.add(delta)
+}
+
+func (g *Gauge) Value() float64 {
+	if g == nil {
+		return 0
+	}
+
+	return g.value.load()
+}
+
+func (g *Gauge) kind() string {
+	return "gauge"
+}
+
+func (g *Gauge) write(buffer *bytes.Buffer, name string) {
+	writeSample(buffer, name, "", g.Value())
+}
+
+type valueFunc struct {
+	metricKind string
+	fn         func() float64
+}
+
+func (f *valueFunc) kind() string {
+	return f.metricKind
+}
+
+func (f *valueFunc) write(buffer *bytes.Buffer, name string) {
+	writeSample(buffer, name, "", f.fn())
+}
+
+type Histogram struct {
+	buckets []float64
+	// the last count is of observations above every bucket
+	counts []atomic.Uint64
+	sum    atomicFloat
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &Histogram{
+		buckets: sorted,
+		counts:  make([]atomic.Uint64, len(sorted)+1),
+	}
+}
+
+func (h *Histogram) Observe(value float64) {
+	if h == nil {
+		return
+	}
+
+	// counts are kept per bucket and accumulated on write
+	h.counts[sort.SearchFloat64s(h.buckets, value)].Add(1)
+	h.sum.add(value)
+}
+
+// ObserveDuration observes the duration in seconds.
+func (h *Histogram) ObserveDuration(duration time.Duration) {
+	h.Observe(duration.Seconds())
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	if h == nil {
+		return 0
+	}
+
+	var count uint64
+	for i := range h.counts {
+		count += h.counts[i].Load()
+	}
+
+	return count
+}
+
+func (h *Histogram) kind() string {
+	return "histogram"
+}
+
+func (h *Histogram) write(buffer *bytes.Buffer, name string) {
+	h.writeLabeled(buffer, name, "")
+}
+
+func (h *Histogram) writeLabeled(buffer *bytes.Buffer, name, labels string) {
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i].Load()
+		writeSample(buffer, name+"_bucket", joinLabels(labels, `le="`+formatFloat(bound)+`"`), float64(cumulative))
+	}
+
+	cumulative += h.counts[len(h.buckets)].Load()
+	writeSample(buffer, name+"_bucket", joinLabels(labels, `le="+Inf"`), float64(cumulative))
+	writeSample(buffer, name+"_sum", labels, h.sum.load())
+	writeSample(buffer, name+"_count", labels, float64(cumulative))
+}
+
+type CounterVec struct {
+	vec *vec[*Counter]
+}
+
+// WithLabel returns the counter of the label value.
+func (v *CounterVec) WithLabel(value string) *Counter {
+	if v == nil {
+		return nil
+	}
+
+	return v.vec.get(value)
+}
+
+func (v *CounterVec) kind() string {
+	return "counter"
+}
+
+func (v *CounterVec) write(buffer *bytes.Buffer, name string) {
+	v.vec.each(func(labels string, counter *Counter) {
+		writeSample(buffer, name, labels, counter.Value())
+	})
+}
+
+type GaugeVec struct {
+	vec *vec[*Gauge]
+}
+
+// WithLabel returns the gauge of the label value.
+func (v *GaugeVec) WithLabel(value string) *Gauge {
+	if v == nil {
+		return nil
+	}
+
+	return v.vec.get(value)
+}
+
+func (v *GaugeVec) kind() string {
+	return "gauge"
+}
+
+func (v *GaugeVec) write(buffer *bytes.Buffer, name string) {
+	v.vec.each(func(labels string, gauge *Gauge) {
+		writeSample(buffer, name, labels, gauge.Value())
+	})
+}
+
+type HistogramVec struct {
+	vec *vec[*Histogram]
+}
+
+// WithLabel returns the histogram of the label value.
+func (v *HistogramVec) WithLabel(value string) *Histogram {
+	if v == nil {
+		return nil
+	}
+
+	return v.vec.get(value)
+}
+
+func (v *HistogramVec) kind() string {
+	return "histogram"
+}
+
+func (v *HistogramVec) write(buffer *bytes.Buffer, name string) {
+	v.vec.each(func(labels string, histogram *Histogram) {
+		histogram.writeLabeled(buffer, name, labels)
+	})
+}
+
+// vec keeps a metric per value of a single label.
+type vec[T any] struct {
+	label  string
+	create func() T
+
+	mutex   sync.RWMutex
+	metrics map[string]T
+}
+
+func newVec[T any](label string, create func() T) *vec[T] {
+	if !isValidName(label) {
+		panic(fmt.Sprintf("metrics: label %q is invalid", label))
+	}
+
+	return &vec[T]{
+		label:   label,
+		create:  create,
+		metrics: make(map[string]T),
+	}
+}
+
+func (v *vec[T]) get(value string) T {
+	v.mutex.RLock()
+	metric, found := v.metrics[value]
+	v.mutex.RUnlock()
+	if found {
+		return metric
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if metric, found = v.metrics[value]; !found {
+		metric = v.create()
+		v.metrics[value] = metric
+	}
+
+	return metric
+}
+
+// each visits the metrics sorted by the label value.
+func (v *vec[T]) each(fn func(labels string, metric T)) {
+	v.mutex.RLock()
+	metrics := make(map[string]T, len(v.metrics))
+	values := make([]string, 0, len(v.metrics))
+	for value, metric := range v.metrics {
+		metrics[value] = metric
+		values = append(values, value)
+	}
+	v.mutex.RUnlock()
+
+	sort.Strings(values)
+	for _, value := range values {
+		fn(v.label+`="`+escapeLabelValue(value)+`"`, metrics[value])
+	}
+}
+
+// atomicFloat is a float64 updated with compare-and-swap on its bits.
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) add(delta float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) store(value float64) {
+	f.bits.Store(math.Float64bits(value))
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+func writeSample(buffer *bytes.Buffer, name, labels string, value float64) {
+	buffer.WriteString(name)
+	if labels != "" {
+		buffer.WriteByte('{')
+		buffer.WriteString(labels)
+		buffer.WriteByte('}')
+	}
+
+	buffer.WriteByte(' ')
+	buffer.WriteString(formatFloat(value))
+	buffer.WriteByte('\n')
+}
+
+func joinLabels(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+
+	return labels + "," + label
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// isValidName checks the name syntax [a-zA-Z_:][a-zA-Z0-9_:]*.
+func isValidName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, symbol := range name {
+		isLetter := symbol >= 'a' && symbol <= 'z' || symbol >= 'A' && symbol <= 'Z' || symbol == '_' || symbol == ':'
+		isDigit := symbol >= '0' && symbol <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteText(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	commands := registry.CounterVec("inmemdb_commands_total", "Executed commands.", "command")
+	commands.WithLabel("SET").Inc()
+	commands.WithLabel("GET").Add(2)
+	commands.WithLabel(`"quoted"`).Inc()
+
+	registry.Gauge("inmemdb_connected_clients", "Connected clients.").Set(3)
+	registry.GaugeFunc("inmemdb_keys", "Stored keys.", func() float64 { return 42 })
+	registry.CounterFunc("inmemdb_evicted_keys_total", "Evicted keys.", func() float64 { return 7 })
+
+	latency := registry.Histogram("inmemdb_fsync_duration_seconds", "Fsync latency.", []float64{0.1, 0.01})
+	latency.Observe(0.005)
+	latency.Observe(0.01)
+	latency.ObserveDuration(50 * time.Millisecond)
+	latency.Observe(2)
+
+	var buffer bytes.Buffer
+	registry.WriteText(&buffer)
+	assert.Equal(t, `# HELP inmemdb_commands_total Executed commands.
+# TYPE inmemdb_commands_total counter
+inmemdb_commands_total{command="\"quoted\""} 1
+inmemdb_commands_total{command="GET"} 2
+inmemdb_commands_total{command="SET"} 1
+# HELP inmemdb_connected_clients Connected clients.
+# TYPE inmemdb_connected_clients gauge
+inmemdb_connected_clients 3
+# HELP inmemdb_evicted_keys_total Evicted keys.
+# TYPE inmemdb_evicted_keys_total counter
+inmemdb_evicted_keys_total 7
+# HELP inmemdb_fsync_duration_seconds Fsync latency.
+# TYPE inmemdb_fsync_duration_seconds histogram
+inmemdb_fsync_duration_seconds_bucket{le="0.01"} 2
+inmemdb_fsync_duration_seconds_bucket{le="0.1"} 3
+inmemdb_fsync_duration_seconds_bucket{le="+Inf"} 4
+inmemdb_fsync_duration_seconds_sum 2.065
+inmemdb_fsync_duration_seconds_count 4
+# HELP inmemdb_keys Stored keys.
+# TYPE inmemdb_keys gauge
+inmemdb_keys 42
+`, buffer.String())
+}
+
+func TestHistogramVec(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	latency := registry.HistogramVec("latency_seconds", "Latency.", "command", []float64{1})
+	latency.WithLabel("GET").Observe(0.5)
+
+	var buffer bytes.Buffer
+	registry.WriteText(&buffer)
+	assert.Contains(t, buffer.String(), `latency_seconds_bucket{command="GET",le="1"} 1
+latency_seconds_bucket{command="GET",le="+Inf"} 1
+latency_seconds_sum{command="GET"} 0.5
+latency_seconds_count{command="GET"} 1
+`)
+}
+
+func TestRegisterSameName(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	counter := registry.Counter("requests_total", "Requests.")
+	assert.Same(t, counter, registry.Counter("requests_total", "Requests."))
+
+	assert.Panics(t, func() { registry.Gauge("requests_total", "Requests.") })
+	assert.Panics(t, func() { registry.Counter("requests-total", "Requests.") })
+	assert.Panics(t, func() { registry.CounterVec("requests", "Requests.", "1st") })
+}
+
+func TestNilRegistry(t *testing.T) {
+	t.Parallel()
+
+	var registry *Registry
+	assert.NotPanics(t, func() {
+		registry.Counter("counter", "").Inc()
+		registry.CounterVec("counter_vec", "", "label").WithLabel("value").Add(2)
+		registry.Gauge("gauge", "").Set(1)
+		registry.GaugeVec("gauge_vec", "", "label").WithLabel("value").Dec()
+		registry.GaugeFunc("gauge_func", "", func() float64 { return 0 })
+		registry.Histogram("histogram", "", DefaultLatencyBuckets).Observe(1)
+		registry.HistogramVec("histogram_vec", "", "label", DefaultLatencyBuckets).WithLabel("value").Observe(1)
+	})
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	counter := registry.CounterVec("counter", "", "label")
+	histogram := registry.Histogram("histogram", "", DefaultLatencyBuckets)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				counter.WithLabel("value").Inc()
+				histogram.Observe(0.001)
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, float64(1000), counter.WithLabel("value").Value())
+	assert.Equal(t, uint64(1000), histogram.Count())
+}
+
+func TestServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	registry.Counter("requests_total", "Requests.").Inc()
+
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, contentType, recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "requests_total 1\n")
+
+	recorder = httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
@@ -0,0 +1,61 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Framer splits the stream of a connection into requests
+// and frames the responses of the handler.
+type Framer interface {
+	// ReadRequest reads the next request, the request must not exceed maxSize bytes.
+	ReadRequest(reader *bufio.Reader, maxSize int) ([]byte, error)
+	FrameResponse(response []byte) []byte
+	FrameError(err error) []byte
+}
+
+// lineFramer serves newline-delimited text queries.
+type lineFramer struct{}
+
+func (lineFramer) ReadRequest(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	return readLine(reader, maxSize)
+}
+
+func (lineFramer) FrameResponse(response []byte) []byte {
+	return append(response, '\n')
+}
+
+func (lineFramer) FrameError(err error) []byte {
+	return []byte(fmt.Sprintf("[error] %s\n", err.Error()))
+}
+
+// readLine reads a newline-terminated message without the trailing "\n" or "\r\n".
+// Messages longer than maxSize bytes are rejected with ErrMessageTooLarge,
+// the reader buffer must fit maxSize bytes followed by "\r\n".
+func readLine(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	line, err := reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, ErrMessageTooLarge
+	}
+
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return nil, err
+	}
+
+	// the last query may come without a trailing newline right before EOF
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+	if len(line) != 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+
+	if len(line) > maxSize {
+		return nil, ErrMessageTooLarge
+	}
+
+	request := make([]byte, len(line))
+	copy(request, line)
+	return request, nil
+}
@@ -0,0 +1,106 @@
+package resp
+
+import (
+	"context"
+	"inmem-db-go/internal/database/result"
+	"strings"
+)
+
+const pingCommand = "PING"
+
+type queryHandler interface {
+	ExecuteTokens(context.Context, []string) result.Result
+}
+
+// Encoder encodes results as RESP values of the types Redis uses for them.
+type Encoder struct{}
+
+func (Encoder) Encode(reply result.Result) []byte {
+	switch reply.Status {
+	case result.StatusError:
+		return AppendError(nil, errorKind(reply.Code)+" "+reply.Message)
+	case result.StatusNotFound:
+		if reply.Kind == result.KindCount {
+			return AppendInteger(nil, 0)
+		}
+
+		return AppendNil(nil)
+	}
+
+	switch reply.Kind {
+	case result.KindString:
+		return AppendBulkString(nil, reply.Value)
+	case result.KindInteger:
+		return AppendInteger(nil, reply.Integer)
+	case result.KindCount:
+		return AppendInteger(nil, reply.Affected)
+	case result.KindList:
+		buffer := appendHeader(nil, arrayPrefix, len(reply.Values))
+		for _, value := range reply.Values {
+			if value == nil {
+				buffer = AppendNil(buffer)
+			} else {
+				buffer = AppendBulkString(buffer, *value)
+			}
+		}
+
+		return buffer
+	case result.KindCursor:
+		buffer := appendHeader(nil, arrayPrefix, 2)
+		buffer = AppendBulkString(buffer, reply.Value)
+		buffer = appendHeader(buffer, arrayPrefix, len(reply.Values))
+		for _, value := range reply.Values {
+			buffer = AppendBulkString(buffer, *value)
+		}
+
+		return buffer
+	}
+
+	return AppendSimpleString(nil, "OK")
+}
+
+// HandleRequest runs a request read by Framer with the handler.
+func HandleRequest(ctx context.Context, handler queryHandler, request []byte) []byte {
+	tokens, err := DecodeCommand(request)
+	if err != nil {
+		return AppendError(nil, "ERR "+err.Error())
+	}
+
+	if len(tokens) == 0 {
+		return AppendError(nil, "ERR "+errEmptyCommand.Error())
+	}
+
+	// commands are case-insensitive in RESP
+	command := strings.ToUpper(tokens[0])
+	tokens[0] = command
+	if command == pingCommand {
+		return handlePing(tokens[1:])
+	}
+
+	return Encoder{}.Encode(handler.ExecuteTokens(ctx, tokens))
+}
+
+func handlePing(arguments []string) []byte {
+	switch len(arguments) {
+	case 0:
+		return AppendSimpleString(nil, "PONG")
+	case 1:
+		return AppendBulkString(nil, arguments[0])
+	}
+
+	return AppendError(nil, "ERR wrong number of arguments for 'ping' command")
+}
+
+// errorKind returns the error kind clients of Redis expect for the code.
+func errorKind(code result.Code) string {
+	switch code {
+	case result.CodeOutOfMemory:
+		return "OOM"
+	case result.CodeReadOnly:
+		return "READONLY"
+	case result.CodeWrongType:
+		return "WRONGTYPE"
+	}
+
+	return "ERR"
+}
@@ -0,0 +1,225 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"inmem-db-go/internal/network"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	arrayPrefix        = '*'
+	bulkStringPrefix   = '$'
+	simpleStringPrefix = '+'
+	errorPrefix        = '-'
+	integerPrefix      = ':'
+)
+
+var (
+	errEmptyCommand     = errors.New("empty command")
+	errTruncatedRequest = fmt.Errorf("%w: unexpected end of request", network.ErrMalformedRequest)
+)
+
+// Framer reads RESP arrays of bulk strings and inline commands,
+// requests are passed to the handler encoded as arrays, see DecodeCommand.
+type Framer struct{}
+
+func NewFramer() *Framer {
+	return &Framer{}
+}
+
+func (f *Framer) ReadRequest(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	tokens, err := readCommand(reader, maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncodeCommand(tokens), nil
+}
+
+// FrameResponse keeps the response as is, the handler replies with encoded values.
+func (f *Framer) FrameResponse(response []byte) []byte {
+	return response
+}
+
+func (f *Framer) FrameError(err error) []byte {
+	return AppendError(nil, "ERR "+err.Error())
+}
+
+// EncodeCommand encodes tokens as an array of bulk strings.
+func EncodeCommand(tokens []string) []byte {
+	command := appendHeader(nil, arrayPrefix, len(tokens))
+	for _, token := range tokens {
+		command = AppendBulkString(command, token)
+	}
+
+	return command
+}
+
+// DecodeCommand decodes a request produced by Framer.
+func DecodeCommand(request []byte) ([]string, error) {
+	return readCommand(bufio.NewReader(bytes.NewReader(request)), len(request))
+}
+
+func AppendSimpleString(buffer []byte, value string) []byte {
+	buffer = append(buffer, simpleStringPrefix)
+	buffer = append(buffer, sanitize(value)...)
+	return append(buffer, '\r', '\n')
+}
+
+func AppendError(buffer []byte, message string) []byte {
+	buffer = append(buffer, errorPrefix)
+	buffer = append(buffer, sanitize(message)...)
+	return append(buffer, '\r', '\n')
+}
+
+func AppendInteger(buffer []byte, value int64) []byte {
+	buffer = append(buffer, integerPrefix)
+	buffer = strconv.AppendInt(buffer, value, 10)
+	return append(buffer, '\r', '\n')
+}
+
+func AppendBulkString(buffer []byte, value string) []byte {
+	buffer = appendHeader(buffer, bulkStringPrefix, len(value))
+	buffer = append(buffer, value...)
+	return append(buffer, '\r', '\n')
+}
+
+// AppendNil appends the null bulk string, the reply for missing values.
+func AppendNil(buffer []byte) []byte {
+	return append(buffer, "$-1\r\n"...)
+}
+
+func appendHeader(buffer []byte, prefix byte, size int) []byte {
+	buffer = append(buffer, prefix)
+	buffer = strconv.AppendInt(buffer, int64(size), 10)
+	return append(buffer, '\r', '\n')
+}
+
+// sanitize keeps simple strings and errors on a single line.
+func sanitize(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+// readCommand reads an array of bulk strings or, when the request
+// does not start with '*', an inline command of space separated words.
+// The whole request must not exceed maxSize bytes.
+func readCommand(reader *bufio.Reader, maxSize int) ([]string, error) {
+	limit := &limitedReader{reader: reader, remaining: maxSize}
+	prefix, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix[0] != arrayPrefix {
+		line, err := limit.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		return strings.Fields(line), nil
+	}
+
+	count, err := limit.readHeader(arrayPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]string, 0, min(count, maxSize))
+	for i := 0; i < count; i++ {
+		size, err := limit.readHeader(bulkStringPrefix)
+		if err != nil {
+			return nil, err
+		}
+
+		token, err := limit.readBulk(size)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// limitedReader reads parts of one request and fails with
+// network.ErrMessageTooLarge once the request exceeds its size limit.
+type limitedReader struct {
+	reader    *bufio.Reader
+	remaining int
+}
+
+func (r *limitedReader) readLine() (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+		if len(chunk) > r.remaining {
+			return "", network.ErrMessageTooLarge
+		}
+
+		r.remaining -= len(chunk)
+		line = append(line, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+
+		if err == io.EOF && len(line) != 0 {
+			return "", errTruncatedRequest
+		} else if err != nil {
+			return "", err
+		}
+
+		break
+	}
+
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	return string(line), nil
+}
+
+func (r *limitedReader) readHeader(prefix byte) (int, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(line) == 0 || line[0] != prefix {
+		return 0, fmt.Errorf("%w: expected '%c'", network.ErrMalformedRequest, prefix)
+	}
+
+	size, err := strconv.Atoi(line[1:])
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("%w: invalid length %q", network.ErrMalformedRequest, line[1:])
+	}
+
+	if size > r.remaining {
+		return 0, network.ErrMessageTooLarge
+	}
+
+	return size, nil
+}
+
+func (r *limitedReader) readBulk(size int) (string, error) {
+	if size+2 > r.remaining {
+		return "", network.ErrMessageTooLarge
+	}
+
+	r.remaining -= size + 2
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r.reader, data); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return "", errTruncatedRequest
+	} else if err != nil {
+		return "", err
+	}
+
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return "", fmt.Errorf("%w: bulk string is not terminated", network.ErrMalformedRequest)
+	}
+
+	return string(data[:size]), nil
+}
@@ -0,0 +1,157 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/network"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+type handlerFunc func(context.Context, []string) result.Result
+
+func (f handlerFunc) ExecuteTokens(ctx context.Context, tokens []string) result.Result {
+	return f(ctx, tokens)
+}
+
+func TestReadRequest(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		request        string
+		maxSize        int
+		expectedTokens []string
+		expectedError  error
+	}{
+		{name: "array of bulk strings", request: "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$12\r\nhello\r\nworld\r\n", maxSize: 1024,
+			expectedTokens: []string{"SET", "key", "hello\r\nworld"}},
+		{name: "empty bulk string", request: "*2\r\n$3\r\nGET\r\n$0\r\n\r\n", maxSize: 1024,
+			expectedTokens: []string{"GET", ""}},
+		{name: "inline command", request: "GET  key\r\n", maxSize: 1024,
+			expectedTokens: []string{"GET", "key"}},
+		{name: "empty array", request: "*0\r\n", maxSize: 1024,
+			expectedTokens: []string{}},
+		{name: "closed connection", request: "", maxSize: 1024,
+			expectedError: io.EOF},
+		{name: "not a bulk string", request: "*1\r\n:1\r\n", maxSize: 1024,
+			expectedError: network.ErrMalformedRequest},
+		{name: "invalid length", request: "*1\r\n$x\r\n", maxSize: 1024,
+			expectedError: network.ErrMalformedRequest},
+		{name: "unterminated bulk string", request: "*1\r\n$3\r\nGETX\r\n", maxSize: 1024,
+			expectedError: network.ErrMalformedRequest},
+		{name: "truncated request", request: "*2\r\n$3\r\nGET\r\n$3\r\nke", maxSize: 1024,
+			expectedError: network.ErrMalformedRequest},
+		{name: "too large bulk string", request: "*1\r\n$100\r\n", maxSize: 32,
+			expectedError: network.ErrMessageTooLarge},
+		{name: "too large inline command", request: strings.Repeat("a", 64) + "\r\n", maxSize: 32,
+			expectedError: network.ErrMessageTooLarge},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			request, err := NewFramer().ReadRequest(bufio.NewReaderSize(strings.NewReader(tc.request), 16), tc.maxSize)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			tokens, err := DecodeCommand(request)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedTokens, tokens)
+		})
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+	t.Parallel()
+
+	value := "a"
+	testCases := []struct {
+		name     string
+		tokens   []string
+		reply    result.Result
+		expected string
+	}{
+		{name: "simple string", tokens: []string{"set", "key", "value"}, reply: result.OK(), expected: "+OK\r\n"},
+		{name: "bulk string", tokens: []string{"GET", "key"}, reply: result.String("1"), expected: "$1\r\n1\r\n"},
+		{name: "nil bulk string", tokens: []string{"GET", "key"}, reply: result.NotFound(), expected: "$-1\r\n"},
+		{name: "removed key", tokens: []string{"DEL", "key"}, reply: result.Count(1), expected: ":1\r\n"},
+		{name: "missing key", tokens: []string{"DEL", "key"}, reply: result.Count(0), expected: ":0\r\n"},
+		{name: "integer", tokens: []string{"ttl", "key"}, reply: result.Integer(-2), expected: ":-2\r\n"},
+		{name: "array", tokens: []string{"KEYS"}, reply: result.List([]*string{&value, nil}), expected: "*2\r\n$1\r\na\r\n$-1\r\n"},
+		{name: "cursor", tokens: []string{"SCAN", "0"}, reply: result.Cursor("0", []*string{&value}), expected: "*2\r\n$1\r\n0\r\n*1\r\n$1\r\na\r\n"},
+		{name: "error", tokens: []string{"GET"}, reply: result.FromError(result.NewError(result.CodeWrongArity, "invalid arguments")),
+			expected: "-ERR invalid arguments\r\n"},
+		{name: "out of memory", tokens: []string{"SET", "key", "value"}, reply: result.FromError(result.NewError(result.CodeOutOfMemory, "out of memory")),
+			expected: "-OOM out of memory\r\n"},
+		{name: "wrong type", tokens: []string{"HGET", "key", "field"}, reply: result.FromError(result.NewError(result.CodeWrongType, "wrong type")),
+			expected: "-WRONGTYPE wrong type\r\n"},
+		{name: "ping", tokens: []string{"PING"}, expected: "+PONG\r\n"},
+		{name: "ping with message", tokens: []string{"ping", "hi"}, expected: "$2\r\nhi\r\n"},
+		{name: "empty command", tokens: []string{}, expected: "-ERR empty command\r\n"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := handlerFunc(func(_ context.Context, tokens []string) result.Result {
+				assert.Equal(t, strings.ToUpper(tc.tokens[0]), tokens[0])
+				return tc.reply
+			})
+
+			response := HandleRequest(context.Background(), handler, EncodeCommand(tc.tokens))
+			assert.Equal(t, tc.expected, string(response))
+		})
+	}
+}
+
+func TestServerWithFramer(t *testing.T) {
+	t.Parallel()
+
+	server, err := network.NewTCPServer("localhost:0", zap.NewNop(), network.WithServerFramer(NewFramer()))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	handler := handlerFunc(func(_ context.Context, tokens []string) result.Result {
+		return result.String(strings.Join(tokens[1:], ","))
+	})
+	go func() {
+		_ = server.HandleQueries(ctx, func(ctx context.Context, request []byte) []byte {
+			return HandleRequest(ctx, handler, request)
+		})
+	}()
+
+	connection, err := net.Dial("tcp", server.Address().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = connection.Close() })
+
+	_, err = connection.Write(append(EncodeCommand([]string{"GET", "a b"}), "GET c\r\n*1\r\n+GET\r\n"...))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(connection)
+	expected := []string{"$3\r\n", "a b\r\n", "$1\r\n", "c\r\n", "-ERR malformed request: expected '$'\r\n"}
+	for _, line := range expected {
+		response, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, line, response)
+	}
+
+	// the connection is closed after a malformed request
+	_, err = reader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+}
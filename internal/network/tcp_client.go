@@ -0,0 +1,89 @@
+package network
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	defaultClientTimeout         = 10 * time.Second
+	defaultClientMaxResponseSize = 1 << 20
+)
+
+type TCPClientOption func(*TCPClient)
+
+func WithClientTimeout(timeout time.Duration) TCPClientOption {
+	return func(client *TCPClient) {
+		client.timeout = timeout
+	}
+}
+
+func WithClientMaxResponseSize(size int) TCPClientOption {
+	return func(client *TCPClient) {
+		client.maxResponseSize = size
+	}
+}
+
+type TCPClient struct {
+	connection net.Conn
+	reader     *bufio.Reader
+
+	timeout         time.Duration
+	maxResponseSize int
+}
+
+func NewTCPClient(address string, options ...TCPClientOption) (*TCPClient, error) {
+	client := &TCPClient{
+		timeout:         defaultClientTimeout,
+		maxResponseSize: defaultClientMaxResponseSize,
+	}
+
+	for _, option := range options {
+		option(client)
+	}
+
+	if client.timeout <= 0 {
+		return nil, errors.New("timeout is invalid")
+	}
+
+	if client.maxResponseSize <= 0 {
+		return nil, errors.New("max response size is invalid")
+	}
+
+	connection, err := net.DialTimeout("tcp", address, client.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	client.connection = connection
+	client.reader = bufio.NewReaderSize(connection, client.maxResponseSize+2)
+	return client, nil
+}
+
+// Send writes a single query and waits for its response. The request
+// must not contain newlines, they are used as query delimiters.
+func (c *TCPClient) Send(request []byte) ([]byte, error) {
+	if err := c.connection.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+
+	message := make([]byte, 0, len(request)+1)
+	message = append(append(message, request...), '\n')
+	if _, err := c.connection.Write(message); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	response, err := readLine(c.reader, c.maxResponseSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return response, nil
+}
+
+func (c *TCPClient) Close() error {
+	return c.connection.Close()
+}
@@ -0,0 +1,85 @@
+package network
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewTCPClient(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewTCPClient("localhost:0", WithClientTimeout(0))
+	require.Error(t, err, "timeout is invalid")
+	require.Nil(t, client)
+
+	client, err = NewTCPClient("localhost:0", WithClientMaxResponseSize(0))
+	require.Error(t, err, "max response size is invalid")
+	require.Nil(t, client)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	client, err = NewTCPClient(address)
+	var opErr *net.OpError
+	require.ErrorAs(t, err, &opErr)
+	require.Nil(t, client)
+}
+
+func TestClientSend(t *testing.T) {
+	t.Parallel()
+
+	server, _, _ := startServer(t, echoHandler)
+
+	client, err := NewTCPClient(server.Address().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	response, err := client.Send([]byte("GET one"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("echo GET one"), response)
+
+	response, err = client.Send([]byte("DEL one"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("echo DEL one"), response)
+}
+
+func TestClientSendTimeout(t *testing.T) {
+	t.Parallel()
+
+	slowHandler := func(ctx context.Context, request []byte) []byte {
+		time.Sleep(200 * time.Millisecond)
+		return echoHandler(ctx, request)
+	}
+
+	server, _, _ := startServer(t, slowHandler)
+
+	client, err := NewTCPClient(server.Address().String(), WithClientTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Send([]byte("GET one"))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestClientSendToClosedServer(t *testing.T) {
+	t.Parallel()
+
+	server, cancel, done := startServer(t, echoHandler)
+
+	client, err := NewTCPClient(server.Address().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	cancel()
+	<-done
+
+	_, err = client.Send([]byte("GET one"))
+	require.Error(t, err)
+}
@@ -0,0 +1,329 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/txcontext"
+	"inmem-db-go/internal/metrics"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxConnections = 100
+	defaultMaxMessageSize = 4 << 10
+	defaultIdleTimeout    = 5 * time.Minute
+)
+
+var (
+	errTooManyConnections = errors.New("too many connections")
+
+	// ErrMessageTooLarge and ErrMalformedRequest returned by a Framer
+	// are reported to the client before the connection is closed.
+	ErrMessageTooLarge  = errors.New("message is too large")
+	ErrMalformedRequest = errors.New("malformed request")
+)
+
+type TCPHandler = func(context.Context, []byte) []byte
+
+type TCPServerOption func(*TCPServer)
+
+func WithServerMaxConnections(maxConnections int) TCPServerOption {
+	return func(server *TCPServer) {
+		server.maxConnections = maxConnections
+	}
+}
+
+func WithServerIdleTimeout(timeout time.Duration) TCPServerOption {
+	return func(server *TCPServer) {
+		server.idleTimeout = timeout
+	}
+}
+
+func WithServerMaxMessageSize(size int) TCPServerOption {
+	return func(server *TCPServer) {
+		server.maxMessageSize = size
+	}
+}
+
+// WithServerFramer replaces the newline-delimited text protocol of the listener.
+func WithServerFramer(framer Framer) TCPServerOption {
+	return func(server *TCPServer) {
+		server.framer = framer
+	}
+}
+
+// WithServerMetrics reports connected and rejected clients,
+// the listener label tells servers sharing the registry apart.
+func WithServerMetrics(registry *metrics.Registry, listener string) TCPServerOption {
+	return func(server *TCPServer) {
+		server.connectedClients = registry.GaugeVec(
+			"inmemdb_connected_clients",
+			"Clients connected right now.",
+			"listener",
+		).WithLabel(listener)
+		server.rejectedClients = registry.CounterVec(
+			"inmemdb_rejected_connections_total",
+			"Connections rejected over the max connections limit.",
+			"listener",
+		).WithLabel(listener)
+	}
+}
+
+type TCPServer struct {
+	listener net.Listener
+
+	maxConnections int
+	maxMessageSize int
+	idleTimeout    time.Duration
+	framer         Framer
+
+	semaphore   chan struct{}
+	mutex       sync.Mutex
+	connections map[net.Conn]struct{}
+	wg          sync.WaitGroup
+
+	connectedClients *metrics.Gauge
+	rejectedClients  *metrics.Counter
+
+	logger *zap.Logger
+}
+
+func NewTCPServer(address string, logger *zap.Logger, options ...TCPServerOption) (*TCPServer, error) {
+	if logger == nil {
+		return nil, errors.New("logger is invalid")
+	}
+
+	server := &TCPServer{
+		maxConnections: defaultMaxConnections,
+		maxMessageSize: defaultMaxMessageSize,
+		idleTimeout:    defaultIdleTimeout,
+		framer:         lineFramer{},
+		connections:    make(map[net.Conn]struct{}),
+		logger:         logger,
+	}
+
+	for _, option := range options {
+		option(server)
+	}
+
+	if server.maxConnections <= 0 {
+		return nil, errors.New("max connections number is invalid")
+	}
+
+	if server.maxMessageSize <= 0 {
+		return nil, errors.New("max message size is invalid")
+	}
+
+	if server.idleTimeout <= 0 {
+		return nil, errors.New("idle timeout is invalid")
+	}
+
+	if server.framer == nil {
+		return nil, errors.New("framer is invalid")
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	server.listener = listener
+	server.semaphore = make(chan struct{}, server.maxConnections)
+	return server, nil
+}
+
+func (s *TCPServer) Address() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close releases the listener of a server which is not going to serve,
+// HandleQueries and HandleSessions close it by themselves on shutdown.
+func (s *TCPServer) Close() error {
+	return s.listener.Close()
+}
+
+// HandleQueries serves clients until ctx is done. On shutdown it stops accepting
+// connections, interrupts idle reads and waits for in-flight queries to be answered.
+func (s *TCPServer) HandleQueries(ctx context.Context, handler TCPHandler) error {
+	if handler == nil {
+		return errors.New("handler is invalid")
+	}
+
+	return s.HandleSessions(ctx, func() TCPHandler {
+		return handler
+	})
+}
+
+// HandleSessions is like HandleQueries, but every connection gets its own
+// handler from newHandler, so the handler may keep per-client state.
+// Queries of one connection are never handled concurrently.
+func (s *TCPServer) HandleSessions(ctx context.Context, newHandler func() TCPHandler) error {
+	if newHandler == nil {
+		return errors.New("handler builder is invalid")
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := s.listener.Close(); err != nil {
+			s.logger.Warn("failed to close listener", zap.Error(err))
+		}
+
+		s.interruptConnections()
+	}()
+
+	var acceptErr error
+	for {
+		connection, err := s.listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				acceptErr = err
+			}
+
+			break
+		}
+
+		select {
+		case s.semaphore <- struct{}{}:
+		default:
+			s.logger.Warn("connection rejected", zap.Stringer("address", connection.RemoteAddr()))
+			s.rejectedClients.Inc()
+			s.reject(connection, errTooManyConnections)
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer func() {
+				<-s.semaphore
+				s.wg.Done()
+			}()
+
+			s.handleConnection(ctx, connection, newHandler)
+		}()
+	}
+
+	s.wg.Wait()
+	return acceptErr
+}
+
+func (s *TCPServer) handleConnection(ctx context.Context, connection net.Conn, newHandler func() TCPHandler) {
+	address := connection.RemoteAddr().String()
+	if !s.track(connection) {
+		s.closeConnection(connection)
+		return
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			s.logger.Error("captured panic", zap.String("address", address), zap.Any("panic", v))
+		}
+
+		s.untrack(connection)
+		s.closeConnection(connection)
+	}()
+
+	s.logger.Debug("client connected", zap.String("address", address))
+	handler := newHandler()
+
+	// queries already read are answered even if shutdown starts,
+	// so the handler context must not be canceled together with ctx
+	queryCtx := txcontext.WithClientAddress(context.WithoutCancel(ctx), address)
+	// the buffer fits a message of the max size with its "\r\n"
+	reader := bufio.NewReaderSize(connection, s.maxMessageSize+2)
+	for {
+		if err := connection.SetReadDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+			s.logger.Warn("failed to set read deadline", zap.String("address", address), zap.Error(err))
+			return
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		request, err := s.framer.ReadRequest(reader, s.maxMessageSize)
+		if err != nil {
+			if errors.Is(err, ErrMessageTooLarge) || errors.Is(err, ErrMalformedRequest) {
+				_ = s.write(connection, s.framer.FrameError(err))
+			} else if err != io.EOF {
+				s.logger.Debug("failed to read request", zap.String("address", address), zap.Error(err))
+			}
+
+			s.logger.Debug("client disconnected", zap.String("address", address))
+			return
+		}
+
+		response := handler(queryCtx, request)
+		if err := s.write(connection, s.framer.FrameResponse(response)); err != nil {
+			s.logger.Warn("failed to write response", zap.String("address", address), zap.Error(err))
+			return
+		}
+	}
+}
+
+func (s *TCPServer) write(connection net.Conn, data []byte) error {
+	if err := connection.SetWriteDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+		return err
+	}
+
+	_, err := connection.Write(data)
+	return err
+}
+
+func (s *TCPServer) reject(connection net.Conn, reason error) {
+	_ = s.write(connection, s.framer.FrameError(reason))
+	s.closeConnection(connection)
+}
+
+func (s *TCPServer) closeConnection(connection net.Conn) {
+	if err := connection.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		s.logger.Warn("failed to close connection", zap.Error(err))
+	}
+}
+
+// ConnectedClients returns the number of clients connected right now.
+func (s *TCPServer) ConnectedClients() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.connections)
+}
+
+func (s *TCPServer) track(connection net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.connections == nil {
+		return false
+	}
+
+	s.connections[connection] = struct{}{}
+	s.connectedClients.Inc()
+	return true
+}
+
+func (s *TCPServer) untrack(connection net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.connections, connection)
+	s.connectedClients.Dec()
+}
+
+func (s *TCPServer) interruptConnections() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for connection := range s.connections {
+		// blocked reads return immediately, a query being handled
+		// right now still gets its response written
+		_ = connection.SetReadDeadline(time.Now())
+	}
+
+	s.connections = nil
+}
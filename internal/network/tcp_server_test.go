@@ -0,0 +1,242 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, handler TCPHandler, options ...TCPServerOption) (*TCPServer, context.CancelFunc, chan error) {
+	t.Helper()
+
+	server, err := NewTCPServer("localhost:0", zap.NewNop(), options...)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.HandleQueries(ctx, handler)
+	}()
+
+	t.Cleanup(cancel)
+	return server, cancel, done
+}
+
+func dial(t *testing.T, server *TCPServer) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	connection, err := net.Dial("tcp", server.Address().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = connection.Close() })
+
+	return connection, bufio.NewReader(connection)
+}
+
+func echoHandler(_ context.Context, request []byte) []byte {
+	return append([]byte("echo "), request...)
+}
+
+func TestNewTCPServer(t *testing.T) {
+	t.Parallel()
+
+	server, err := NewTCPServer("localhost:0", nil)
+	require.Error(t, err, "logger is invalid")
+	require.Nil(t, server)
+
+	server, err = NewTCPServer("localhost:0", zap.NewNop(), WithServerMaxConnections(0))
+	require.Error(t, err, "max connections number is invalid")
+	require.Nil(t, server)
+
+	server, err = NewTCPServer("localhost:0", zap.NewNop(), WithServerMaxMessageSize(-1))
+	require.Error(t, err, "max message size is invalid")
+	require.Nil(t, server)
+
+	server, err = NewTCPServer("localhost:0", zap.NewNop(), WithServerIdleTimeout(0))
+	require.Error(t, err, "idle timeout is invalid")
+	require.Nil(t, server)
+
+	server, err = NewTCPServer("localhost:0", zap.NewNop())
+	require.NoError(t, err)
+	require.NotNil(t, server)
+}
+
+func TestServerHandlesQueries(t *testing.T) {
+	t.Parallel()
+
+	server, _, _ := startServer(t, echoHandler)
+	connection, reader := dial(t, server)
+
+	_, err := connection.Write([]byte("GET one\nSET one 1\r\n"))
+	require.NoError(t, err)
+
+	response, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "echo GET one\n", response)
+
+	response, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "echo SET one 1\n", response)
+}
+
+func TestServerHandlesConcurrentClients(t *testing.T) {
+	t.Parallel()
+
+	server, _, _ := startServer(t, echoHandler)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		connection, reader := dial(t, server)
+
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+
+			request := fmt.Sprintf("GET key_%d", idx)
+			_, err := connection.Write([]byte(request + "\n"))
+			assert.NoError(t, err)
+
+			response, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			assert.Equal(t, "echo "+request+"\n", response)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestServerKeepsSessionPerConnection(t *testing.T) {
+	t.Parallel()
+
+	server, err := NewTCPServer("localhost:0", zap.NewNop())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = server.HandleSessions(ctx, func() TCPHandler {
+			queries := 0
+			return func(_ context.Context, request []byte) []byte {
+				queries++
+				return []byte(fmt.Sprintf("%d %s", queries, request))
+			}
+		})
+	}()
+
+	first, firstReader := dial(t, server)
+	second, secondReader := dial(t, server)
+
+	exchange := func(connection net.Conn, reader *bufio.Reader, request string) string {
+		_, err := connection.Write([]byte(request + "\n"))
+		require.NoError(t, err)
+
+		response, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		return response
+	}
+
+	assert.Equal(t, "1 a\n", exchange(first, firstReader, "a"))
+	assert.Equal(t, "2 b\n", exchange(first, firstReader, "b"))
+	assert.Equal(t, "1 c\n", exchange(second, secondReader, "c"))
+}
+
+func TestServerRejectsExtraConnections(t *testing.T) {
+	t.Parallel()
+
+	server, _, _ := startServer(t, echoHandler, WithServerMaxConnections(1))
+
+	connection, reader := dial(t, server)
+	_, err := connection.Write([]byte("GET one\n"))
+	require.NoError(t, err)
+	_, err = reader.ReadString('\n')
+	require.NoError(t, err)
+
+	_, reader = dial(t, server)
+	response, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "[error] too many connections\n", response)
+}
+
+func TestServerRejectsLargeMessages(t *testing.T) {
+	t.Parallel()
+
+	server, _, _ := startServer(t, echoHandler, WithServerMaxMessageSize(16))
+	connection, reader := dial(t, server)
+
+	_, err := connection.Write([]byte("SET key 0123456789abcdef\n"))
+	require.NoError(t, err)
+
+	response, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "[error] message is too large\n", response)
+
+	_, err = reader.ReadString('\n')
+	require.Error(t, err)
+}
+
+func TestServerAcceptsMessagesOfMaxSize(t *testing.T) {
+	t.Parallel()
+
+	server, _, _ := startServer(t, echoHandler, WithServerMaxMessageSize(16))
+	connection, reader := dial(t, server)
+
+	for _, message := range []string{"0123456789abcdef\n", "0123456789abcdef\r\n"} {
+		_, err := connection.Write([]byte(message))
+		require.NoError(t, err)
+
+		response, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "echo 0123456789abcdef\n", response)
+	}
+}
+
+func TestServerClosesIdleConnections(t *testing.T) {
+	t.Parallel()
+
+	server, _, _ := startServer(t, echoHandler, WithServerIdleTimeout(50*time.Millisecond))
+	_, reader := dial(t, server)
+
+	_, err := reader.ReadString('\n')
+	require.Error(t, err)
+}
+
+func TestServerGracefulShutdown(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	slowHandler := func(ctx context.Context, request []byte) []byte {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		return echoHandler(ctx, request)
+	}
+
+	server, cancel, done := startServer(t, slowHandler)
+	connection, reader := dial(t, server)
+	_, idleReader := dial(t, server)
+
+	_, err := connection.Write([]byte("GET one\n"))
+	require.NoError(t, err)
+
+	<-started
+	cancel()
+
+	response, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "echo GET one\n", response)
+
+	_, err = idleReader.ReadString('\n')
+	require.Error(t, err)
+
+	select {
+	case err = <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("server has not stopped")
+	}
+}
@@ -0,0 +1,200 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/storage/wal"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	maxRecordsPerResponse = 1024
+	masterIdleTimeout     = time.Minute
+)
+
+var (
+	errReplicaAhead = errors.New("replica is ahead of master")
+	errBatchFull    = errors.New("batch is full")
+)
+
+type walReader interface {
+	Replay(int64, func(wal.Record) error) error
+	LastLSN() int64
+}
+
+type stateDumper interface {
+	Dump() (int64, io.WriterTo)
+}
+
+// Master serves log records to replicas on its own listener.
+type Master struct {
+	listener net.Listener
+	log      walReader
+	storage  stateDumper
+	logger   *zap.Logger
+
+	mutex       sync.Mutex
+	connections map[net.Conn]struct{}
+	wg          sync.WaitGroup
+}
+
+func NewMaster(address string, log walReader, storage stateDumper, logger *zap.Logger) (*Master, error) {
+	if log == nil {
+		return nil, errors.New("wal is invalid")
+	}
+
+	if storage == nil {
+		return nil, errors.New("storage is invalid")
+	}
+
+	if logger == nil {
+		return nil, errors.New("logger is invalid")
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Master{
+		listener:    listener,
+		log:         log,
+		storage:     storage,
+		logger:      logger,
+		connections: make(map[net.Conn]struct{}),
+	}, nil
+}
+
+func (m *Master) Address() net.Addr {
+	return m.listener.Addr()
+}
+
+// Serve handles replicas until ctx is done.
+func (m *Master) Serve(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		if err := m.listener.Close(); err != nil {
+			m.logger.Warn("failed to close replication listener", zap.Error(err))
+		}
+
+		m.mutex.Lock()
+		for connection := range m.connections {
+			_ = connection.Close()
+		}
+		m.mutex.Unlock()
+	}()
+
+	var acceptErr error
+	for {
+		connection, err := m.listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				acceptErr = err
+			}
+
+			break
+		}
+
+		m.mutex.Lock()
+		if ctx.Err() != nil {
+			m.mutex.Unlock()
+			_ = connection.Close()
+			break
+		}
+
+		m.connections[connection] = struct{}{}
+		m.mutex.Unlock()
+
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.handleConnection(connection)
+		}()
+	}
+
+	m.wg.Wait()
+	return acceptErr
+}
+
+func (m *Master) handleConnection(connection net.Conn) {
+	address := connection.RemoteAddr().String()
+	defer func() {
+		m.mutex.Lock()
+		delete(m.connections, connection)
+		m.mutex.Unlock()
+		_ = connection.Close()
+	}()
+
+	m.logger.Info("replica connected", zap.String("address", address))
+
+	decoder := gob.NewDecoder(connection)
+	encoder := gob.NewEncoder(connection)
+	for {
+		if err := connection.SetDeadline(time.Now().Add(masterIdleTimeout)); err != nil {
+			m.logger.Warn("failed to set deadline", zap.String("address", address), zap.Error(err))
+			return
+		}
+
+		var request Request
+		if err := decoder.Decode(&request); err != nil {
+			if err != io.EOF {
+				m.logger.Debug("failed to read replication request", zap.String("address", address), zap.Error(err))
+			}
+
+			m.logger.Info("replica disconnected", zap.String("address", address))
+			return
+		}
+
+		if err := encoder.Encode(m.handleRequest(request)); err != nil {
+			m.logger.Warn("failed to write replication response", zap.String("address", address), zap.Error(err))
+			return
+		}
+	}
+}
+
+func (m *Master) handleRequest(request Request) Response {
+	response := Response{MasterLSN: m.log.LastLSN()}
+	if request.LastLSN > response.MasterLSN {
+		response.Error = errReplicaAhead.Error()
+		return response
+	}
+
+	if request.LastLSN == response.MasterLSN {
+		return response
+	}
+
+	err := m.log.Replay(request.LastLSN, func(record wal.Record) error {
+		response.Records = append(response.Records, record)
+		if len(response.Records) == maxRecordsPerResponse {
+			return errBatchFull
+		}
+
+		return nil
+	})
+
+	switch {
+	case err == nil, errors.Is(err, errBatchFull):
+	case errors.Is(err, wal.ErrRecordsMissing):
+		// the replica is too far behind, so it gets the whole state
+		lsn, data := m.storage.Dump()
+		buffer := bytes.Buffer{}
+		if _, err := data.WriteTo(&buffer); err != nil {
+			response.Error = err.Error()
+			return response
+		}
+
+		response.Records = nil
+		response.Snapshot = buffer.Bytes()
+		response.SnapshotLSN = lsn
+	default:
+		response.Error = err.Error()
+	}
+
+	return response
+}
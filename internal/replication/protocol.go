@@ -0,0 +1,20 @@
+package replication
+
+import "inmem-db-go/internal/database/storage/wal"
+
+// Request asks the master for records written after LastLSN.
+type Request struct {
+	LastLSN int64
+}
+
+// Response carries the next batch of records. When the requested records
+// are not in the master log anymore, the whole master state is sent as
+// a snapshot taken at SnapshotLSN instead.
+type Response struct {
+	Records     []wal.Record
+	Snapshot    []byte
+	SnapshotLSN int64
+	// MasterLSN is the master log position, it is used to compute the lag
+	MasterLSN int64
+	Error     string
+}
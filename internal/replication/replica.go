@@ -0,0 +1,242 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/result"
+	"inmem-db-go/internal/database/storage/wal"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSyncInterval = 100 * time.Millisecond
+	replicaTimeout      = 10 * time.Second
+)
+
+var errNotReplica = result.NewError(result.CodeNotReplica, "server is not a replica")
+
+type replicaStorage interface {
+	LastLSN() int64
+	ApplyReplicated(context.Context, []wal.Record) error
+	RestoreReplicated(context.Context, int64, io.Reader) error
+}
+
+type ReplicaOption func(*Replica)
+
+// WithReplicaSyncInterval sets how often the master is polled when
+// the replica has caught up or the master is not reachable.
+func WithReplicaSyncInterval(interval time.Duration) ReplicaOption {
+	return func(replica *Replica) {
+		replica.syncInterval = interval
+	}
+}
+
+// Replica pulls log records from the master and applies them to the local storage.
+type Replica struct {
+	masterAddress string
+	storage       replicaStorage
+	syncInterval  time.Duration
+	logger        *zap.Logger
+
+	lag      atomic.Int64
+	mutex    sync.Mutex
+	promoted bool
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewReplica(masterAddress string, storage replicaStorage, logger *zap.Logger, options ...ReplicaOption) (*Replica, error) {
+	if masterAddress == "" {
+		return nil, errors.New("master address is invalid")
+	}
+
+	if storage == nil {
+		return nil, errors.New("storage is invalid")
+	}
+
+	if logger == nil {
+		return nil, errors.New("logger is invalid")
+	}
+
+	replica := &Replica{
+		masterAddress: masterAddress,
+		storage:       storage,
+		syncInterval:  defaultSyncInterval,
+		logger:        logger,
+		stop:          make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(replica)
+	}
+
+	if replica.syncInterval <= 0 {
+		return nil, errors.New("sync interval is invalid")
+	}
+
+	return replica, nil
+}
+
+// IsReplica reports whether the server still follows the master.
+func (r *Replica) IsReplica() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return !r.promoted
+}
+
+// Lag returns the number of master records not applied yet,
+// as of the last successful request to the master.
+func (r *Replica) Lag() int64 {
+	return r.lag.Load()
+}
+
+// Promote stops the replication, it returns after the last
+// received batch is applied, so local writes can start right away.
+func (r *Replica) Promote() error {
+	r.mutex.Lock()
+	if r.promoted {
+		r.mutex.Unlock()
+		return errNotReplica
+	}
+
+	r.promoted = true
+	close(r.stop)
+	r.mutex.Unlock()
+
+	r.wg.Wait()
+	r.logger.Info("replica promoted to master", zap.Int64("lsn", r.storage.LastLSN()))
+	return nil
+}
+
+// Run follows the master until ctx is done or the replica is promoted.
+func (r *Replica) Run(ctx context.Context) {
+	r.mutex.Lock()
+	if r.promoted {
+		r.mutex.Unlock()
+		return
+	}
+
+	r.wg.Add(1)
+	r.mutex.Unlock()
+	defer r.wg.Done()
+
+	for {
+		if err := r.follow(ctx); err != nil {
+			r.logger.Warn("replication is interrupted", zap.String("master", r.masterAddress), zap.Error(err))
+		}
+
+		if !r.wait(ctx) {
+			return
+		}
+	}
+}
+
+// follow requests records over one connection until an error happens.
+func (r *Replica) follow(ctx context.Context) error {
+	connection, err := net.DialTimeout("tcp", r.masterAddress, replicaTimeout)
+	if err != nil {
+		return err
+	}
+
+	defer connection.Close()
+
+	// the connection is closed to interrupt a blocked request
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-r.stop:
+		case <-done:
+		}
+		_ = connection.Close()
+	}()
+
+	encoder := gob.NewEncoder(connection)
+	decoder := gob.NewDecoder(connection)
+	for {
+		if err := connection.SetDeadline(time.Now().Add(replicaTimeout)); err != nil {
+			return err
+		}
+
+		if err := encoder.Encode(Request{LastLSN: r.storage.LastLSN()}); err != nil {
+			return err
+		}
+
+		var response Response
+		if err := decoder.Decode(&response); err != nil {
+			return err
+		}
+
+		if response.Error != "" {
+			return errors.New(response.Error)
+		}
+
+		applied, err := r.apply(ctx, response)
+		if err != nil {
+			return err
+		}
+
+		r.lag.Store(response.MasterLSN - r.storage.LastLSN())
+		if !applied && !r.wait(ctx) {
+			return nil
+		}
+	}
+}
+
+// apply reports whether the response had anything to apply.
+func (r *Replica) apply(ctx context.Context, response Response) (bool, error) {
+	// the batch is dropped if the replica has been promoted meanwhile
+	select {
+	case <-r.stop:
+		return false, nil
+	default:
+	}
+
+	if response.Snapshot != nil {
+		if err := r.storage.RestoreReplicated(ctx, response.SnapshotLSN, bytes.NewReader(response.Snapshot)); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	if len(response.Records) == 0 {
+		return false, nil
+	}
+
+	if err := r.storage.ApplyReplicated(ctx, response.Records); err != nil {
+		return false, err
+	}
+
+	r.logger.Debug(
+		"records replicated",
+		zap.Int("records", len(response.Records)),
+		zap.Int64("lsn", response.Records[len(response.Records)-1].LSN),
+	)
+
+	return true, nil
+}
+
+// wait reports whether the replication should go on after the sync interval.
+func (r *Replica) wait(ctx context.Context) bool {
+	timer := time.NewTimer(r.syncInterval)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-r.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,177 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/storage"
+	"inmem-db-go/internal/database/storage/engine/in_memory"
+	"inmem-db-go/internal/database/storage/snapshot"
+	"inmem-db-go/internal/database/storage/wal"
+	"inmem-db-go/internal/database/txcontext"
+	"testing"
+	"time"
+)
+
+type node struct {
+	engine  *in_memory.Engine
+	storage *storage.Storage
+	log     *wal.WAL
+}
+
+func newNode(t *testing.T, options ...storage.StorageOption) node {
+	t.Helper()
+
+	engine, err := in_memory.NewEngine(in_memory.HashTableBuilder, zap.NewNop())
+	require.NoError(t, err)
+
+	log, err := wal.NewWAL(t.TempDir(), zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = log.Close() })
+
+	store, err := storage.NewStorage(engine, zap.NewNop(), append(options, storage.WithWAL(log))...)
+	require.NoError(t, err)
+
+	return node{engine: engine, storage: store, log: log}
+}
+
+func startMaster(t *testing.T, master node) *Master {
+	t.Helper()
+
+	server, err := NewMaster("localhost:0", master.log, master.storage, zap.NewNop())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = server.Serve(ctx)
+	}()
+
+	return server
+}
+
+func startReplica(t *testing.T, address string, replicaNode node) *Replica {
+	t.Helper()
+
+	replica, err := NewReplica(address, replicaNode.storage, zap.NewNop(), WithReplicaSyncInterval(5*time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go replica.Run(ctx)
+
+	return replica
+}
+
+func requireValue(t *testing.T, replicaNode node, key, expected string) {
+	t.Helper()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	require.Eventually(t, func() bool {
+		value, err := replicaNode.storage.Get(ctx, key)
+		return err == nil && value == expected
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestNewReplica(t *testing.T) {
+	t.Parallel()
+
+	replicaNode := newNode(t)
+
+	replica, err := NewReplica("", replicaNode.storage, zap.NewNop())
+	require.Error(t, err, "master address is invalid")
+	require.Nil(t, replica)
+
+	replica, err = NewReplica("localhost:0", nil, zap.NewNop())
+	require.Error(t, err, "storage is invalid")
+	require.Nil(t, replica)
+
+	replica, err = NewReplica("localhost:0", replicaNode.storage, zap.NewNop(), WithReplicaSyncInterval(0))
+	require.Error(t, err, "sync interval is invalid")
+	require.Nil(t, replica)
+}
+
+func TestReplicaFollowsMaster(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	masterNode := newNode(t)
+	replicaNode := newNode(t)
+
+	require.NoError(t, masterNode.storage.Set(ctx, "key_1", "value_1"))
+	require.NoError(t, masterNode.storage.Set(ctx, "key_2", "value_2"))
+
+	master := startMaster(t, masterNode)
+	replica := startReplica(t, master.Address().String(), replicaNode)
+
+	requireValue(t, replicaNode, "key_2", "value_2")
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, masterNode.storage.Set(ctx, fmt.Sprintf("key_%d", i), "new_value"))
+	}
+	require.NoError(t, masterNode.storage.Del(ctx, "key_1"))
+
+	requireValue(t, replicaNode, "key_9", "new_value")
+	require.Eventually(t, func() bool {
+		return replica.Lag() == 0 && replicaNode.storage.LastLSN() == masterNode.storage.LastLSN()
+	}, 5*time.Second, 5*time.Millisecond)
+
+	_, err := replicaNode.storage.Get(ctx, "key_1")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	// the replica log keeps the master positions
+	assert.Equal(t, masterNode.log.LastLSN(), replicaNode.log.LastLSN())
+}
+
+func TestReplicaRestoresTruncatedState(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+
+	masterSnapshots, err := snapshot.NewManager(t.TempDir(), zap.NewNop())
+	require.NoError(t, err)
+	masterNode := newNode(t, storage.WithSnapshots(masterSnapshots))
+
+	replicaSnapshots, err := snapshot.NewManager(t.TempDir(), zap.NewNop())
+	require.NoError(t, err)
+	replicaNode := newNode(t, storage.WithSnapshots(replicaSnapshots))
+
+	require.NoError(t, masterNode.storage.Set(ctx, "key_1", "value_1"))
+	require.NoError(t, masterNode.storage.Snapshot(ctx))
+	// drops the records covered by the snapshot, including the active segment
+	require.NoError(t, masterNode.log.Reset(masterNode.log.LastLSN()))
+	require.NoError(t, masterNode.storage.Set(ctx, "key_2", "value_2"))
+
+	master := startMaster(t, masterNode)
+	startReplica(t, master.Address().String(), replicaNode)
+
+	requireValue(t, replicaNode, "key_1", "value_1")
+	requireValue(t, replicaNode, "key_2", "value_2")
+	assert.Equal(t, masterNode.log.LastLSN(), replicaNode.log.LastLSN())
+}
+
+func TestPromote(t *testing.T) {
+	t.Parallel()
+
+	ctx := txcontext.WithTxID(context.Background(), 555)
+	masterNode := newNode(t)
+	replicaNode := newNode(t)
+
+	master := startMaster(t, masterNode)
+	replica := startReplica(t, master.Address().String(), replicaNode)
+
+	require.NoError(t, masterNode.storage.Set(ctx, "key", "value"))
+	requireValue(t, replicaNode, "key", "value")
+
+	require.True(t, replica.IsReplica())
+	require.NoError(t, replica.Promote())
+	require.False(t, replica.IsReplica())
+	require.ErrorIs(t, replica.Promote(), errNotReplica)
+
+	require.NoError(t, masterNode.storage.Set(ctx, "key", "master_value"))
+	require.NoError(t, replicaNode.storage.Set(ctx, "key", "promoted_value"))
+
+	time.Sleep(20 * time.Millisecond)
+	requireValue(t, replicaNode, "key", "promoted_value")
+}
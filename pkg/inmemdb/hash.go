@@ -0,0 +1,59 @@
+package inmemdb
+
+import "context"
+
+// HSet sets the hash fields and returns the number of fields that didn't exist,
+// a missing key is created. ErrWrongType is returned for string keys.
+func (db *DB) HSet(ctx context.Context, key string, fields map[string]string) (int, error) {
+	release, err := db.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return db.storage.HSet(ctx, key, fields)
+}
+
+// HGet returns ErrNotFound if the field or the key doesn't exist.
+func (db *DB) HGet(ctx context.Context, key, field string) (string, error) {
+	release, err := db.acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	return db.storage.HGet(ctx, key, field)
+}
+
+// HDel removes the hash fields and returns the number of removed ones,
+// the key is removed together with its last field.
+func (db *DB) HDel(ctx context.Context, key string, fields ...string) (int, error) {
+	release, err := db.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return db.storage.HDel(ctx, key, fields)
+}
+
+// HGetAll returns a copy of the hash fields, a missing key has no fields.
+func (db *DB) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	release, err := db.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return db.storage.HGetAll(ctx, key)
+}
+
+func (db *DB) HLen(ctx context.Context, key string) (int, error) {
+	release, err := db.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return db.storage.HLen(ctx, key)
+}
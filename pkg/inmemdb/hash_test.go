@@ -0,0 +1,56 @@
+package inmemdb
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	directory := t.TempDir()
+
+	db, err := Open(WithDirectory(directory))
+	require.NoError(t, err)
+
+	added, err := db.HSet(ctx, "user", map[string]string{"name": "alice", "city": "paris"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	removed, err := db.HDel(ctx, "user", "city", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, err = db.HGet(ctx, "user", "city")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, db.Set(ctx, "string", "value"))
+	_, err = db.HSet(ctx, "string", map[string]string{"field": "value"})
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = db.Get(ctx, "user")
+	assert.ErrorIs(t, err, ErrWrongType)
+	require.NoError(t, db.Close())
+
+	_, err = db.HLen(ctx, "user")
+	assert.ErrorIs(t, err, ErrClosed)
+
+	// hashes are restored from the log
+	db, err = Open(WithDirectory(directory))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	fields, err := db.HGetAll(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "alice"}, fields)
+
+	length, err := db.HLen(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, 1, length)
+
+	value, err := db.HGet(ctx, "user", "name")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", value)
+}
@@ -0,0 +1,353 @@
+// Package inmemdb embeds the database into a Go program. Queries are applied
+// to the storage directly, without the text protocol and its parser.
+//
+// The module path inmem-db-go is not a fetchable import path, so the package
+// serves programs inside this module only, like the ones under cmd.
+package inmemdb
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"inmem-db-go/internal/database/glob"
+	"inmem-db-go/internal/database/storage"
+	"inmem-db-go/internal/database/storage/engine/in_memory"
+	"inmem-db-go/internal/database/storage/snapshot"
+	"inmem-db-go/internal/database/storage/wal"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultExpirationInterval = 100 * time.Millisecond
+
+	walDirectory      = "wal"
+	snapshotDirectory = "snapshots"
+)
+
+var (
+	// ErrNotFound is returned for missing and expired keys.
+	ErrNotFound = storage.ErrNotFound
+	// ErrOutOfMemory is returned for writes over the memory limit
+	// when the eviction policy has nothing to evict.
+	ErrOutOfMemory = in_memory.ErrOutOfMemory
+	// ErrWrongType is returned for operations on keys of another type,
+	// like Get of a hash key or HGet of a string key.
+	ErrWrongType = in_memory.ErrWrongType
+	ErrClosed    = errors.New("database is closed")
+)
+
+type Option func(*options)
+
+type options struct {
+	directory          string
+	shards             int
+	maxMemory          int64
+	evictionPolicy     string
+	expirationInterval time.Duration
+	logger             *zap.Logger
+}
+
+// WithDirectory makes the database persistent: writes go through the write-ahead
+// log and the data is restored from the directory on Open. Without it the
+// database lives purely in memory.
+func WithDirectory(directory string) Option {
+	return func(options *options) {
+		options.directory = directory
+	}
+}
+
+// WithShards partitions keys across independently locked shards.
+func WithShards(shards int) Option {
+	return func(options *options) {
+		options.shards = shards
+	}
+}
+
+// WithMaxMemory limits the approximate memory taken by the keys, the policy
+// is one of noeviction, allkeys-lru, allkeys-lfu, allkeys-random or volatile-ttl.
+func WithMaxMemory(bytes int64, policy string) Option {
+	return func(options *options) {
+		options.maxMemory = bytes
+		options.evictionPolicy = policy
+	}
+}
+
+// WithExpirationInterval sets how often expired keys are reclaimed.
+func WithExpirationInterval(interval time.Duration) Option {
+	return func(options *options) {
+		options.expirationInterval = interval
+	}
+}
+
+func WithLogger(logger *zap.Logger) Option {
+	return func(options *options) {
+		options.logger = logger
+	}
+}
+
+// DB is safe for concurrent use, Close waits for the operations in flight.
+type DB struct {
+	storage   *storage.Storage
+	wal       *wal.WAL
+	snapshots *snapshot.Manager
+
+	stopSweeper context.CancelFunc
+
+	// operations hold the read lock, so Close
+	// holding the write lock waits for them
+	mutex  sync.RWMutex
+	closed bool
+}
+
+func Open(opts ...Option) (*DB, error) {
+	options := &options{
+		shards:             in_memory.DefaultShardsNumber,
+		evictionPolicy:     "noeviction",
+		expirationInterval: defaultExpirationInterval,
+		logger:             zap.NewNop(),
+	}
+
+	for _, option := range opts {
+		option(options)
+	}
+
+	if options.logger == nil {
+		return nil, errors.New("logger is invalid")
+	}
+
+	if options.expirationInterval <= 0 {
+		return nil, errors.New("expiration interval is invalid")
+	}
+
+	policy, err := in_memory.ParseEvictionPolicy(options.evictionPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	engine, err := in_memory.NewEngine(
+		in_memory.ShardedHashTableBuilder(options.shards),
+		options.logger,
+		in_memory.WithMaxMemory(options.maxMemory),
+		in_memory.WithEvictionPolicy(policy),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{}
+
+	var storageOptions []storage.StorageOption
+	if options.directory != "" {
+		db.wal, err = wal.NewWAL(filepath.Join(options.directory, walDirectory), options.logger)
+		if err != nil {
+			return nil, err
+		}
+
+		db.snapshots, err = snapshot.NewManager(filepath.Join(options.directory, snapshotDirectory), options.logger)
+		if err != nil {
+			_ = db.wal.Close()
+			return nil, err
+		}
+
+		storageOptions = append(storageOptions, storage.WithWAL(db.wal), storage.WithSnapshots(db.snapshots))
+	}
+
+	db.storage, err = storage.NewStorage(engine, options.logger, storageOptions...)
+	if err != nil {
+		if db.wal != nil {
+			_ = db.wal.Close()
+		}
+
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	db.stopSweeper = cancel
+	go engine.RunExpirationSweeper(ctx, options.expirationInterval)
+
+	return db, nil
+}
+
+// Close waits for the operations in flight, stops background work and flushes
+// the log. Operations started after it fail with ErrClosed.
+func (db *DB) Close() error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.closed {
+		return nil
+	}
+
+	db.closed = true
+	db.stopSweeper()
+	if db.wal != nil {
+		return db.wal.Close()
+	}
+
+	return nil
+}
+
+func (db *DB) Set(ctx context.Context, key, value string) error {
+	release, err := db.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return db.storage.Set(ctx, key, value)
+}
+
+// SetWithTTL sets the value that expires after ttl.
+func (db *DB) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	release, err := db.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if ttl <= 0 {
+		return errors.New("ttl is invalid")
+	}
+
+	return db.storage.SetWithExpiration(ctx, key, value, time.Now().Add(ttl))
+}
+
+// Get returns ErrNotFound for missing keys and ErrWrongType for hash keys.
+func (db *DB) Get(ctx context.Context, key string) (string, error) {
+	release, err := db.acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	return db.storage.Get(ctx, key)
+}
+
+// Del returns ErrNotFound for missing keys.
+func (db *DB) Del(ctx context.Context, key string) error {
+	release, err := db.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return db.storage.Del(ctx, key)
+}
+
+// Expire sets the key to expire after ttl, a non-positive ttl
+// expires it right away. ErrNotFound is returned for missing keys.
+func (db *DB) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	release, err := db.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	updated, err := db.storage.Expire(ctx, key, time.Now().Add(ttl))
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Persist removes the key expiration. ErrNotFound is returned for missing keys.
+func (db *DB) Persist(ctx context.Context, key string) error {
+	release, err := db.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if _, err := db.ttl(ctx, key); err != nil {
+		return err
+	}
+
+	_, err = db.storage.Persist(ctx, key)
+	return err
+}
+
+// TTL returns the remaining time to live, 0 for keys without expiration
+// and ErrNotFound for missing keys.
+func (db *DB) TTL(ctx context.Context, key string) (time.Duration, error) {
+	release, err := db.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return db.ttl(ctx, key)
+}
+
+// ttl is TTL for the operations which have acquired the DB already,
+// the read lock is not acquired twice since a waiting Close blocks it.
+func (db *DB) ttl(ctx context.Context, key string) (time.Duration, error) {
+	expiresAt, found, err := db.storage.Expiration(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	if !found {
+		return 0, ErrNotFound
+	}
+
+	if expiresAt.IsZero() {
+		return 0, nil
+	}
+
+	return time.Until(expiresAt), nil
+}
+
+// Keys returns the alive keys matching the pattern of the KEYS command
+// in no particular order, the pattern "*" matches all of them.
+func (db *DB) Keys(ctx context.Context, pattern string) ([]string, error) {
+	release, err := db.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	keys, err := db.storage.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := keys[:0]
+	for _, key := range keys {
+		if glob.Match(pattern, key) {
+			matched = append(matched, key)
+		}
+	}
+
+	return matched, nil
+}
+
+// Snapshot saves the data of a persistent database, so fewer log
+// records have to be replayed on Open.
+func (db *DB) Snapshot(ctx context.Context) error {
+	release, err := db.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return db.storage.Snapshot(ctx)
+}
+
+// acquire read locks the DB for an operation, release must be called
+// once the operation is done. ErrClosed is returned after Close.
+func (db *DB) acquire() (release func(), err error) {
+	db.mutex.RLock()
+	if db.closed {
+		db.mutex.RUnlock()
+		return nil, ErrClosed
+	}
+
+	return db.mutex.RUnlock, nil
+}
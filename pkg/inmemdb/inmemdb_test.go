@@ -0,0 +1,166 @@
+package inmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpenInvalidOptions(t *testing.T) {
+	t.Parallel()
+
+	_, err := Open(WithLogger(nil))
+	require.Error(t, err)
+
+	_, err = Open(WithExpirationInterval(0))
+	require.Error(t, err)
+
+	_, err = Open(WithMaxMemory(1024, "volatile-lru"))
+	require.Error(t, err)
+}
+
+func TestInMemory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, err := Open()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	require.NoError(t, db.Set(ctx, "key", "hello world"))
+	value, err := db.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", value)
+
+	_, err = db.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, db.Del(ctx, "missing"), ErrNotFound)
+	assert.ErrorIs(t, db.Expire(ctx, "missing", time.Minute), ErrNotFound)
+	assert.ErrorIs(t, db.Persist(ctx, "missing"), ErrNotFound)
+
+	ttl, err := db.TTL(ctx, "key")
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+
+	require.NoError(t, db.Expire(ctx, "key", time.Minute))
+	ttl, err = db.TTL(ctx, "key")
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, ttl, float64(time.Second))
+
+	require.NoError(t, db.Persist(ctx, "key"))
+	require.NoError(t, db.SetWithTTL(ctx, "volatile", "value", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, err = db.Get(ctx, "volatile")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, db.Set(ctx, "other", "value"))
+	keys, err := db.Keys(ctx, "*")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"key", "other"}, keys)
+
+	keys, err = db.Keys(ctx, "k?y")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key"}, keys)
+
+	keys, err = db.Keys(ctx, "missing*")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	require.NoError(t, db.Del(ctx, "key"))
+	_, err = db.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPersistent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	directory := t.TempDir()
+
+	db, err := Open(WithDirectory(directory))
+	require.NoError(t, err)
+	require.NoError(t, db.Set(ctx, "one", "1"))
+	require.NoError(t, db.Snapshot(ctx))
+	require.NoError(t, db.Set(ctx, "two", "2"))
+	require.NoError(t, db.Del(ctx, "one"))
+	require.NoError(t, db.Close())
+
+	_, err = db.Get(ctx, "two")
+	assert.ErrorIs(t, err, ErrClosed)
+
+	db, err = Open(WithDirectory(directory))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	value, err := db.Get(ctx, "two")
+	require.NoError(t, err)
+	assert.Equal(t, "2", value)
+
+	_, err = db.Get(ctx, "one")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCloseWaitsForWrites(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	directory := t.TempDir()
+
+	db, err := Open(WithDirectory(directory))
+	require.NoError(t, err)
+
+	var mutex sync.Mutex
+	var written []string
+	var wg sync.WaitGroup
+	for writer := 0; writer < 8; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				key := fmt.Sprintf("key_%d_%d", writer, i)
+				err := db.Set(ctx, key, "value")
+				if errors.Is(err, ErrClosed) {
+					return
+				}
+
+				// writes either succeed or fail with ErrClosed,
+				// they never reach the log once it is closed
+				require.NoError(t, err)
+				mutex.Lock()
+				written = append(written, key)
+				mutex.Unlock()
+			}
+		}(writer)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Close())
+	wg.Wait()
+
+	db, err = Open(WithDirectory(directory))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	for _, key := range written {
+		_, err := db.Get(ctx, key)
+		require.NoError(t, err, key)
+	}
+}
+
+func TestMaxMemory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, err := Open(WithMaxMemory(1024, "noeviction"))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	err = db.Set(ctx, "key", string(make([]byte, 2048)))
+	assert.ErrorIs(t, err, ErrOutOfMemory)
+}